@@ -1689,7 +1689,7 @@ func (cf *changeFrontier) checkpointJobProgress(
 			// future job system level retries from having large
 			// backoffs because of past failures.
 			if md.RunStats != nil {
-				ju.UpdateRunStats(1, md.RunStats.LastRun)
+				ju.ResetNumRuns(1, md.RunStats.LastRun)
 			}
 
 			return nil
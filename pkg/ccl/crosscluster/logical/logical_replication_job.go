@@ -527,7 +527,7 @@ func (rh *rowHandler) handleRow(ctx context.Context, row tree.Datums) error {
 			progress.RunningStatus = fmt.Sprintf("logical replication running: %s", replicatedTime.GoTime())
 			ju.UpdateProgress(progress)
 			if md.RunStats != nil && md.RunStats.NumRuns > 1 {
-				ju.UpdateRunStats(1, md.RunStats.LastRun)
+				ju.ResetNumRuns(1, md.RunStats.LastRun)
 			}
 			return nil
 		}); err != nil {
@@ -348,7 +348,7 @@ func (sf *streamIngestionFrontier) maybeUpdateProgress() error {
 		// a steady state. By resetting NumRuns,we avoid future job system level
 		// retries from having a large backoff because of past failures.
 		if md.RunStats != nil && md.RunStats.NumRuns > 1 {
-			ju.UpdateRunStats(1, md.RunStats.LastRun)
+			ju.ResetNumRuns(1, md.RunStats.LastRun)
 		}
 
 		// Update the protected timestamp record protecting the destination tenant's
@@ -345,8 +345,12 @@ SELECT id, status, payload, progress FROM system.jobs
 		md := jobs.JobMetadata{}
 		md.ID = jobspb.JobID(vals[0].(int64))
 		md.Status = jobs.Status(vals[1].(string))
+		payloadBytes, err := jobs.DecodePayload(vals[2].([]byte))
+		if err != nil {
+			return err
+		}
 		md.Payload = &jobspb.Payload{}
-		if err := protoutil.Unmarshal(vals[2].([]byte), md.Payload); err != nil {
+		if err := protoutil.Unmarshal(payloadBytes, md.Payload); err != nil {
 			return err
 		}
 		md.Progress = &jobspb.Progress{}
@@ -524,6 +528,10 @@ func fromZipDir(
 			if !ok {
 				return errors.Newf("job %d: failed to decode hex payload", id)
 			}
+			payloadBytes, err = jobs.DecodePayload(payloadBytes)
+			if err != nil {
+				return errors.Wrap(err, "failed decompressing job payload")
+			}
 			md.Payload = &jobspb.Payload{}
 			if err := protoutil.Unmarshal(payloadBytes, md.Payload); err != nil {
 				return errors.Wrap(err, "failed unmarshalling job payload")
@@ -574,6 +582,10 @@ func fromZipDir(
 			if err != nil {
 				return nil, nil, nil, errors.Wrapf(err, "job %d: failed to decode hex payload", id)
 			}
+			payloadBytes, err = jobs.DecodePayload(payloadBytes)
+			if err != nil {
+				return nil, nil, nil, errors.Wrapf(err, "job %d: failed decompressing job payload", id)
+			}
 			row.Payload = &jobspb.Payload{}
 			if err := protoutil.Unmarshal(payloadBytes, row.Payload); err != nil {
 				return nil, nil, nil, errors.Wrap(err, "failed unmarshalling job payload")
@@ -241,6 +241,10 @@ const (
 	// column to the system.sql_instances table.
 	V24_3_SQLInstancesAddDraining
 
+	// V24_3_JobsPayloadCompression is the version from which job payloads may
+	// be written compressed; see jobs.payload.compression.enabled.
+	V24_3_JobsPayloadCompression
+
 	// *************************************************
 	// Step (1) Add new versions above this comment.
 	// Do not add new versions to a patch release.
@@ -295,6 +299,7 @@ var versionTable = [numKeys]roachpb.Version{
 	V24_3_TenantExcludeDataFromBackup:  {Major: 24, Minor: 2, Internal: 10},
 	V24_3_AdvanceCommitIndexViaMsgApps: {Major: 24, Minor: 2, Internal: 12},
 	V24_3_SQLInstancesAddDraining:      {Major: 24, Minor: 2, Internal: 14},
+	V24_3_JobsPayloadCompression:       {Major: 24, Minor: 2, Internal: 16},
 
 	// *************************************************
 	// Step (2): Add new versions above this comment.
@@ -49,13 +49,64 @@ const (
 	// non-terminal jobs.
 	NonTerminalStatusTupleString = `(` + nonTerminalStatusList + `)`
 
+	// claimQuery claims up to $3 unclaimed jobs, preferring jobs with a higher
+	// priority (see Updater.SetPriority) and, among those, newer jobs. Since
+	// priorities are stored as positive decimals without leading zeros, and
+	// the default of 0 is not stored at all, they are ordered by length and
+	// then bytewise, which never fails on the stored value; NULLs sort last.
 	claimQuery = `
    UPDATE system.jobs
       SET claim_session_id = $1, claim_instance_id = $2
-    WHERE ((claim_session_id IS NULL)
-      AND (status IN ` + claimableStatusTupleString + `))
- ORDER BY created DESC
-    LIMIT $3
+    WHERE claim_session_id IS NULL
+      AND id IN (
+          SELECT j.id
+            FROM system.jobs AS j
+       LEFT JOIN system.job_info AS p
+              ON p.job_id = j.id AND p.info_key = '` + priorityKey + `'
+           WHERE ((j.claim_session_id IS NULL)
+             AND (j.status IN ` + claimableStatusTupleString + `))
+        ORDER BY length(p.value) DESC, p.value DESC, j.created DESC
+           LIMIT $3
+          )
+RETURNING id;`
+
+	// cappedClaimQuery is like claimQuery, but claims no more jobs of each of
+	// the types in $4 than its cap in $5 less the number of jobs of the type
+	// which are already claimed, by any node; see WithMaxActivePerType.
+	cappedClaimQuery = `
+     WITH caps AS (
+          SELECT c.job_type, c.max_active - (
+                 SELECT count(*)
+                   FROM system.jobs
+                  WHERE job_type = c.job_type
+                    AND claim_session_id IS NOT NULL
+                    AND status IN ` + claimableStatusTupleString + `
+                 ) AS available
+            FROM unnest($4::STRING[], $5::INT8[]) AS c (job_type, max_active)
+          )
+   UPDATE system.jobs
+      SET claim_session_id = $1, claim_instance_id = $2
+    WHERE claim_session_id IS NULL
+      AND id IN (
+          SELECT id
+            FROM (
+                 SELECT j.id, j.created, p.value AS priority, c.available,
+                        row_number() OVER (
+                          PARTITION BY j.job_type
+                          ORDER BY length(p.value) DESC, p.value DESC, j.created DESC
+                        ) AS n
+                   FROM system.jobs AS j
+              LEFT JOIN system.job_info AS p
+                     ON p.job_id = j.id AND p.info_key = '` + priorityKey + `'
+              LEFT JOIN caps AS c
+                     ON c.job_type = j.job_type
+                  WHERE ((j.claim_session_id IS NULL)
+                    AND (j.status IN ` + claimableStatusTupleString + `))
+                 ) AS candidates
+           WHERE available IS NULL OR n <= available
+        ORDER BY length(priority) DESC, priority DESC, created DESC
+           LIMIT $3
+          )
 RETURNING id;`
 )
 
@@ -97,9 +148,20 @@ func (r *Registry) claimJobs(ctx context.Context, s sqlliveness.Session) error {
 		if err := txn.KV().SetUserPriority(roachpb.MinUserPriority); err != nil {
 			return errors.WithAssertionFailure(err)
 		}
-		numRows, err := txn.Exec(
-			ctx, "claim-jobs", txn.KV(), claimQuery,
-			s.ID().UnsafeBytes(), r.ID(), maxAdoptionsPerLoop)
+		typs, caps, err := r.maxActivePerTypeArgs()
+		if err != nil {
+			return err
+		}
+		var numRows int
+		if typs == nil {
+			numRows, err = txn.Exec(
+				ctx, "claim-jobs", txn.KV(), claimQuery,
+				s.ID().UnsafeBytes(), r.ID(), maxAdoptionsPerLoop)
+		} else {
+			numRows, err = txn.Exec(
+				ctx, "claim-capped-jobs", txn.KV(), cappedClaimQuery,
+				s.ID().UnsafeBytes(), r.ID(), maxAdoptionsPerLoop, typs, caps)
+		}
 		if err != nil {
 			return errors.Wrap(err, "could not query jobs table")
 		}
@@ -351,6 +413,8 @@ func (r *Registry) loadJobForResume(
 
 	payload := &jobspb.Payload{}
 	progress := &jobspb.Progress{}
+	var epoch int64
+	var tooNew, excluded bool
 	if err := r.db.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
 		infoStorage := job.InfoStorage(txn)
 		payloadBytes, exists, err := infoStorage.GetLegacyPayload(ctx)
@@ -363,6 +427,29 @@ func (r *Registry) loadJobForResume(
 		if err := protoutil.Unmarshal(payloadBytes, payload); err != nil {
 			return err
 		}
+		// Leave the job for a node running a newer binary if it requires one.
+		if v := payload.MinBinaryVersion; v != nil && r.settings.Version.LatestVersion().Less(*v) {
+			tooNew = true
+			_, err := txn.ExecEx(
+				ctx, "clear-job-claim", txn.KV(),
+				sessiondata.NodeUserSessionDataOverride,
+				clearClaimQuery, jobID, s.ID().UnsafeBytes(), r.ID(),
+			)
+			return err
+		}
+
+		// Leave the job queued, and unclaimed, if another job of its
+		// exclusion group is running, and take hold of the group otherwise.
+		if excluded, err = acquireExclusionGroup(ctx, txn, infoStorage, s); err != nil {
+			return err
+		} else if excluded {
+			_, err := txn.ExecEx(
+				ctx, "clear-job-claim", txn.KV(),
+				sessiondata.NodeUserSessionDataOverride,
+				clearClaimQuery, jobID, s.ID().UnsafeBytes(), r.ID(),
+			)
+			return err
+		}
 
 		progressBytes, exists, err := infoStorage.GetLegacyProgress(ctx)
 		if err != nil {
@@ -371,18 +458,87 @@ func (r *Registry) loadJobForResume(
 		if !exists {
 			return errors.Wrap(&JobNotFoundError{jobID: jobID}, "job progress not found in system.job_info")
 		}
-		return protoutil.Unmarshal(progressBytes, progress)
+		if err := protoutil.Unmarshal(progressBytes, progress); err != nil {
+			return err
+		}
+		// Fence off writes from the resumers of earlier adoptions, if the
+		// job's type asked for it.
+		if opts, ok := getRegisterOptions(payload.Type()); ok && opts.bumpsEpoch {
+			epoch, err = bumpEpoch(ctx, infoStorage)
+		}
+		return err
 	}); err != nil {
 		return nil, err
 	}
+	if tooNew {
+		log.Infof(ctx, "job %d: requires binary version %s; releasing it for a newer node",
+			jobID, payload.MinBinaryVersion)
+		return nil, nil
+	}
+	if excluded {
+		log.Infof(ctx, "job %d: another job of its exclusion group is running; releasing it", jobID)
+		return nil, nil
+	}
 
 	job.mu.payload = *payload
 	job.mu.progress = *progress
 	job.mu.status = status
-	job.session = s
+	job.mu.epoch = epoch
+	job.mu.session = s
 	return job, nil
 }
 
+// exclusionGroupHolderQuery finds a job, other than $1, of the exclusion group
+// $2 which is running or reverting under the session with which it took hold
+// of the group. It only reads the exclusion group rows of the running and
+// reverting jobs, so the lookup is bounded by the number of those jobs rather
+// than by the size of system.job_info.
+const exclusionGroupHolderQuery = `
+SELECT j.id
+  FROM system.jobs AS j
+  JOIN system.job_info AS g ON g.job_id = j.id AND g.info_key = '` + exclusionGroupKey + `'
+  JOIN system.job_info AS h ON h.job_id = j.id AND h.info_key = '` + exclusionGroupHolderKey + `'
+ WHERE j.status IN ` + processQueryStatusTupleString + `
+   AND j.id != $1
+   AND g.value = $2
+   AND j.claim_session_id = h.value
+ LIMIT 1`
+
+// acquireExclusionGroup checks whether another job of the exclusion group of
+// the job being resumed, if any, is running, i.e. is running or reverting under
+// the session with which it took hold of the group. If none is, the job takes
+// hold of the group under the given session. It returns whether the job must
+// remain queued instead. The holder rows of the other jobs are read in the
+// transaction, so concurrent attempts to take hold of the same group conflict
+// with each other.
+func acquireExclusionGroup(
+	ctx context.Context, txn isql.Txn, infoStorage InfoStorage, s sqlliveness.Session,
+) (excluded bool, _ error) {
+	group, ok, err := infoStorage.get(ctx, exclusionGroupKey)
+	if err != nil || !ok {
+		return false, err
+	}
+	row, err := txn.QueryRowEx(
+		ctx, "exclusion-group-holder", txn.KV(),
+		sessiondata.NodeUserSessionDataOverride,
+		exclusionGroupHolderQuery, infoStorage.j.ID(), group,
+	)
+	if err != nil {
+		return false, errors.Wrapf(err, "job %d: checking exclusion group %q",
+			infoStorage.j.ID(), group)
+	}
+	if row != nil {
+		return true, nil
+	}
+	return false, infoStorage.write(ctx, exclusionGroupHolderKey, s.ID().UnsafeBytes())
+}
+
+// releaseExclusionGroup lets go of the exclusion group of a job reaching a
+// terminal status, so that its holder row does not outlive its run.
+func releaseExclusionGroup(ctx context.Context, infoStorage InfoStorage) error {
+	return infoStorage.write(ctx, exclusionGroupHolderKey, nil)
+}
+
 // addAdoptedJob adds the job to the set of currently running jobs. This set is
 // used for introspection, and, importantly, to serve as a lock to prevent
 // concurrent executions. Removal occurs in runJob or in the case that we were
@@ -553,6 +709,9 @@ func (r *Registry) servePauseAndCancelRequests(ctx context.Context, s sqllivenes
 						&RunStats{},
 						StatusPauseRequested,
 						StatusPaused)
+					if typ, err := jobspb.TypeFromString(string(jobTypeString)); err == nil {
+						r.publishStatusChange(id, typ, StatusPauseRequested, StatusPaused)
+					}
 				})
 				log.Infof(ctx, "job %d, session %s: paused", id, s.ID())
 			case StatusReverting:
@@ -580,7 +739,7 @@ func (r *Registry) servePauseAndCancelRequests(ctx context.Context, s sqllivenes
 					// When we cancel a job, we want to reset its last_run and num_runs
 					// so that the job can be picked-up in the next adopt-loop, sooner
 					// than its current next-retry time.
-					ju.UpdateRunStats(0 /* numRuns */, r.clock.Now().GoTime() /* lastRun */)
+					ju.ResetNumRuns(0 /* numRuns */, r.clock.Now().GoTime() /* lastRun */)
 					txn.KV().AddCommitTrigger(func(ctx context.Context) {
 						LogStatusChangeStructured(ctx,
 							id,
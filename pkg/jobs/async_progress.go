@@ -0,0 +1,115 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package jobs
+
+import (
+	"context"
+
+	"github.com/cockroachdb/cockroach/pkg/jobs/jobspb"
+	"github.com/cockroachdb/cockroach/pkg/sql/isql"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+	"github.com/cockroachdb/errors"
+)
+
+// asyncProgressWriter persists progress buffered by Job.AsyncProgress in the
+// background. Only the most recently buffered progress is written; progress
+// superseded before it could be written is skipped.
+type asyncProgressWriter struct {
+	mu struct {
+		syncutil.Mutex
+		// pending is the progress that is yet to be written, if any.
+		pending *jobspb.Progress
+		// drained is non-nil while a writer task is running, and is closed
+		// once it has written all pending progress.
+		drained chan struct{}
+		// err is the first error encountered writing progress.
+		err error
+	}
+}
+
+// AsyncProgress buffers progress to be written in the background and returns
+// without waiting for it to be written. Progress buffered before an earlier
+// write completes replaces the earlier one. Callers must not modify progress
+// after passing it. Use Barrier to wait for buffered progress to be durable.
+func (j *Job) AsyncProgress(progress *jobspb.Progress) {
+	w := &j.asyncProgress
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.mu.pending = progress
+	if w.mu.drained != nil {
+		return
+	}
+	w.mu.drained = make(chan struct{})
+	ctx := j.registry.ac.AnnotateCtx(context.Background())
+	if err := j.registry.stopper.RunAsyncTask(ctx, "jobs/async-progress", func(ctx context.Context) {
+		ctx, cancel := j.registry.stopper.WithCancelOnQuiesce(ctx)
+		defer cancel()
+		j.writeAsyncProgress(ctx)
+	}); err != nil {
+		w.mu.pending = nil
+		w.recordErrLocked(err)
+		close(w.mu.drained)
+		w.mu.drained = nil
+	}
+}
+
+// writeAsyncProgress writes buffered progress until none is left.
+func (j *Job) writeAsyncProgress(ctx context.Context) {
+	w := &j.asyncProgress
+	for {
+		w.mu.Lock()
+		progress := w.mu.pending
+		w.mu.pending = nil
+		if progress == nil {
+			close(w.mu.drained)
+			w.mu.drained = nil
+			w.mu.Unlock()
+			return
+		}
+		w.mu.Unlock()
+
+		err := j.NoTxn().Update(ctx, func(_ isql.Txn, _ JobMetadata, ju *JobUpdater) error {
+			ju.UpdateProgress(progress)
+			return nil
+		})
+		if err != nil {
+			w.mu.Lock()
+			w.recordErrLocked(err)
+			w.mu.Unlock()
+		}
+	}
+}
+
+func (w *asyncProgressWriter) recordErrLocked(err error) {
+	if w.mu.err == nil {
+		w.mu.err = errors.Wrap(err, "writing progress asynchronously")
+	}
+}
+
+// Barrier blocks until all progress buffered by AsyncProgress before the call
+// has been committed. It returns the first error encountered writing buffered
+// progress, if any.
+func (j *Job) Barrier(ctx context.Context) error {
+	w := &j.asyncProgress
+	w.mu.Lock()
+	drained := w.mu.drained
+	w.mu.Unlock()
+	if drained != nil {
+		select {
+		case <-drained:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.mu.err
+}
@@ -0,0 +1,86 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package jobs
+
+import (
+	"context"
+	"strings"
+
+	"github.com/cockroachdb/cockroach/pkg/settings"
+	"github.com/cockroachdb/cockroach/pkg/sql/isql"
+	"github.com/cockroachdb/errors"
+)
+
+// diagnosticsKeyPrefix is the prefix of the info_keys under which diagnostics
+// attached to a job are stored, followed by the name of the attachment.
+const diagnosticsKeyPrefix = "diagnostics/"
+
+var diagnosticsMaxTotalSize = settings.RegisterByteSizeSetting(
+	settings.ApplicationLevel,
+	"jobs.diagnostics.max_total_size",
+	"the maximum total byte size of the diagnostics attached to a single job",
+	1<<20, /* 1 MiB */
+)
+
+// ErrDiagnosticsTooLarge is returned when attaching diagnostics to a job would
+// exceed jobs.diagnostics.max_total_size.
+var ErrDiagnosticsTooLarge = errors.New("job diagnostics exceed the maximum total size")
+
+// AttachDiagnostics attaches data, such as stack traces or statistics, to the
+// job under the given name for post-mortem analysis, replacing any previous
+// attachment of the same name. It fails with ErrDiagnosticsTooLarge if the
+// total size of the job's attachments would exceed
+// jobs.diagnostics.max_total_size.
+func (u Updater) AttachDiagnostics(ctx context.Context, name string, data []byte) error {
+	if name == "" {
+		return errors.AssertionFailedf("diagnostics must be named")
+	}
+	key := diagnosticsKeyPrefix + name
+	return u.Update(ctx, func(txn isql.Txn, md JobMetadata, ju *JobUpdater) error {
+		total := int64(len(data))
+		if err := u.j.InfoStorage(txn).Iterate(ctx, diagnosticsKeyPrefix, func(infoKey string, value []byte) error {
+			if infoKey != key {
+				total += int64(len(value))
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		if limit := diagnosticsMaxTotalSize.Get(&u.j.registry.settings.SV); total > limit {
+			return errors.Wrapf(ErrDiagnosticsTooLarge,
+				"attaching %q would bring the total to %d bytes, above %d", name, total, limit)
+		}
+		if data == nil {
+			// A nil value would delete the attachment.
+			data = []byte{}
+		}
+		ju.writeInfo(key, data)
+		return nil
+	})
+}
+
+// Diagnostics returns the diagnostics attached to the job with
+// AttachDiagnostics, keyed by name.
+func (j *Job) Diagnostics(ctx context.Context) (map[string][]byte, error) {
+	diagnostics := make(map[string][]byte)
+	if err := j.registry.db.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+		for name := range diagnostics {
+			delete(diagnostics, name)
+		}
+		return j.InfoStorage(txn).Iterate(ctx, diagnosticsKeyPrefix, func(infoKey string, value []byte) error {
+			diagnostics[strings.TrimPrefix(infoKey, diagnosticsKeyPrefix)] = value
+			return nil
+		})
+	}); err != nil {
+		return nil, err
+	}
+	return diagnostics, nil
+}
@@ -0,0 +1,58 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/jobs/jobspb"
+	"github.com/cockroachdb/cockroach/pkg/security/username"
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttachDiagnostics(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s, r, _ := startManualClaimServer(t)
+	defer s.Stopper().Stop(ctx)
+	diagnosticsMaxTotalSize.Override(ctx, &s.ClusterSettings().SV, 100)
+
+	record := Record{
+		Details:  jobspb.ImportDetails{},
+		Progress: jobspb.ImportProgress{},
+		Username: username.TestUserName(),
+	}
+	j, err := r.CreateJobWithTxn(ctx, record, r.MakeJobID(), nil /* txn */)
+	require.NoError(t, err)
+
+	stacks := bytes.Repeat([]byte("s"), 60)
+	require.NoError(t, j.NoTxn().AttachDiagnostics(ctx, "stacks", stacks))
+
+	// Together with the stacks, the stats would exceed the cap.
+	err = j.NoTxn().AttachDiagnostics(ctx, "stats", bytes.Repeat([]byte("x"), 50))
+	require.True(t, errors.Is(err, ErrDiagnosticsTooLarge), "unexpected error: %v", err)
+
+	// Replacing an attachment only counts its new size.
+	stacks = bytes.Repeat([]byte("t"), 90)
+	require.NoError(t, j.NoTxn().AttachDiagnostics(ctx, "stacks", stacks))
+	require.NoError(t, j.NoTxn().AttachDiagnostics(ctx, "stats", []byte("ok")))
+
+	diagnostics, err := j.Diagnostics(ctx)
+	require.NoError(t, err)
+	require.Equal(t, map[string][]byte{"stacks": stacks, "stats": []byte("ok")}, diagnostics)
+}
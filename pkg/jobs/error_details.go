@@ -0,0 +1,62 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package jobs
+
+import (
+	"context"
+	gojson "encoding/json"
+
+	"github.com/cockroachdb/cockroach/pkg/sql/isql"
+	"github.com/cockroachdb/errors"
+)
+
+// JobErrorDetails is structured information about the error a job failed
+// with, for tooling, stored alongside the free-form error of its payload.
+type JobErrorDetails struct {
+	// Code is the error code of the failure, e.g. a pgcode.
+	Code string `json:"code,omitempty"`
+	// Retryable is set if the failure is expected to go away if the job is
+	// run again.
+	Retryable bool `json:"retryable,omitempty"`
+	// LastOffendingRow describes the last row the job failed to process, if
+	// any.
+	LastOffendingRow string `json:"last_offending_row,omitempty"`
+}
+
+// SetErrorDetails records details as the structured error details of the job
+// (to be persisted), typically in the update which moves the job to
+// StatusFailed, so that both are written in the same transaction; see
+// Job.ErrorDetails.
+func (ju *JobUpdater) SetErrorDetails(details JobErrorDetails) error {
+	value, err := gojson.Marshal(details)
+	if err != nil {
+		return errors.Wrap(err, "encoding job error details")
+	}
+	ju.writeInfo(errorDetailsKey, value)
+	return nil
+}
+
+// ErrorDetails returns the structured error details of the job recorded with
+// JobUpdater.SetErrorDetails, if any.
+func (j *Job) ErrorDetails(ctx context.Context) (details JobErrorDetails, ok bool, _ error) {
+	if err := j.registry.db.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+		var value []byte
+		var err error
+		value, ok, err = j.InfoStorage(txn).get(ctx, errorDetailsKey)
+		if err != nil || !ok {
+			return err
+		}
+		return errors.Wrap(gojson.Unmarshal(value, &details), "decoding job error details")
+	}); err != nil {
+		return JobErrorDetails{}, false, err
+	}
+	return details, ok, nil
+}
@@ -77,6 +77,71 @@ func IsPauseSelfError(err error) bool {
 // PauseRequestExplained is a prose used to wrap and explain a pause-request error.
 const PauseRequestExplained = "pausing due to error; use RESUME JOB to try to proceed once the issue is resolved, or CANCEL JOB to rollback"
 
+// ErrIncompleteProgress is returned when a job is asked to complete only if
+// its progress is complete, but the stored progress indicates otherwise.
+var ErrIncompleteProgress = errors.New("job progress is incomplete")
+
+// ErrClaimInstanceMismatch is returned when a job is updated under a claim
+// whose session matches this node's but whose instance does not.
+var ErrClaimInstanceMismatch = errors.New("job claim is held by another instance")
+
+// ErrStaleEpoch is returned when an update fenced on a resumption epoch is
+// attempted after the job has moved on to a later epoch.
+var ErrStaleEpoch = errors.New("job resumption epoch is stale")
+
+// ErrStaleSequence is returned when an update conditioned on the job's update
+// sequence number is attempted after the job has been updated again.
+var ErrStaleSequence = errors.New("job update sequence is stale")
+
+// ErrConcurrentStatusChange marks the ConcurrentStatusChangeError returned when
+// a status transition made with JobUpdater.UpdateStatusCAS finds that the job
+// is no longer in the expected status.
+var ErrConcurrentStatusChange = errors.New("job status changed concurrently")
+
+// ConcurrentStatusChangeError is the error returned when a compare-and-swap
+// status transition finds that the job is no longer in the expected status.
+// It is marked with ErrConcurrentStatusChange.
+type ConcurrentStatusChangeError struct {
+	JobID    jobspb.JobID
+	Expected Status
+}
+
+func (e *ConcurrentStatusChangeError) Error() string {
+	return fmt.Sprintf("job %d is no longer in expected status %q", e.JobID, e.Expected)
+}
+
+// ErrIllegalStatusTransition is returned when an update attempts to transition
+// a job between two statuses for which IsValidStatusTransition is false.
+var ErrIllegalStatusTransition = errors.New("illegal job status transition")
+
+// ErrHighWaterRegression is returned when a job's high-water mark would be
+// moved backwards, e.g. by a stale coordinator.
+var ErrHighWaterRegression = errors.New("job high-water mark would regress")
+
+// ErrCheckpointRegression is returned when an update writes progress with a
+// checkpoint ID that isn't greater than the job's current one.
+var ErrCheckpointRegression = errors.New("job checkpoint ID would not increase")
+
+// ErrUpdateTimeout marks errors of updates which ran into the statement
+// timeout of their Updater; see Updater.WithStatementTimeout.
+var ErrUpdateTimeout = errors.New("job update timed out")
+
+// ErrRunStatsRegression is returned when an update would decrease a job's
+// number of runs other than through ResetRunStats.
+var ErrRunStatsRegression = errors.New("job number of runs would decrease")
+
+// ErrProgressKindMismatch is returned when an update writes progress of a kind
+// other than the one declared for the job's type with WithProgressKind.
+var ErrProgressKindMismatch = errors.New("job progress kind mismatch")
+
+// ErrCorruptJobPayload marks errors returned when a stored job payload cannot
+// be decoded, so that callers can tell a corrupt job record apart from other
+// failures and, e.g., quarantine the job rather than retry it.
+var ErrCorruptJobPayload = errors.New("corrupt job payload")
+
+// ErrCorruptJobProgress is like ErrCorruptJobPayload for job progress.
+var ErrCorruptJobProgress = errors.New("corrupt job progress")
+
 // errJobLeaseNotHeld is a marker error for returning from a job execution if it
 // knows or finds out it no longer has a job lease.
 var errJobLeaseNotHeld = errors.New("job lease not held")
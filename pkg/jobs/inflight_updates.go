@@ -0,0 +1,117 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package jobs
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/jobs/jobspb"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+)
+
+// UpdatePhase is the step an in-flight job update is at.
+type UpdatePhase int32
+
+const (
+	// UpdatePhaseBegin is the phase of updates waiting for their transaction
+	// to start, or to be retried.
+	UpdatePhaseBegin UpdatePhase = iota
+	// UpdatePhaseLoad is the phase of updates loading the job's metadata.
+	UpdatePhaseLoad
+	// UpdatePhaseUpdateFn is the phase of updates running their UpdateFn.
+	UpdatePhaseUpdateFn
+	// UpdatePhaseWrite is the phase of updates writing the job's records, or
+	// waiting for their transaction to commit.
+	UpdatePhaseWrite
+)
+
+func (p UpdatePhase) String() string {
+	switch p {
+	case UpdatePhaseBegin:
+		return "begin"
+	case UpdatePhaseLoad:
+		return "load"
+	case UpdatePhaseUpdateFn:
+		return "update-fn"
+	case UpdatePhaseWrite:
+		return "write"
+	default:
+		return "unknown"
+	}
+}
+
+// InFlightUpdate describes a job update which is currently executing.
+type InFlightUpdate struct {
+	ID       jobspb.JobID
+	Duration time.Duration
+	Phase    UpdatePhase
+}
+
+// inFlightUpdate is the tracked state of an executing update.
+type inFlightUpdate struct {
+	id    jobspb.JobID
+	start time.Time
+	phase atomic.Int32
+}
+
+func (u *inFlightUpdate) setPhase(phase UpdatePhase) {
+	u.phase.Store(int32(phase))
+}
+
+// inFlightUpdates tracks the job updates executing on this registry.
+type inFlightUpdates struct {
+	mu struct {
+		syncutil.Mutex
+		updates map[*inFlightUpdate]struct{}
+	}
+}
+
+// start registers an executing update of the job with the given ID. The
+// returned inFlightUpdate must be passed to finish once the update returns.
+func (t *inFlightUpdates) start(id jobspb.JobID) *inFlightUpdate {
+	u := &inFlightUpdate{id: id, start: timeutil.Now()}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.mu.updates == nil {
+		t.mu.updates = make(map[*inFlightUpdate]struct{})
+	}
+	t.mu.updates[u] = struct{}{}
+	return u
+}
+
+func (t *inFlightUpdates) finish(u *inFlightUpdate) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.mu.updates, u)
+}
+
+// InFlightUpdates returns the job updates currently executing on this
+// registry, longest-running first. It is intended for diagnosing stuck jobs.
+func (r *Registry) InFlightUpdates() []InFlightUpdate {
+	now := timeutil.Now()
+	r.inFlightUpdates.mu.Lock()
+	res := make([]InFlightUpdate, 0, len(r.inFlightUpdates.mu.updates))
+	for u := range r.inFlightUpdates.mu.updates {
+		res = append(res, InFlightUpdate{
+			ID:       u.id,
+			Duration: now.Sub(u.start),
+			Phase:    UpdatePhase(u.phase.Load()),
+		})
+	}
+	r.inFlightUpdates.mu.Unlock()
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Duration > res[j].Duration
+	})
+	return res
+}
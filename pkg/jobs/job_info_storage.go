@@ -13,6 +13,10 @@ package jobs
 import (
 	"bytes"
 	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/cockroachdb/cockroach/pkg/jobs/jobspb"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
@@ -20,7 +24,9 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/sql/sem/tree"
 	"github.com/cockroachdb/cockroach/pkg/sql/sessiondata"
 	"github.com/cockroachdb/cockroach/pkg/sql/sqlliveness"
+	"github.com/cockroachdb/cockroach/pkg/sql/types"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
 	"github.com/cockroachdb/cockroach/pkg/util/tracing"
 	"github.com/cockroachdb/errors"
 )
@@ -49,6 +55,15 @@ func InfoStorageForJob(txn isql.Txn, jobID jobspb.JobID) InfoStorage {
 	return InfoStorage{j: &Job{id: jobID}, txn: txn}
 }
 
+// now returns the current time according to the clock of the job's registry,
+// if the storage was created for a job with one.
+func (i InfoStorage) now() time.Time {
+	if r := i.j.registry; r != nil {
+		return r.clock.Now().GoTime()
+	}
+	return timeutil.Now()
+}
+
 func (i *InfoStorage) checkClaimSession(ctx context.Context) error {
 	if i.claimChecked {
 		return nil
@@ -112,6 +127,39 @@ func (i InfoStorage) get(ctx context.Context, infoKey string) ([]byte, bool, err
 	return []byte(*value), true, nil
 }
 
+// getWithWritten is like get, but also returns the time at which the record
+// was written.
+func (i InfoStorage) getWithWritten(
+	ctx context.Context, infoKey string,
+) (value []byte, written time.Time, ok bool, _ error) {
+	if i.txn == nil {
+		return nil, time.Time{}, false, errors.New("cannot access the job info table without an associated txn")
+	}
+
+	ctx, sp := tracing.ChildSpan(ctx, "get-job-info")
+	defer sp.Finish()
+
+	row, err := i.txn.QueryRowEx(
+		ctx, "job-info-get-with-written", i.txn.KV(),
+		sessiondata.NodeUserSessionDataOverride,
+		"SELECT value, written FROM system.job_info WHERE job_id = $1 AND info_key::string = $2 ORDER BY written DESC LIMIT 1",
+		i.j.ID(), infoKey,
+	)
+	if err != nil || row == nil {
+		return nil, time.Time{}, false, err
+	}
+
+	v, ok := row[0].(*tree.DBytes)
+	if !ok {
+		return nil, time.Time{}, false, errors.AssertionFailedf("job info: expected value to be DBytes (was %T)", row[0])
+	}
+	ts, ok := row[1].(*tree.DTimestampTZ)
+	if !ok {
+		return nil, time.Time{}, false, errors.AssertionFailedf("job info: expected written to be DTimestampTZ (was %T)", row[1])
+	}
+	return []byte(*v), ts.Time, true, nil
+}
+
 func (i InfoStorage) write(ctx context.Context, infoKey string, value []byte) error {
 	return i.doWrite(ctx, func(ctx context.Context, j *Job, txn isql.Txn) error {
 		// First clear out any older revisions of this info.
@@ -187,14 +235,20 @@ func (i InfoStorage) iterate(
 		return errors.AssertionFailedf("unhandled iteration mode %v", iterMode)
 	}
 
+	keyBounds := `AND info_key >= $2 AND info_key < $3`
+	args := []interface{}{i.j.ID(), infoPrefix, string(roachpb.Key(infoPrefix).PrefixEnd())}
+	if infoPrefix == "" {
+		// Every info key has the empty prefix.
+		keyBounds, args = "", args[:1]
+	}
 	rows, err := i.txn.QueryIteratorEx(
 		ctx, "job-info-iter", i.txn.KV(),
 		sessiondata.NodeUserSessionDataOverride,
 		`SELECT info_key, value
 		FROM system.job_info
-		WHERE job_id = $1 AND info_key >= $2 AND info_key < $3
+		WHERE job_id = $1 `+keyBounds+`
 		`+iterConfig,
-		i.j.ID(), infoPrefix, string(roachpb.Key(infoPrefix).PrefixEnd()),
+		args...,
 	)
 	if err != nil {
 		return err
@@ -245,7 +299,12 @@ func (i InfoStorage) Write(ctx context.Context, infoKey string, value []byte) er
 	if value == nil {
 		return errors.AssertionFailedf("missing value (infoKey %q)", infoKey)
 	}
-	return i.write(ctx, infoKey, value)
+	// Also drop any expiration set by an earlier WriteWithTTL, so the rewritten
+	// record is kept.
+	return i.WriteBatch(ctx, map[string][]byte{
+		infoKey:                        value,
+		infoExpirationPrefix + infoKey: nil,
+	})
 }
 
 // Delete removes the info record for the provided infoKey.
@@ -253,6 +312,55 @@ func (i InfoStorage) Delete(ctx context.Context, infoKey string) error {
 	return i.write(ctx, infoKey, nil /* value */)
 }
 
+// WriteBatch replaces the info records for all the infoKeys in entries with
+// their values, as Write does for a single one, but using a single delete and a
+// single multi-row insert. A nil value deletes the info record.
+func (i InfoStorage) WriteBatch(ctx context.Context, entries map[string][]byte) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	infoKeys := make([]string, 0, len(entries))
+	for infoKey := range entries {
+		infoKeys = append(infoKeys, infoKey)
+	}
+	sort.Strings(infoKeys)
+	return i.doWrite(ctx, func(ctx context.Context, j *Job, txn isql.Txn) error {
+		keyArray := tree.NewDArray(types.String)
+		params := []interface{}{j.ID()}
+		var tuples []string
+		for _, infoKey := range infoKeys {
+			if err := keyArray.Append(tree.NewDString(infoKey)); err != nil {
+				return err
+			}
+			value := entries[infoKey]
+			if value == nil {
+				continue
+			}
+			params = append(params, infoKey, value)
+			tuples = append(tuples, fmt.Sprintf("($1, $%d, now(), $%d)", len(params)-1, len(params)))
+		}
+		if _, err := txn.ExecEx(
+			ctx, "write-job-info-delete-keys", txn.KV(),
+			sessiondata.NodeUserSessionDataOverride,
+			"DELETE FROM system.job_info WHERE job_id = $1 AND info_key::string = ANY($2)",
+			j.ID(), keyArray,
+		); err != nil {
+			return err
+		}
+		if len(tuples) == 0 {
+			return nil
+		}
+		_, err := txn.ExecEx(
+			ctx, "write-job-info-insert-keys", txn.KV(),
+			sessiondata.NodeUserSessionDataOverride,
+			"INSERT INTO system.job_info (job_id, info_key, written, value) VALUES "+
+				strings.Join(tuples, ", "),
+			params...,
+		)
+		return err
+	})
+}
+
 // DeleteRange removes the info records between the provided
 // start key (inclusive) and end key (exclusive).
 func (i InfoStorage) DeleteRange(
@@ -308,7 +416,10 @@ func (i InfoStorage) Count(ctx context.Context, startInfoKey, endInfoKey string)
 	return int(*value), nil
 }
 
-// Iterate iterates though the info records for a given job and info key prefix.
+// Iterate iterates though the info records for a given job and info key prefix,
+// in order of their keys, calling fn with the latest value of each key. An
+// empty prefix iterates over all of the job's info records, e.g. to export
+// its complete state.
 func (i InfoStorage) Iterate(
 	ctx context.Context, infoPrefix string, fn func(infoKey string, value []byte) error,
 ) (retErr error) {
@@ -333,6 +444,99 @@ const (
 const (
 	LegacyPayloadKey  = "legacy_payload"
 	LegacyProgressKey = "legacy_progress"
+
+	// JobLogEntryPrefix is the prefix of the info_keys of the entries of the
+	// job's log; see JobUpdater.AppendLogEntry. Iterating over it yields the
+	// entries oldest first, and each value can be decoded with
+	// DecodeJobLogEntry. The values are JSON, so the entries can also be read
+	// from SQL by decoding them as such, e.g.
+	//
+	//   SELECT convert_from(value, 'UTF8')::JSONB FROM system.job_info
+	//    WHERE job_id = $1 AND info_key LIKE 'log_entry_%' ORDER BY info_key
+	//
+	// No crdb_internal virtual table exposes them.
+	JobLogEntryPrefix = "log_entry_"
+
+	// lastUpdatedByKey is the info_key whose value is the ID of the SQL
+	// instance that most recently updated the job.
+	lastUpdatedByKey = "last_updated_by"
+
+	// priorityKey is the info_key whose value is the decimal representation
+	// of the job's adoption priority, if it is not the default of 0.
+	priorityKey = "priority"
+
+	// etaKey is the info_key whose value is the decimal representation of the
+	// estimated time remaining, in nanoseconds, as of the time it was written.
+	etaKey = "eta"
+
+	// runStatsHistoryKey is the info_key whose value is the job's run-stats
+	// history, as encoded by encodeRunStatsHistory.
+	runStatsHistoryKey = "run_stats_history"
+
+	// statusHistoryKey is the info_key whose value is the JSON-encoded list
+	// of the job's most recent status transitions.
+	statusHistoryKey = "status_history"
+
+	// epochKey is the info_key whose value is the decimal representation of
+	// the job's resumption epoch; see WithResumptionEpoch.
+	epochKey = "epoch"
+
+	// switchedToHighWaterKey is the info_key whose presence records that the
+	// job's progress was switched from a fraction to a high-water mark. Its
+	// value is the fraction completed at the time of the switch.
+	switchedToHighWaterKey = "switched_to_high_water"
+
+	// attentionKey is the info_key whose presence flags the job as needing
+	// manual attention. Its value is the reason the job was flagged.
+	attentionKey = "needs_attention"
+
+	// sequenceKey is the info_key whose value is the decimal representation
+	// of the number of updates of the job which wrote anything; see
+	// JobMetadata.Sequence.
+	sequenceKey = "update_sequence"
+
+	// heartbeatKey is the info_key rewritten by Updater.Heartbeat. Only the
+	// time at which it was last written is meaningful.
+	heartbeatKey = "heartbeat"
+
+	// exclusionGroupKey is the info_key whose value is the name of the job's
+	// mutual exclusion group; see Updater.SetExclusionGroup.
+	exclusionGroupKey = "exclusion_group"
+
+	// exclusionGroupHolderKey is the info_key whose value is the ID of the
+	// session under which the job was last resumed while belonging to an
+	// exclusion group. The job holds its group for as long as it is running
+	// or reverting under that same session.
+	exclusionGroupHolderKey = "exclusion_group_holder"
+
+	// infoExpirationPrefix is the prefix of the info_keys whose value is the
+	// time, in decimal nanoseconds since the Unix epoch, at which the info
+	// record with the rest of the key expires; see InfoStorage.WriteWithTTL.
+	infoExpirationPrefix = "expiration/"
+
+	// settingKeyPrefix is the prefix of the info_keys whose value is a job-scoped
+	// setting, keyed by the rest of the key; see InfoStorage.WriteSetting.
+	settingKeyPrefix = "setting/"
+
+	// errorDetailsKey is the info_key whose value is the JSON-encoded
+	// JobErrorDetails of the job; see JobUpdater.SetErrorDetails.
+	errorDetailsKey = "error_details"
+
+	// pauseRequestedAtKey is the info_key whose value is the time, in decimal
+	// nanoseconds since the Unix epoch, at which the pause of the job was last
+	// requested; see Registry.RecoverStuckPauseRequested.
+	pauseRequestedAtKey = "pause_requested_at"
+
+	// progressLineagePrefix is the prefix of the info_keys whose values are the
+	// retained older versions of the job's progress, keyed by the time, in
+	// zero-padded decimal nanoseconds since the Unix epoch, at which they were
+	// written; see jobs.progress_lineage.retained_versions.
+	progressLineagePrefix = "progress_lineage/"
+
+	// checkpointIDKey is the info_key whose value is the decimal representation
+	// of the ID of the checkpoint the job's progress was last written with; see
+	// JobUpdater.UpdateProgressWithCheckpoint.
+	checkpointIDKey = "checkpoint_id"
 )
 
 // GetLegacyPayloadKey returns the info_key whose value is the jobspb.Payload of
@@ -347,13 +551,29 @@ func GetLegacyProgressKey() string {
 	return LegacyProgressKey
 }
 
-// GetLegacyPayload returns the job's Payload from the system.job_info table.
+// GetLegacyPayload returns the job's Payload from the system.job_info table,
+// decompressing it if needed.
 func (i InfoStorage) GetLegacyPayload(ctx context.Context) ([]byte, bool, error) {
-	return i.Get(ctx, LegacyPayloadKey)
+	value, ok, err := i.Get(ctx, LegacyPayloadKey)
+	if err != nil || !ok {
+		return nil, ok, err
+	}
+	payload, err := DecodePayload(value)
+	if err != nil {
+		return nil, false, err
+	}
+	return payload, true, nil
 }
 
-// WriteLegacyPayload writes the job's Payload to the system.job_info table.
+// WriteLegacyPayload writes the job's Payload to the system.job_info table,
+// compressing it if jobs.payload.compression.enabled is set.
 func (i InfoStorage) WriteLegacyPayload(ctx context.Context, payload []byte) error {
+	if r := i.j.registry; r != nil {
+		var err error
+		if payload, err = encodePayload(ctx, r.settings, payload); err != nil {
+			return err
+		}
+	}
 	return i.Write(ctx, LegacyPayloadKey, payload)
 }
 
@@ -366,3 +586,22 @@ func (i InfoStorage) GetLegacyProgress(ctx context.Context) ([]byte, bool, error
 func (i InfoStorage) WriteLegacyProgress(ctx context.Context, progress []byte) error {
 	return i.Write(ctx, LegacyProgressKey, progress)
 }
+
+// settingKey returns the info_key of the job-scoped setting with the given key.
+func settingKey(key string) string {
+	return settingKeyPrefix + key
+}
+
+// ReadSetting returns the value of the job-scoped setting with the given key,
+// as written by WriteSetting, and whether it is set.
+func (i InfoStorage) ReadSetting(ctx context.Context, key string) ([]byte, bool, error) {
+	return i.Get(ctx, settingKey(key))
+}
+
+// WriteSetting sets the job-scoped setting with the given key to value. Such
+// settings are small, mutable pieces of configuration, e.g. a user-adjusted
+// concurrency limit, stored in their own job_info records so that they can be
+// changed without rewriting the job's payload or progress.
+func (i InfoStorage) WriteSetting(ctx context.Context, key string, value []byte) error {
+	return i.Write(ctx, settingKey(key), value)
+}
@@ -13,6 +13,7 @@ package jobs_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/cockroachdb/cockroach/pkg/base"
 	_ "github.com/cockroachdb/cockroach/pkg/ccl/backupccl" // import ccl to be able to run backups
@@ -358,3 +359,153 @@ func TestAccessorsWithWrongSQLLivenessSession(t *testing.T) {
 		})
 	}))
 }
+
+func TestIterateAllJobInfo(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	s := serverutils.StartServerOnly(t, base.TestServerArgs{})
+	ctx := context.Background()
+	defer s.Stopper().Stop(ctx)
+
+	idb := s.InternalDB().(isql.DB)
+	r := s.JobRegistry().(*jobs.Registry)
+	record := jobs.Record{
+		Details:  jobspb.ImportDetails{},
+		Progress: jobspb.ImportProgress{},
+		Username: username.TestUserName(),
+	}
+	job, err := r.CreateJobWithTxn(ctx, record, r.MakeJobID(), nil /* txn */)
+	require.NoError(t, err)
+	other, err := r.CreateJobWithTxn(ctx, record, r.MakeJobID(), nil /* txn */)
+	require.NoError(t, err)
+
+	require.NoError(t, idb.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+		infoStorage := job.InfoStorage(txn)
+		if err := infoStorage.Write(ctx, "custom", []byte("v1")); err != nil {
+			return err
+		}
+		if err := infoStorage.Write(ctx, "custom", []byte("v2")); err != nil {
+			return err
+		}
+		return other.InfoStorage(txn).Write(ctx, "other", []byte("v"))
+	}))
+
+	values := make(map[string][]byte)
+	var keys []string
+	require.NoError(t, idb.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+		return job.InfoStorage(txn).Iterate(ctx, "", func(key string, value []byte) error {
+			keys = append(keys, key)
+			values[key] = value
+			return nil
+		})
+	}))
+	require.IsIncreasing(t, keys)
+	require.Contains(t, values, jobs.LegacyPayloadKey)
+	require.Contains(t, values, jobs.LegacyProgressKey)
+	require.Equal(t, []byte("v2"), values["custom"])
+	require.NotContains(t, values, "other")
+}
+
+func TestWriteJobInfoBatch(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	s := serverutils.StartServerOnly(t, base.TestServerArgs{})
+	ctx := context.Background()
+	defer s.Stopper().Stop(ctx)
+
+	idb := s.InternalDB().(isql.DB)
+	r := s.JobRegistry().(*jobs.Registry)
+	record := jobs.Record{
+		Details:  jobspb.ImportDetails{},
+		Progress: jobspb.ImportProgress{},
+		Username: username.TestUserName(),
+	}
+	job, err := r.CreateJobWithTxn(ctx, record, r.MakeJobID(), nil /* txn */)
+	require.NoError(t, err)
+
+	require.NoError(t, idb.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+		infoStorage := job.InfoStorage(txn)
+		if err := infoStorage.Write(ctx, "partition/1", []byte("old")); err != nil {
+			return err
+		}
+		return infoStorage.Write(ctx, "partition/3", []byte("old"))
+	}))
+	require.NoError(t, idb.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+		return job.InfoStorage(txn).WriteBatch(ctx, map[string][]byte{
+			"partition/1": []byte("v1"),
+			"partition/2": []byte("v2"),
+			"partition/3": nil,
+		})
+	}))
+
+	var values [][]byte
+	require.NoError(t, idb.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+		return job.InfoStorage(txn).Iterate(ctx, "partition/", func(key string, value []byte) error {
+			values = append(values, value)
+			return nil
+		})
+	}))
+	// Older versions of the written keys are replaced and nil values delete.
+	require.Equal(t, [][]byte{[]byte("v1"), []byte("v2")}, values)
+}
+
+func TestDeleteExpiredJobInfo(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	s := serverutils.StartServerOnly(t, base.TestServerArgs{})
+	ctx := context.Background()
+	defer s.Stopper().Stop(ctx)
+
+	idb := s.InternalDB().(isql.DB)
+	r := s.JobRegistry().(*jobs.Registry)
+	record := jobs.Record{
+		Details:  jobspb.ImportDetails{},
+		Progress: jobspb.ImportProgress{},
+		Username: username.TestUserName(),
+	}
+	job, err := r.CreateJobWithTxn(ctx, record, r.MakeJobID(), nil /* txn */)
+	require.NoError(t, err)
+
+	require.NoError(t, idb.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+		infoStorage := job.InfoStorage(txn)
+		if err := infoStorage.WriteWithTTL(ctx, "expired", []byte("v"), -time.Second); err != nil {
+			return err
+		}
+		if err := infoStorage.WriteWithTTL(ctx, "unexpired", []byte("v"), time.Hour); err != nil {
+			return err
+		}
+		// Rewriting a record with Write clears its expiration.
+		if err := infoStorage.WriteWithTTL(ctx, "rewritten", []byte("v"), -time.Second); err != nil {
+			return err
+		}
+		if err := infoStorage.Write(ctx, "rewritten", []byte("v2")); err != nil {
+			return err
+		}
+		return infoStorage.Write(ctx, "permanent", []byte("v"))
+	}))
+
+	require.NoError(t, idb.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+		infoStorage := job.InfoStorage(txn)
+		n, err := infoStorage.DeleteExpired(ctx)
+		if err != nil {
+			return err
+		}
+		require.Equal(t, 1, n)
+		for key, exists := range map[string]bool{
+			"expired":   false,
+			"unexpired": true,
+			"rewritten": true,
+			"permanent": true,
+		} {
+			_, ok, err := infoStorage.Get(ctx, key)
+			if err != nil {
+				return err
+			}
+			require.Equal(t, exists, ok, key)
+		}
+		return nil
+	}))
+}
@@ -0,0 +1,223 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package jobs
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/jobs/jobspb"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/settings"
+	"github.com/cockroachdb/cockroach/pkg/sql/isql"
+	"github.com/cockroachdb/cockroach/pkg/sql/sem/tree"
+	"github.com/cockroachdb/cockroach/pkg/sql/sessiondata"
+	"github.com/cockroachdb/cockroach/pkg/sql/types"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+	"github.com/cockroachdb/errors"
+)
+
+var terminalJobInfoRetention = settings.RegisterDurationSetting(
+	settings.ApplicationLevel,
+	"jobs.info.terminal_retention",
+	"the amount of time after which the info records of finished jobs which are "+
+		"not needed to load them are deleted; if 0, they are kept for as long as "+
+		"the jobs themselves",
+	0,
+	settings.NonNegativeDuration,
+)
+
+// essentialInfoKeys are the info keys needed to load a job, which are kept
+// until the job itself is deleted.
+var essentialInfoKeys = []string{LegacyPayloadKey, LegacyProgressKey}
+
+// WriteWithTTL is like Write, but the info record expires after ttl, after
+// which it is deleted by DeleteExpired. Rewriting the record with Write clears
+// its expiration.
+func (i InfoStorage) WriteWithTTL(
+	ctx context.Context, infoKey string, value []byte, ttl time.Duration,
+) error {
+	if err := i.Write(ctx, infoKey, value); err != nil {
+		return err
+	}
+	expiration := strconv.FormatInt(i.now().Add(ttl).UnixNano(), 10)
+	return i.write(ctx, infoExpirationPrefix+infoKey, []byte(expiration))
+}
+
+// DeleteExpired deletes the job's info records written with WriteWithTTL whose
+// TTL has passed. It returns the number of records deleted.
+func (i InfoStorage) DeleteExpired(ctx context.Context) (int, error) {
+	now := i.now().UnixNano()
+	var expired []string
+	if err := i.Iterate(ctx, infoExpirationPrefix, func(key string, value []byte) error {
+		expiration, err := strconv.ParseInt(string(value), 10, 64)
+		if err != nil {
+			return errors.Wrapf(err, "job %d: invalid expiration of info key %q", i.j.ID(), key)
+		}
+		if expiration <= now {
+			expired = append(expired, strings.TrimPrefix(key, infoExpirationPrefix))
+		}
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	for _, key := range expired {
+		if err := i.Delete(ctx, key); err != nil {
+			return 0, err
+		}
+		if err := i.Delete(ctx, infoExpirationPrefix+key); err != nil {
+			return 0, err
+		}
+	}
+	return len(expired), nil
+}
+
+// cleanupJobInfo deletes the info records whose TTL has passed and, if
+// jobs.info.terminal_retention is set, the info records of jobs which
+// finished before the retention that are not needed to load them.
+func (r *Registry) cleanupJobInfo(ctx context.Context) error {
+	if err := r.deleteExpiredJobInfo(ctx); err != nil {
+		return errors.Wrap(err, "deleting expired job info records")
+	}
+	if retention := terminalJobInfoRetention.Get(&r.settings.SV); retention > 0 {
+		if err := r.deleteTerminalJobInfo(ctx, r.clock.Now().GoTime().Add(-retention)); err != nil {
+			return errors.Wrap(err, "deleting info records of finished jobs")
+		}
+	}
+	return nil
+}
+
+// deleteExpiredJobInfo runs InfoStorage.DeleteExpired for every job with info
+// records written with a TTL. Such jobs are considered in pages of IDs, each
+// in a transaction of its own.
+func (r *Registry) deleteExpiredJobInfo(ctx context.Context) error {
+	const pageQuery = `
+SELECT DISTINCT job_id FROM system.job_info
+ WHERE job_id > $1 AND info_key >= $2 AND info_key < $3
+ ORDER BY job_id
+ LIMIT $4`
+	end := string(roachpb.Key(infoExpirationPrefix).PrefixEnd())
+	var after jobspb.JobID
+	var deleted int
+	for {
+		var page []jobspb.JobID
+		var pageDeleted int
+		if err := r.db.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+			page, pageDeleted = page[:0], 0
+			rows, err := txn.QueryBufferedEx(
+				ctx, "job-info-with-ttl-page", txn.KV(),
+				sessiondata.NodeUserSessionDataOverride,
+				pageQuery, after, infoExpirationPrefix, end, cleanupPageSize,
+			)
+			if err != nil {
+				return err
+			}
+			for _, row := range rows {
+				id := jobspb.JobID(*row[0].(*tree.DInt))
+				n, err := InfoStorage{j: &Job{id: id, registry: r}, txn: txn}.DeleteExpired(ctx)
+				if err != nil {
+					return errors.Wrapf(err, "job %d", id)
+				}
+				pageDeleted += n
+				page = append(page, id)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		deleted += pageDeleted
+		if len(page) < cleanupPageSize {
+			break
+		}
+		after = page[len(page)-1]
+	}
+	if deleted > 0 {
+		log.Infof(ctx, "deleted %d expired job info records", deleted)
+	}
+	return nil
+}
+
+// deleteTerminalJobInfo deletes the info records which are not needed to load
+// the jobs, i.e. all but the essentialInfoKeys, of the jobs which finished
+// before finishedBefore. Finished jobs are considered in pages of IDs.
+func (r *Registry) deleteTerminalJobInfo(ctx context.Context, finishedBefore time.Time) error {
+	const pageQuery = `
+SELECT id FROM system.jobs
+ WHERE status IN ('` + string(StatusSucceeded) + `', '` + string(StatusFailed) + `', '` +
+		string(StatusCanceled) + `') AND id > $1
+ ORDER BY id
+ LIMIT $2`
+	essential := tree.NewDArray(types.String)
+	for _, key := range essentialInfoKeys {
+		_ = essential.Append(tree.NewDString(key))
+	}
+	finishedMicros := timeutil.ToUnixMicros(finishedBefore)
+	var after jobspb.JobID
+	var deleted int
+	for {
+		var page []jobspb.JobID
+		var pageDeleted int
+		if err := r.db.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+			page, pageDeleted = page[:0], 0
+			rows, err := txn.QueryBufferedEx(
+				ctx, "finished-jobs-page", txn.KV(),
+				sessiondata.NodeUserSessionDataOverride,
+				pageQuery, after, cleanupPageSize,
+			)
+			if err != nil {
+				return err
+			}
+			if len(rows) == 0 {
+				return nil
+			}
+			ids := make([]jobspb.JobID, 0, len(rows))
+			for _, row := range rows {
+				ids = append(ids, jobspb.JobID(*row[0].(*tree.DInt)))
+			}
+			page = append(page, ids...)
+			mds, err := loadMetadataBatch(ctx, txn, ids)
+			if err != nil {
+				return err
+			}
+			toClean := tree.NewDArray(types.Int)
+			for _, id := range ids {
+				if md := mds[id]; md.Status.Terminal() && md.Payload.FinishedMicros < finishedMicros {
+					_ = toClean.Append(tree.NewDInt(tree.DInt(id)))
+				}
+			}
+			if len(toClean.Array) == 0 {
+				return nil
+			}
+			n, err := txn.ExecEx(
+				ctx, "delete-finished-job-info", txn.KV(),
+				sessiondata.NodeUserSessionDataOverride,
+				`DELETE FROM system.job_info WHERE job_id = ANY($1) AND NOT (info_key = ANY($2))`,
+				toClean, essential,
+			)
+			pageDeleted = n
+			return err
+		}); err != nil {
+			return err
+		}
+		deleted += pageDeleted
+		if len(page) < cleanupPageSize {
+			break
+		}
+		after = page[len(page)-1]
+	}
+	if deleted > 0 {
+		log.Infof(ctx, "deleted %d info records of finished jobs", deleted)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package jobs
+
+import (
+	"context"
+	gojson "encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/settings"
+	"github.com/cockroachdb/cockroach/pkg/sql/isql"
+	"github.com/cockroachdb/cockroach/pkg/util"
+	"github.com/cockroachdb/errors"
+)
+
+var jobLogMaxEntries = settings.RegisterIntSetting(
+	settings.ApplicationLevel,
+	"jobs.log.max_entries",
+	"the maximum number of structured log entries retained for each job; "+
+		"the oldest entries are dropped beyond it",
+	100,
+	settings.NonNegativeInt,
+)
+
+// maxJobLogMessageRunes is the length, in runes, beyond which the message of
+// a job log entry is truncated.
+const maxJobLogMessageRunes = 1024
+
+// JobLogEntry is an entry of a job's structured log.
+type JobLogEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	// Level is the severity of the entry, e.g. INFO, WARNING or ERROR.
+	Level   string `json:"level"`
+	Message string `json:"message"`
+}
+
+// DecodeJobLogEntry decodes the value of a job_info record whose key has the
+// JobLogEntryPrefix.
+func DecodeJobLogEntry(value []byte) (JobLogEntry, error) {
+	var e JobLogEntry
+	if err := gojson.Unmarshal(value, &e); err != nil {
+		return JobLogEntry{}, errors.Wrap(err, "decoding job log entry")
+	}
+	return e, nil
+}
+
+// jobLogEntryKey returns the info key of a log entry written at ts. The
+// timestamp is zero-padded so that keys sort in time order, and seq
+// distinguishes entries with the same timestamp.
+func jobLogEntryKey(ts time.Time, seq int) string {
+	return fmt.Sprintf("%s%019d_%04d", JobLogEntryPrefix, ts.UnixNano(), seq)
+}
+
+// AppendLogEntry appends entry to the job's log when the update is persisted.
+// The entry is timestamped with the current time if its Timestamp is unset,
+// and overly long messages are truncated. Only the most recent
+// jobs.log.max_entries entries are retained.
+func (ju *JobUpdater) AppendLogEntry(entry JobLogEntry) error {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = ju.now()
+	}
+	entry.Message = util.TruncateString(entry.Message, maxJobLogMessageRunes)
+	value, err := gojson.Marshal(entry)
+	if err != nil {
+		return errors.Wrap(err, "encoding job log entry")
+	}
+	ju.writeInfo(jobLogEntryKey(entry.Timestamp, ju.numLogEntries), value)
+	ju.numLogEntries++
+	return nil
+}
+
+// trimJobLog drops the oldest entries of the job's log beyond
+// jobs.log.max_entries.
+func trimJobLog(ctx context.Context, infoStorage InfoStorage, sv *settings.Values) error {
+	maxEntries := int(jobLogMaxEntries.Get(sv))
+	end := string(roachpb.Key(JobLogEntryPrefix).PrefixEnd())
+	n, err := infoStorage.Count(ctx, JobLogEntryPrefix, end)
+	if err != nil {
+		return err
+	}
+	if n <= maxEntries {
+		return nil
+	}
+	return infoStorage.DeleteRange(ctx, JobLogEntryPrefix, end, n-maxEntries)
+}
+
+// LogEntries returns the entries of the job's log, oldest first.
+func (j *Job) LogEntries(ctx context.Context) ([]JobLogEntry, error) {
+	var entries []JobLogEntry
+	if err := j.registry.db.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+		entries = entries[:0]
+		return j.InfoStorage(txn).Iterate(ctx, JobLogEntryPrefix, func(_ string, value []byte) error {
+			e, err := DecodeJobLogEntry(value)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, e)
+			return nil
+		})
+	}); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
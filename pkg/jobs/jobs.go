@@ -15,13 +15,17 @@ import (
 	"context"
 	gojson "encoding/json"
 	"fmt"
+	"math"
 	"reflect"
+	"strconv"
 	"sync/atomic"
 	"time"
 
+	"github.com/cockroachdb/cockroach/pkg/base"
 	"github.com/cockroachdb/cockroach/pkg/build"
 	"github.com/cockroachdb/cockroach/pkg/jobs/jobspb"
 	"github.com/cockroachdb/cockroach/pkg/kv"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/security/username"
 	"github.com/cockroachdb/cockroach/pkg/settings/cluster"
 	"github.com/cockroachdb/cockroach/pkg/sql/catalog/descpb"
@@ -32,6 +36,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/sql/sqlliveness"
 	"github.com/cockroachdb/cockroach/pkg/sql/types"
 	"github.com/cockroachdb/cockroach/pkg/util"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
 	"github.com/cockroachdb/cockroach/pkg/util/json"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
 	"github.com/cockroachdb/cockroach/pkg/util/protoutil"
@@ -54,14 +59,28 @@ type Job struct {
 
 	id        jobspb.JobID
 	createdBy *CreatedByInfo
-	session   sqlliveness.Session
 	mu        struct {
 		syncutil.Mutex
+		// session is the session the job was claimed with, if any; see
+		// Updater.ClaimAndCheckpoint.
+		session  sqlliveness.Session
 		payload  jobspb.Payload
 		progress jobspb.Progress
 		status   Status
 		runStats *RunStats
+		// epoch is the resumption epoch of the job as of its adoption by this
+		// registry, or its latest BumpEpoch.
+		epoch int64
+		// lastFractionWrite is the time and fraction of the latest progress
+		// written by RateLimitedFractionProgressed.
+		lastFractionWrite struct {
+			at       time.Time
+			fraction float32
+		}
 	}
+
+	// asyncProgress buffers progress written through AsyncProgress.
+	asyncProgress asyncProgressWriter
 }
 
 // CreatedByInfo encapsulates the type and the ID of the system which created
@@ -226,6 +245,42 @@ func (s Status) Terminal() bool {
 	return s == StatusFailed || s == StatusSucceeded || s == StatusCanceled || s == StatusRevertFailed
 }
 
+// validStatusTransitions lists, for each status, the statuses a job in that
+// status may be transitioned to by an update. Terminal statuses have no
+// outgoing transitions. Transitions made by the adoption loop directly, such
+// as from pause-requested to paused, are listed too.
+var validStatusTransitions = map[Status][]Status{
+	StatusPending: {
+		StatusRunning, StatusPauseRequested, StatusCancelRequested, StatusReverting,
+		StatusSucceeded, StatusFailed,
+	},
+	StatusRunning: {
+		StatusPaused, StatusPauseRequested, StatusCancelRequested, StatusReverting,
+		StatusSucceeded, StatusFailed,
+	},
+	StatusPauseRequested:  {StatusPaused, StatusFailed},
+	StatusPaused:          {StatusRunning, StatusReverting, StatusCancelRequested, StatusFailed},
+	StatusCancelRequested: {StatusReverting, StatusFailed},
+	StatusReverting: {
+		StatusPauseRequested, StatusCanceled, StatusFailed, StatusRevertFailed,
+	},
+}
+
+// IsValidStatusTransition returns whether a job may be transitioned from one
+// status to another. Transitioning a job to its current status is always
+// valid, and a no-op.
+func IsValidStatusTransition(from, to Status) bool {
+	if from == to {
+		return true
+	}
+	for _, s := range validStatusTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
 // ID returns the ID of the job.
 func (j *Job) ID() jobspb.JobID {
 	return j.id
@@ -233,7 +288,19 @@ func (j *Job) ID() jobspb.JobID {
 
 // Session returns the underlying sqlliveness.Session associated with the job.
 func (j *Job) Session() sqlliveness.Session {
-	return j.session
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.mu.session
+}
+
+// Epoch returns the resumption epoch of the job as of its adoption by this
+// registry, or 0 if the job was not adopted by this registry or its type was
+// not registered WithResumptionEpoch. Resumers may fence their writes on it
+// using Updater.WithEpoch.
+func (j *Job) Epoch() int64 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.mu.epoch
 }
 
 // CreatedBy returns name/id of this job creator.  This will be nil if this information
@@ -283,6 +350,43 @@ func (u Updater) started(ctx context.Context) error {
 	})
 }
 
+// backoffDelay computes the delay before a job that has been run numRuns
+// times may be run again, mirroring NextRunClause. It also returns whether
+// the delay was capped at maxDelay.
+func backoffDelay(numRuns int, initialDelay, maxDelay time.Duration) (time.Duration, bool) {
+	if numRuns > 62 {
+		numRuns = 62
+	}
+	delay := float64(initialDelay) * (math.Pow(2, float64(numRuns)) - 1)
+	if delay < 0 || delay >= float64(maxDelay) {
+		return maxDelay, true
+	}
+	return time.Duration(delay), false
+}
+
+// IncrementRunStatsReportingDeadline increments the number of runs of the job
+// and sets its last run to now, like a job's adoption does. It returns the
+// time at which the job may next be run given the exponential backoff
+// parameters, and whether the backoff has saturated at maxDelay, allowing
+// callers to alert on jobs that are retried with maximal delay.
+func (u Updater) IncrementRunStatsReportingDeadline(
+	ctx context.Context, initialDelay, maxDelay time.Duration,
+) (nextRun time.Time, saturated bool, _ error) {
+	err := u.Update(ctx, func(_ isql.Txn, md JobMetadata, ju *JobUpdater) error {
+		now := u.now()
+		numRuns := md.RunStats.NumRuns + 1
+		ju.UpdateRunStats(numRuns, now)
+		var delay time.Duration
+		delay, saturated = backoffDelay(numRuns, initialDelay, maxDelay)
+		nextRun = now.Add(delay)
+		return nil
+	})
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return nextRun, saturated, nil
+}
+
 // CheckStatus verifies the status of the job and returns an error if the job's
 // status isn't Running or Reverting.
 func (u Updater) CheckStatus(ctx context.Context) error {
@@ -299,8 +403,7 @@ func (u Updater) RunningStatus(ctx context.Context, runningStatus RunningStatus)
 		if err := md.CheckRunningOrReverting(); err != nil {
 			return err
 		}
-		md.Progress.RunningStatus = string(runningStatus)
-		ju.UpdateProgress(md.Progress)
+		ju.UpdateRunningStatus(string(runningStatus))
 		return nil
 	})
 }
@@ -365,6 +468,26 @@ func (u Updater) FractionProgressed(ctx context.Context, progressedFn FractionPr
 	})
 }
 
+// ClearProgress replaces the progress of the tracked job with an empty one,
+// which only retains the kind of the job's progress details. It is an escape
+// hatch for jobs whose progress grew too large to be loaded efficiently, e.g.
+// due to bad checkpoint data; the job restarts its work from scratch if it is
+// resumed. It fails on jobs in a terminal status.
+func (u Updater) ClearProgress(ctx context.Context) error {
+	return u.Update(ctx, func(_ isql.Txn, md JobMetadata, ju *JobUpdater) error {
+		if md.Status.Terminal() {
+			return &InvalidStatusError{md.ID, md.Status, "clear progress of", md.Payload.Error}
+		}
+		progress := &jobspb.Progress{}
+		if details := md.Progress.UnwrapDetails(); details != nil {
+			progress.Details = jobspb.WrapProgressDetails(
+				reflect.Zero(reflect.TypeOf(details)).Interface().(jobspb.ProgressDetails))
+		}
+		ju.UpdateProgress(progress)
+		return nil
+	})
+}
+
 // CancelRequested sets the status of the tracked job to cancel-requested. It
 // does not directly cancel the job; like job.Paused, it expects the job to call
 // job.Progressed soon, observe a "job is cancel-requested" error, and abort.
@@ -395,6 +518,112 @@ func (u Updater) PauseRequestedWithFunc(
 	})
 }
 
+// getEpoch returns the resumption epoch stored for the job, or 0 if there is
+// none.
+func getEpoch(ctx context.Context, infoStorage InfoStorage) (int64, error) {
+	value, ok, err := infoStorage.get(ctx, epochKey)
+	if err != nil || !ok {
+		return 0, err
+	}
+	epoch, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "job %d: invalid epoch", infoStorage.j.ID())
+	}
+	return epoch, nil
+}
+
+// bumpEpoch increments the resumption epoch stored for the job and returns
+// the new epoch.
+func bumpEpoch(ctx context.Context, infoStorage InfoStorage) (int64, error) {
+	epoch, err := getEpoch(ctx, infoStorage)
+	if err != nil {
+		return 0, err
+	}
+	epoch++
+	return epoch, infoStorage.write(ctx, epochKey, []byte(strconv.FormatInt(epoch, 10)))
+}
+
+// BumpEpoch increments the resumption epoch of the job, fencing off writes
+// made through Updaters created with WithEpoch for an earlier epoch, and
+// returns the new epoch.
+func (u Updater) BumpEpoch(ctx context.Context) (int64, error) {
+	var epoch int64
+	if err := u.Update(ctx, func(txn isql.Txn, _ JobMetadata, ju *JobUpdater) error {
+		current, err := getEpoch(ctx, u.j.InfoStorage(txn))
+		if err != nil {
+			return err
+		}
+		epoch = current + 1
+		ju.writeInfo(epochKey, []byte(strconv.FormatInt(epoch, 10)))
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	u.j.mu.Lock()
+	defer u.j.mu.Unlock()
+	u.j.mu.epoch = epoch
+	return epoch, nil
+}
+
+// CheckpointWithFence writes progress as the job's progress if, and only if,
+// the job's resumption epoch is still expectedEpoch, checking the epoch and
+// writing the progress in the same transaction. This keeps a resumer that has
+// been superseded by a later adoption from checkpointing over the progress of
+// its successor. Unlike WithEpoch, an expectedEpoch of zero is checked too. It
+// returns false, and no error, if the epoch has moved on.
+func (u Updater) CheckpointWithFence(
+	ctx context.Context, progress *jobspb.Progress, expectedEpoch int64,
+) (written bool, _ error) {
+	err := u.Update(ctx, func(txn isql.Txn, md JobMetadata, ju *JobUpdater) error {
+		written = false
+		if err := md.CheckRunningOrReverting(); err != nil {
+			return err
+		}
+		epoch, err := getEpoch(ctx, u.j.InfoStorage(txn))
+		if err != nil {
+			return err
+		}
+		if epoch != expectedEpoch {
+			log.Infof(ctx, "job %d: not checkpointing progress of epoch %d in epoch %d",
+				md.ID, expectedEpoch, epoch)
+			return nil
+		}
+		ju.UpdateProgress(progress)
+		written = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return written, nil
+}
+
+// PauseIf evaluates predicate against the job's current metadata and, if it
+// returns true, sets the status of the job to pause-requested with the reason
+// returned by the predicate. The check and the transition happen in the same
+// transaction. It returns whether the job was transitioned; a job that is
+// already pause-requested or paused is left alone.
+func (u Updater) PauseIf(
+	ctx context.Context, predicate func(md JobMetadata) (bool, string),
+) (paused bool, _ error) {
+	err := u.Update(ctx, func(txn isql.Txn, md JobMetadata, ju *JobUpdater) error {
+		paused = false
+		if md.Status == StatusPauseRequested || md.Status == StatusPaused {
+			return nil
+		}
+		pause, reason := predicate(md)
+		if !pause {
+			return nil
+		}
+		if err := ju.PauseRequested(ctx, txn, md, reason); err != nil {
+			return err
+		}
+		paused = true
+		return nil
+	})
+	return paused, err
+}
+
 // reverted sets the status of the tracked job to reverted.
 func (u Updater) reverted(
 	ctx context.Context, err error, fn func(context.Context, isql.Txn) error,
@@ -441,12 +670,12 @@ func (u Updater) reverted(
 			// We should reset the exponential backoff parameters if the job was not
 			// canceled. Note that md.Status will be StatusReverting if the job
 			// was canceled.
-			numRuns := md.RunStats.NumRuns + 1
 			if md.Status != StatusReverting {
 				// Reset the number of runs to speed up reverting.
-				numRuns = 1
+				ju.ResetNumRuns(1, u.now())
+			} else {
+				ju.UpdateRunStats(md.RunStats.NumRuns+1, u.now())
 			}
-			ju.UpdateRunStats(numRuns, u.now())
 		}
 		if traceID != 0 && md.Progress != nil && md.Progress.TraceID != traceID {
 			md.Progress.TraceID = traceID
@@ -486,20 +715,79 @@ func (u Updater) failed(ctx context.Context, err error) error {
 		// acceptable depending on the job.
 		ju.UpdateStatus(StatusFailed)
 
-		// Truncate all errors to avoid large rows in the jobs
-		// table.
-		const (
-			jobErrMaxRuneCount    = 1024
-			jobErrTruncatedMarker = " -- TRUNCATED"
-		)
-		errStr := err.Error()
-		if len(errStr) > jobErrMaxRuneCount {
-			errStr = util.TruncateString(errStr, jobErrMaxRuneCount) + jobErrTruncatedMarker
+		md.Payload.Error = truncatedJobError(err)
+
+		md.Payload.FinishedMicros = timeutil.ToUnixMicros(u.now())
+		ju.UpdatePayload(md.Payload)
+		return nil
+	})
+}
+
+// truncatedJobError returns the message of err truncated to avoid large rows in
+// the jobs table.
+func truncatedJobError(err error) string {
+	const (
+		jobErrMaxRuneCount    = 1024
+		jobErrTruncatedMarker = " -- TRUNCATED"
+	)
+	errStr := err.Error()
+	if len(errStr) > jobErrMaxRuneCount {
+		errStr = util.TruncateString(errStr, jobErrMaxRuneCount) + jobErrTruncatedMarker
+	}
+	return errStr
+}
+
+// Failed records the failure of the tracked job with the given cause in a
+// single transaction. Jobs which have yet to revert, i.e. which can be moved
+// to StatusReverting, are moved there so that their resumer's OnFailOrCancel
+// cleans up after them, with their number of runs reset to speed the revert
+// up. Other jobs, such as reverting ones, are moved to StatusFailed, with their
+// finished time stamped and their number of runs incremented. In both cases,
+// cause is recorded as the job's error. An InvalidStatusError is returned if
+// the job can be moved to neither status.
+func (u Updater) Failed(ctx context.Context, cause error) error {
+	return u.Update(ctx, func(_ isql.Txn, md JobMetadata, ju *JobUpdater) error {
+		status := StatusFailed
+		if md.Status != StatusReverting && IsValidStatusTransition(md.Status, StatusReverting) {
+			status = StatusReverting
+		}
+		if !IsValidStatusTransition(md.Status, status) || md.Status == status {
+			return &InvalidStatusError{md.ID, md.Status, "fail", md.Payload.Error}
+		}
+		now := u.now()
+		md.Payload.Error = truncatedJobError(cause)
+		if status == StatusReverting {
+			encodedErr := errors.EncodeError(ctx, cause)
+			md.Payload.FinalResumeError = &encodedErr
+			ju.ResetNumRuns(1, now)
+		} else {
+			md.Payload.FinishedMicros = timeutil.ToUnixMicros(now)
+			var numRuns int
+			if md.RunStats != nil {
+				numRuns = md.RunStats.NumRuns
+			}
+			ju.UpdateRunStats(numRuns+1, now)
 		}
-		md.Payload.Error = errStr
+		ju.UpdatePayload(md.Payload)
+		ju.UpdateStatus(status)
+		return nil
+	})
+}
 
+// Succeeded moves the tracked job from StatusRunning to StatusSucceeded in a
+// single transaction, stamping its finished time and clearing any error left
+// in its payload by an earlier, retried run. Unlike the resumer-driven path,
+// it leaves the job's progress as is. An InvalidStatusError is returned if the
+// job is not running.
+func (u Updater) Succeeded(ctx context.Context) error {
+	return u.Update(ctx, func(_ isql.Txn, md JobMetadata, ju *JobUpdater) error {
+		if md.Status != StatusRunning {
+			return &InvalidStatusError{md.ID, md.Status, "succeed", md.Payload.Error}
+		}
 		md.Payload.FinishedMicros = timeutil.ToUnixMicros(u.now())
+		md.Payload.Error = ""
 		ju.UpdatePayload(md.Payload)
+		ju.UpdateStatus(StatusSucceeded)
 		return nil
 	})
 }
@@ -541,13 +829,180 @@ func (u Updater) succeeded(ctx context.Context, fn func(context.Context, isql.Tx
 				return err
 			}
 		}
-		ju.UpdateStatus(StatusSucceeded)
-		md.Payload.FinishedMicros = timeutil.ToUnixMicros(u.j.registry.clock.Now().GoTime())
-		ju.UpdatePayload(md.Payload)
-		md.Progress.Progress = &jobspb.Progress_FractionCompleted{
-			FractionCompleted: 1.0,
+		u.markSucceeded(md, ju)
+		return nil
+	})
+}
+
+// markSucceeded records the transition of the job to succeeded in ju.
+func (u Updater) markSucceeded(md JobMetadata, ju *JobUpdater) {
+	ju.UpdateStatus(StatusSucceeded)
+	md.Payload.FinishedMicros = timeutil.ToUnixMicros(u.j.registry.clock.Now().GoTime())
+	ju.UpdatePayload(md.Payload)
+	md.Progress.Progress = &jobspb.Progress_FractionCompleted{
+		FractionCompleted: 1.0,
+	}
+	ju.UpdateProgress(md.Progress)
+}
+
+// CompleteIfFullyProgressed marks the job as succeeded, provided that its
+// stored fraction completed is at least 1.0. Otherwise, an error wrapping
+// ErrIncompleteProgress is returned and the job is left unchanged. Jobs which
+// track a high-water mark must use CompleteIfHighWaterReached instead.
+func (u Updater) CompleteIfFullyProgressed(ctx context.Context) error {
+	return u.completeIf(ctx, func(p *jobspb.Progress) error {
+		if hw := p.GetHighWater(); hw != nil {
+			return errors.Wrapf(ErrIncompleteProgress,
+				"job tracks a high-water mark (%s) rather than a fraction completed", hw)
 		}
+		if f := p.GetFractionCompleted(); f < 1.0 {
+			return errors.Wrapf(ErrIncompleteProgress, "fraction completed %f is less than 1.0", f)
+		}
+		return nil
+	})
+}
+
+// CompleteIfHighWaterReached is like CompleteIfFullyProgressed, for jobs
+// which track a high-water mark: the job is only marked as succeeded if its
+// stored high-water mark is at or above target.
+func (u Updater) CompleteIfHighWaterReached(ctx context.Context, target hlc.Timestamp) error {
+	return u.completeIf(ctx, func(p *jobspb.Progress) error {
+		hw := p.GetHighWater()
+		if hw == nil || hw.Less(target) {
+			return errors.Wrapf(ErrIncompleteProgress,
+				"high-water mark %s has not reached target %s", hw, target)
+		}
+		return nil
+	})
+}
+
+func (u Updater) completeIf(ctx context.Context, check func(*jobspb.Progress) error) error {
+	return u.Update(ctx, func(txn isql.Txn, md JobMetadata, ju *JobUpdater) error {
+		if md.Status == StatusSucceeded {
+			return nil
+		}
+		if md.Status != StatusRunning && md.Status != StatusPending {
+			return errors.Errorf("job with status %s cannot be marked as succeeded", md.Status)
+		}
+		if err := check(md.Progress); err != nil {
+			return err
+		}
+		u.markSucceeded(md, ju)
+		return nil
+	})
+}
+
+// SwitchToHighWater replaces the fraction-based progress of the job with a
+// high-water mark of initial, for jobs that move from a bounded phase to an
+// unbounded, streaming one. The job's status is left unchanged. The switch is
+// recorded, along with the fraction completed at the time, so that it can be
+// told apart from a progress update that accidentally changed the kind of
+// progress; see SwitchedToHighWater.
+func (u Updater) SwitchToHighWater(ctx context.Context, initial hlc.Timestamp) error {
+	return u.Update(ctx, func(txn isql.Txn, md JobMetadata, ju *JobUpdater) error {
+		if err := md.CheckRunningOrReverting(); err != nil {
+			return err
+		}
+		if _, ok := md.Progress.Progress.(*jobspb.Progress_HighWater); ok {
+			return errors.Errorf("job %d already tracks a high-water mark", md.ID)
+		}
+		fraction := md.Progress.GetFractionCompleted()
+		md.Progress.Progress = &jobspb.Progress_HighWater{HighWater: &initial}
 		ju.UpdateProgress(md.Progress)
+		ju.writeInfo(switchedToHighWaterKey,
+			[]byte(strconv.FormatFloat(float64(fraction), 'f', -1, 32)))
+		log.Infof(ctx, "job %d: switched from fraction completed %f to high-water mark %s",
+			md.ID, fraction, initial)
+		return nil
+	})
+}
+
+// UpdateHighwaterProgressed updates the job's progress with the new high-water
+// mark, which may not be below the job's current high-water mark.
+func UpdateHighwaterProgressed(highWater hlc.Timestamp, md JobMetadata, ju *JobUpdater) error {
+	return UpdateHighwaterProgressedWithOpts(highWater, md, ju, false /* allowRegression */)
+}
+
+// UpdateHighwaterProgressedWithOpts is like UpdateHighwaterProgressed, but
+// lets the high-water mark move backwards if allowRegression is set.
+// Otherwise, an attempt to do so fails with ErrHighWaterRegression.
+func UpdateHighwaterProgressedWithOpts(
+	highWater hlc.Timestamp, md JobMetadata, ju *JobUpdater, allowRegression bool,
+) error {
+	if err := md.CheckRunningOrReverting(); err != nil {
+		return err
+	}
+	if highWater.Less(hlc.Timestamp{}) {
+		return errors.Errorf("high-water %s is outside allowable range > 0.0", highWater)
+	}
+	existing := md.Progress.GetHighWater()
+	if existing != nil && highWater.Less(*existing) && !allowRegression {
+		return errors.Wrapf(ErrHighWaterRegression, "from %s to %s", *existing, highWater)
+	}
+	md.Progress.Progress = &jobspb.Progress_HighWater{
+		HighWater: &highWater,
+	}
+	ju.UpdateProgress(md.Progress)
+	return nil
+}
+
+// FlagForAttention flags the job as needing manual intervention for the given
+// reason, without otherwise affecting it. Flagged jobs are listed by
+// Registry.JobsNeedingAttention until the flag is cleared with
+// ClearAttentionFlag or they reach a terminal status. Flagging an already flagged job replaces its reason.
+func (u Updater) FlagForAttention(ctx context.Context, reason string) error {
+	return u.Update(ctx, func(_ isql.Txn, md JobMetadata, ju *JobUpdater) error {
+		ju.writeInfo(attentionKey, []byte(reason))
+		log.Infof(ctx, "job %d: flagged for attention: %s", md.ID, reason)
+		return nil
+	})
+}
+
+// UpdateSetting sets the job-scoped setting with the given key to value in an
+// update of the job; see InfoStorage.WriteSetting. Callers that need to change
+// the job's metadata along with the setting should use JobUpdater.UpdateSetting
+// in their UpdateFn instead. A nil value clears the setting.
+func (u Updater) UpdateSetting(ctx context.Context, key string, value []byte) error {
+	return u.Update(ctx, func(_ isql.Txn, _ JobMetadata, ju *JobUpdater) error {
+		ju.UpdateSetting(key, value)
+		return nil
+	})
+}
+
+// ClearAttentionFlag clears the flag set by FlagForAttention, if any.
+func (u Updater) ClearAttentionFlag(ctx context.Context) error {
+	return u.Update(ctx, func(_ isql.Txn, _ JobMetadata, ju *JobUpdater) error {
+		ju.writeInfo(attentionKey, nil)
+		return nil
+	})
+}
+
+// Heartbeat records that the job is alive without touching its payload or
+// progress, allowing a job that is running but not making progress to be told
+// apart from one that is no longer running at all; see LastHeartbeat. Like any
+// other update, it fails if the job's claim has been lost.
+func (u Updater) Heartbeat(ctx context.Context) error {
+	return u.Update(ctx, func(_ isql.Txn, md JobMetadata, ju *JobUpdater) error {
+		if err := md.CheckRunningOrReverting(); err != nil {
+			return err
+		}
+		ju.writeInfo(heartbeatKey, []byte{})
+		return nil
+	})
+}
+
+// SetExclusionGroup places the job in the named mutual exclusion group: the
+// registry does not resume a job while another job of the same group is
+// running, leaving it queued until the group is free. An empty group removes
+// the job from its group. The group only takes effect on the job's next
+// resumption.
+func (u Updater) SetExclusionGroup(ctx context.Context, group string) error {
+	return u.Update(ctx, func(_ isql.Txn, _ JobMetadata, ju *JobUpdater) error {
+		if group == "" {
+			ju.writeInfo(exclusionGroupKey, nil)
+		} else {
+			ju.writeInfo(exclusionGroupKey, []byte(group))
+		}
 		return nil
 	})
 }
@@ -576,6 +1031,220 @@ func (u Updater) SetProgress(ctx context.Context, details interface{}) error {
 	})
 }
 
+// SetPriority sets the adoption priority of the job, which must not be
+// negative. Unclaimed jobs with a higher priority are adopted first; see
+// claimQuery. Jobs default to priority 0.
+func (u Updater) SetPriority(ctx context.Context, p int) error {
+	if p < 0 {
+		return errors.Newf("job priority %d is negative", p)
+	}
+	return u.Update(ctx, func(_ isql.Txn, _ JobMetadata, ju *JobUpdater) error {
+		var value []byte
+		if p != 0 {
+			value = []byte(strconv.Itoa(p))
+		}
+		ju.writeInfo(priorityKey, value)
+		return nil
+	})
+}
+
+// RequireBinaryVersion prevents the job from being resumed by nodes running a
+// binary older than v, for instance because its payload was written in a
+// format that older binaries do not understand. The version is recorded in the
+// job's payload, and nodes running an older binary which claim the job release
+// their claim after loading it, leaving it for a node running a newer binary.
+func (u Updater) RequireBinaryVersion(ctx context.Context, v roachpb.Version) error {
+	return u.Update(ctx, func(_ isql.Txn, md JobMetadata, ju *JobUpdater) error {
+		md.Payload.MinBinaryVersion = &v
+		ju.UpdatePayload(md.Payload)
+		return nil
+	})
+}
+
+// UpdateProgressIfClaimLive writes the given progress only if the job is still
+// claimed by a live session, which must be the job's own session if it has
+// one. If the claim has been lost, nothing is written and false is returned
+// without an error, allowing resumers to cheaply stop checkpointing once they
+// no longer own the job.
+func (u Updater) UpdateProgressIfClaimLive(
+	ctx context.Context, progress *jobspb.Progress,
+) (written bool, err error) {
+	if u.txn == nil {
+		err = u.internalDB().Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+			u.txn = txn
+			written, err = u.UpdateProgressIfClaimLive(ctx, progress)
+			return err
+		})
+		return written, err
+	}
+	row, err := u.txn.QueryRowEx(
+		ctx, "check-claim-live", u.txn.KV(),
+		sessiondata.NodeUserSessionDataOverride,
+		`SELECT claim_session_id, crdb_internal.sql_liveness_is_alive(claim_session_id)
+FROM system.jobs WHERE id = $1 AND claim_session_id IS NOT NULL`,
+		u.j.ID(),
+	)
+	if err != nil {
+		return false, errors.Wrapf(err, "job %d", u.j.ID())
+	}
+	if row == nil || !bool(tree.MustBeDBool(row[1])) {
+		return false, nil
+	}
+	if s := u.j.Session(); s != nil &&
+		!bytes.Equal([]byte(tree.MustBeDBytes(row[0])), s.ID().UnsafeBytes()) {
+		return false, nil
+	}
+	if err := u.update(ctx, func(_ isql.Txn, _ JobMetadata, ju *JobUpdater) error {
+		ju.UpdateProgress(progress)
+		return nil
+	}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ClaimState describes who, if anyone, owns a job.
+type ClaimState int
+
+const (
+	// ClaimStateUnclaimed is the state of a job with no claim_session_id.
+	ClaimStateUnclaimed ClaimState = iota
+	// ClaimStateDeadSession is the state of a job claimed by a session which is
+	// no longer alive. Such jobs are orphaned and can be adopted.
+	ClaimStateDeadSession
+	// ClaimStateLiveSession is the state of a job claimed by a live session,
+	// which should be left alone.
+	ClaimStateLiveSession
+)
+
+// ClaimState loads the job's claim and checks the liveness of the session
+// holding it, if any. It only reads the job.
+func (u Updater) ClaimState(ctx context.Context) (state ClaimState, err error) {
+	if u.txn == nil {
+		err = u.internalDB().Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+			u.txn = txn
+			state, err = u.ClaimState(ctx)
+			return err
+		})
+		return state, err
+	}
+	row, err := u.txn.QueryRowEx(
+		ctx, "load-claim-state", u.txn.KV(),
+		sessiondata.NodeUserSessionDataOverride,
+		`SELECT claim_session_id IS NOT NULL,
+       claim_session_id IS NOT NULL AND crdb_internal.sql_liveness_is_alive(claim_session_id)
+FROM system.jobs WHERE id = $1`,
+		u.j.ID(),
+	)
+	if err != nil {
+		return 0, errors.Wrapf(err, "job %d", u.j.ID())
+	}
+	if row == nil {
+		return 0, &JobNotFoundError{jobID: u.j.ID()}
+	}
+	switch {
+	case !bool(tree.MustBeDBool(row[0])):
+		return ClaimStateUnclaimed, nil
+	case !bool(tree.MustBeDBool(row[1])):
+		return ClaimStateDeadSession, nil
+	default:
+		return ClaimStateLiveSession, nil
+	}
+}
+
+// IsClaimedByLiveSession returns whether the job is claimed by a live session,
+// i.e. actively owned by a coordinator. See ClaimState to further tell apart
+// unclaimed jobs from those claimed by a dead session.
+func (u Updater) IsClaimedByLiveSession(ctx context.Context) (bool, error) {
+	state, err := u.ClaimState(ctx)
+	return state == ClaimStateLiveSession, err
+}
+
+// claimJobQuery claims a single job for a session, provided the job is not
+// already claimed by a different live session.
+const claimJobQuery = `
+UPDATE system.jobs
+   SET claim_session_id = $2, claim_instance_id = $3
+ WHERE id = $1
+   AND (claim_session_id IS NULL
+        OR claim_session_id = $2
+        OR NOT crdb_internal.sql_liveness_is_alive(claim_session_id))`
+
+// ClaimAndCheckpoint claims the job for the given session and records the
+// given progress in the same transaction, so that the job is never observed
+// claimed by session but with stale progress. If writing the progress fails,
+// the claim is rolled back along with it. On success, the job is associated
+// with session. An InvalidStatusError is returned if the job is in a terminal
+// status.
+func (u Updater) ClaimAndCheckpoint(
+	ctx context.Context, session sqlliveness.Session, progress *jobspb.Progress,
+) error {
+	if u.txn == nil {
+		return u.internalDB().Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+			u.txn = txn
+			return u.ClaimAndCheckpoint(ctx, session, progress)
+		})
+	}
+	n, err := u.txn.ExecEx(
+		ctx, "claim-job", u.txn.KV(),
+		sessiondata.NodeUserSessionDataOverride,
+		claimJobQuery, u.j.ID(), session.ID().UnsafeBytes(), u.j.registry.ID(),
+	)
+	if err != nil {
+		return errors.Wrapf(err, "job %d: could not claim job", u.j.ID())
+	}
+	if n != 1 {
+		return errors.Errorf("job %d: could not be claimed by session %s", u.j.ID(), session.ID())
+	}
+	u.j.mu.Lock()
+	prevSession := u.j.mu.session
+	u.j.mu.session = session
+	u.j.mu.Unlock()
+	if err := u.update(ctx, func(_ isql.Txn, md JobMetadata, ju *JobUpdater) error {
+		if md.Status.Terminal() {
+			return &InvalidStatusError{md.ID, md.Status, "claim and checkpoint", md.Payload.Error}
+		}
+		ju.UpdateProgress(progress)
+		return nil
+	}); err != nil {
+		u.j.mu.Lock()
+		u.j.mu.session = prevSession
+		u.j.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Reparent hands the job's claim to newSession, e.g. so that a draining node
+// can pass the jobs it coordinates on to another node rather than leaving them
+// to be adopted once its session expires. The update is subject to the usual
+// check that the job is claimed by the session of the Job, which is left
+// unchanged. The claim is handed to the SQL instance newSession belongs to.
+// An InvalidStatusError is returned if the job is in a terminal status.
+func (u Updater) Reparent(ctx context.Context, newSession sqlliveness.Session) error {
+	return u.Update(ctx, func(txn isql.Txn, md JobMetadata, ju *JobUpdater) error {
+		if md.Status.Terminal() {
+			return &InvalidStatusError{md.ID, md.Status, "reparent", md.Payload.Error}
+		}
+		row, err := txn.QueryRowEx(
+			ctx, "reparent-job-instance", txn.KV(),
+			sessiondata.NodeUserSessionDataOverride,
+			"SELECT id FROM system.sql_instances WHERE session_id = $1",
+			newSession.ID().UnsafeBytes(),
+		)
+		if err != nil {
+			return err
+		}
+		if row == nil {
+			return errors.Errorf("job %d: no SQL instance found for session %s", md.ID, newSession.ID())
+		}
+		ju.updateClaim(newSession.ID(), base.SQLInstanceID(tree.MustBeDInt(row[0])))
+		log.Infof(ctx, "job %d: reparented from session %s to session %s",
+			md.ID, md.ClaimSessionID, newSession.ID())
+		return nil
+	})
+}
+
 // Payload returns the most recently sent Payload for this Job.
 func (j *Job) Payload() jobspb.Payload {
 	j.mu.Lock()
@@ -611,6 +1280,96 @@ func (j *Job) FractionCompleted() float32 {
 	return progress.GetFractionCompleted()
 }
 
+// LastUpdatedBy returns the ID of the SQL instance that most recently updated
+// the job, along with the time of that update; see JobMetadata.LastUpdatedBy.
+// A zero instance ID is returned if no update has been recorded for the job.
+func (j *Job) LastUpdatedBy(ctx context.Context) (base.SQLInstanceID, time.Time, error) {
+	var instanceID base.SQLInstanceID
+	var updated time.Time
+	if err := j.registry.db.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+		instanceID, updated = 0, time.Time{}
+		value, written, ok, err := j.InfoStorage(txn).getWithWritten(ctx, lastUpdatedByKey)
+		if err != nil || !ok {
+			return err
+		}
+		id, err := strconv.ParseInt(string(value), 10, 32)
+		if err != nil {
+			return errors.Wrapf(err, "job %d: invalid last updated by instance ID", j.ID())
+		}
+		instanceID, updated = base.SQLInstanceID(id), written
+		return nil
+	}); err != nil {
+		return 0, time.Time{}, err
+	}
+	return instanceID, updated, nil
+}
+
+// SwitchedToHighWater returns the time at which the job's progress was
+// switched from a fraction to a high-water mark with SwitchToHighWater, and
+// whether it was switched at all.
+func (j *Job) SwitchedToHighWater(ctx context.Context) (time.Time, bool, error) {
+	var at time.Time
+	var switched bool
+	if err := j.registry.db.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+		var err error
+		_, at, switched, err = j.InfoStorage(txn).getWithWritten(ctx, switchedToHighWaterKey)
+		return err
+	}); err != nil {
+		return time.Time{}, false, err
+	}
+	return at, switched, nil
+}
+
+// AttentionFlag returns the reason for which, and the time at which, the job
+// was flagged with FlagForAttention, and whether it is currently flagged.
+func (j *Job) AttentionFlag(ctx context.Context) (reason string, at time.Time, ok bool, _ error) {
+	if err := j.registry.db.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+		value, written, found, err := j.InfoStorage(txn).getWithWritten(ctx, attentionKey)
+		reason, at, ok = string(value), written, found
+		return err
+	}); err != nil {
+		return "", time.Time{}, false, err
+	}
+	return reason, at, ok, nil
+}
+
+// LastHeartbeat returns the time of the job's most recent Heartbeat, or the
+// zero time if it has never recorded one.
+func (j *Job) LastHeartbeat(ctx context.Context) (time.Time, error) {
+	var at time.Time
+	if err := j.registry.db.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+		var err error
+		_, at, _, err = j.InfoStorage(txn).getWithWritten(ctx, heartbeatKey)
+		return err
+	}); err != nil {
+		return time.Time{}, err
+	}
+	return at, nil
+}
+
+// ETA returns the estimated completion time of the job, as last recorded by
+// JobUpdater.UpdateETA. It returns false if no estimate is recorded.
+func (j *Job) ETA(ctx context.Context) (time.Time, bool, error) {
+	var eta time.Time
+	var found bool
+	if err := j.registry.db.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+		eta, found = time.Time{}, false
+		value, written, ok, err := j.InfoStorage(txn).getWithWritten(ctx, etaKey)
+		if err != nil || !ok {
+			return err
+		}
+		remaining, err := strconv.ParseInt(string(value), 10, 64)
+		if err != nil {
+			return errors.Wrapf(err, "job %d: invalid ETA", j.ID())
+		}
+		eta, found = written.Add(time.Duration(remaining)), true
+		return nil
+	}); err != nil {
+		return time.Time{}, false, err
+	}
+	return eta, found, nil
+}
+
 // MarkIdle marks the job as Idle.  Idleness should not be toggled frequently
 // (no more than ~twice a minute) as the action is logged.
 func (j *Job) MarkIdle(isIdle bool) {
@@ -674,7 +1433,7 @@ func (j *Job) loadJobPayloadAndProgress(
 
 func (u Updater) load(ctx context.Context) (retErr error) {
 	if u.txn == nil {
-		return u.j.registry.db.Txn(ctx, func(
+		return u.internalDB().Txn(ctx, func(
 			ctx context.Context, txn isql.Txn,
 		) error {
 			u.txn = txn
@@ -709,12 +1468,12 @@ func (u Updater) load(ctx context.Context) (retErr error) {
 
 	var err error
 	var row tree.Datums
-	if j.session == nil {
+	if session := j.Session(); session == nil {
 		row, err = u.txn.QueryRowEx(ctx, "load-job-query", u.txn.KV(), sess,
 			queryNoSessionID, j.ID())
 	} else {
 		row, err = u.txn.QueryRowEx(ctx, "load-job-query", u.txn.KV(), sess,
-			queryWithSessionID, j.ID(), j.session.ID().UnsafeBytes())
+			queryWithSessionID, j.ID(), session.ID().UnsafeBytes())
 	}
 	if err != nil {
 		return err
@@ -736,18 +1495,33 @@ func (u Updater) load(ctx context.Context) (retErr error) {
 }
 
 // UnmarshalPayload unmarshals and returns the Payload encoded in the input
-// datum, which should be a tree.DBytes.
+// datum, which should be a tree.DBytes, decompressing it if it was compressed
+// when written.
 func UnmarshalPayload(datum tree.Datum) (*jobspb.Payload, error) {
+	payload, _, err := unmarshalPayloadWithBytes(datum)
+	return payload, err
+}
+
+// unmarshalPayloadWithBytes is like UnmarshalPayload, but also returns the
+// decompressed marshaled Payload.
+func unmarshalPayloadWithBytes(datum tree.Datum) (*jobspb.Payload, []byte, error) {
 	payload := &jobspb.Payload{}
 	bytes, ok := datum.(*tree.DBytes)
 	if !ok {
-		return nil, errors.Errorf(
+		return nil, nil, errors.Errorf(
 			"job: failed to unmarshal payload as DBytes (was %T)", datum)
 	}
-	if err := protoutil.Unmarshal([]byte(*bytes), payload); err != nil {
-		return nil, err
+	payloadBytes, err := DecodePayload([]byte(*bytes))
+	if err == nil {
+		err = protoutil.Unmarshal(payloadBytes, payload)
+	}
+	if err != nil {
+		return nil, nil, errors.Mark(errors.Wrapf(err,
+			"job: failed to unmarshal payload of %d bytes (compressed: %t)",
+			len(*bytes), isCompressedPayload([]byte(*bytes)),
+		), ErrCorruptJobPayload)
 	}
-	return payload, nil
+	return payload, payloadBytes, nil
 }
 
 // UnmarshalProgress unmarshals and returns the Progress encoded in the input
@@ -760,7 +1534,9 @@ func UnmarshalProgress(datum tree.Datum) (*jobspb.Progress, error) {
 			"job: failed to unmarshal Progress as DBytes (was %T)", datum)
 	}
 	if err := protoutil.Unmarshal([]byte(*bytes), progress); err != nil {
-		return nil, err
+		return nil, errors.Mark(errors.Wrapf(err,
+			"job: failed to unmarshal progress of %d bytes", len(*bytes),
+		), ErrCorruptJobProgress)
 	}
 	return progress, nil
 }
@@ -811,7 +1587,7 @@ func (sj *StartableJob) Start(ctx context.Context) (err error) {
 			"StartableJob %d cannot be started more than once", sj.ID())
 	}
 
-	if sj.session == nil {
+	if sj.Session() == nil {
 		return errors.AssertionFailedf(
 			"StartableJob %d cannot be started without sqlliveness session", sj.ID())
 	}
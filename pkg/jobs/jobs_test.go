@@ -3211,11 +3211,11 @@ func TestPauseReason(t *testing.T) {
 	}
 
 	{
-		// Now resume the job. Verify that the job is running now, but the pause reason is still there.
+		// Now resume the job. Verify that the job is running now, and the pause reason is cleared.
 		require.NoError(t, registry.Unpause(ctx, nil, jobID))
 		tdb.CheckQueryResultsRetry(t, q, [][]string{{"running"}})
 
-		checkStatusAndPauseReason(t, jobID, "running", "for testing")
+		checkStatusAndPauseReason(t, jobID, "running", "")
 		mustHaveClaim()
 		resumeSignaler.WaitForResumeStarted()
 	}
@@ -3612,3 +3612,70 @@ func TestLoadJobProgress(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, []float32{7.1}, p.GetDetails().(*jobspb.Progress_Import).Import.ReadProgress)
 }
+
+func TestUnmarshalCorruptJobRecords(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	garbage := []byte{0xff, 0xff, 0xff}
+	_, err := jobs.UnmarshalPayload(tree.NewDBytes(tree.DBytes(garbage)))
+	require.True(t, errors.Is(err, jobs.ErrCorruptJobPayload), "%+v", err)
+	require.Contains(t, err.Error(), "payload of 3 bytes (compressed: false)")
+
+	truncatedGzip := []byte{0x1f, 0x8b, 0x08}
+	_, err = jobs.UnmarshalPayload(tree.NewDBytes(tree.DBytes(truncatedGzip)))
+	require.True(t, errors.Is(err, jobs.ErrCorruptJobPayload), "%+v", err)
+	require.Contains(t, err.Error(), "payload of 3 bytes (compressed: true)")
+
+	_, err = jobs.UnmarshalProgress(tree.NewDBytes(tree.DBytes(garbage)))
+	require.True(t, errors.Is(err, jobs.ErrCorruptJobProgress), "%+v", err)
+	require.False(t, errors.Is(err, jobs.ErrCorruptJobPayload))
+	require.Contains(t, err.Error(), "progress of 3 bytes")
+}
+
+func TestRenderProgress(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	fraction := &jobspb.Progress{
+		Progress: &jobspb.Progress_FractionCompleted{FractionCompleted: 0.25},
+		Details:  jobspb.WrapProgressDetails(jobspb.ImportProgress{ReadProgress: []float32{0.25}}),
+	}
+	highWater := &jobspb.Progress{
+		Progress: &jobspb.Progress_HighWater{HighWater: &hlc.Timestamp{WallTime: 123, Logical: 4}},
+	}
+
+	rendered, custom := jobs.RenderProgress(jobspb.TypeImport, fraction)
+	require.False(t, custom)
+	require.Equal(t, "25.00%", rendered)
+	rendered, custom = jobs.RenderProgress(jobspb.TypeChangefeed, highWater)
+	require.False(t, custom)
+	require.Equal(t, "123.0000000004", rendered)
+
+	defer jobs.TestingRegisterProgressRenderer(jobspb.TypeImport, func(p *jobspb.Progress) string {
+		return fmt.Sprintf("read %v", p.GetImport().ReadProgress)
+	})()
+	rendered, custom = jobs.RenderProgress(jobspb.TypeImport, fraction)
+	require.True(t, custom)
+	require.Equal(t, "read [0.25]", rendered)
+
+	// SHOW JOBS uses the renderer for the running status of running jobs which
+	// don't set one.
+	ctx := context.Background()
+	s, sqlDB, _ := serverutils.StartServer(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	tdb := sqlutils.MakeSQLRunner(sqlDB)
+	rec := jobs.Record{
+		Username: username.TestUserName(),
+		Details:  jobspb.ImportDetails{},
+		Progress: jobspb.ImportProgress{ReadProgress: []float32{0.5}},
+	}
+	j, err := registry.CreateJobWithTxn(ctx, rec, registry.MakeJobID(), nil /* txn */)
+	require.NoError(t, err)
+	require.Equal(t, jobs.StatusRunning, j.Status())
+	tdb.CheckQueryResults(t,
+		fmt.Sprintf("SELECT running_status FROM [SHOW JOBS] WHERE job_id = %d", j.ID()),
+		[][]string{{"read [0.5]"}},
+	)
+}
@@ -54,6 +54,34 @@ type Metrics struct {
 	// correlate with the ClaimedJobs counter because a job can be resumed
 	// without an adopt loop, e.g., through a StartableJob.
 	ResumedJobs *metric.Counter
+
+	// StatusChangeEventsCoalesced counts the job status change events that were
+	// coalesced into a later event for the same job before delivery.
+	StatusChangeEventsCoalesced *metric.Counter
+
+	// StatusChangeEventsDropped counts the job status change events that were
+	// dropped because the delivery buffer was full.
+	StatusChangeEventsDropped *metric.Counter
+
+	// UpdatesStatusChanged, UpdatesPayloadWritten and UpdatesProgressWritten
+	// count the job updates which changed the status of a job, wrote its
+	// payload and wrote its progress, respectively. An update may be counted
+	// by several of them.
+	UpdatesStatusChanged   *metric.Counter
+	UpdatesPayloadWritten  *metric.Counter
+	UpdatesProgressWritten *metric.Counter
+
+	// UpdatesPayloadSkipped counts the payload writes which were skipped
+	// because the payload was unchanged.
+	UpdatesPayloadSkipped *metric.Counter
+
+	// UpdatesNoop counts the job updates which had nothing to write.
+	UpdatesNoop *metric.Counter
+
+	// UpdatesSessionMismatch counts the job updates which were rejected
+	// because the job was no longer claimed by the updating session and
+	// instance.
+	UpdatesSessionMismatch *metric.Counter
 }
 
 // JobTypeMetrics is a metric.Struct containing metrics for each type of job.
@@ -235,6 +263,70 @@ var (
 		MetricType:  io_prometheus_client.MetricType_GAUGE,
 	}
 
+	metaStatusChangeEventsCoalesced = metric.Metadata{
+		Name:        "jobs.status_change_events.coalesced",
+		Help:        "number of job status change events coalesced into a later event for the same job",
+		Measurement: "events",
+		Unit:        metric.Unit_COUNT,
+		MetricType:  io_prometheus_client.MetricType_COUNTER,
+	}
+
+	metaStatusChangeEventsDropped = metric.Metadata{
+		Name:        "jobs.status_change_events.dropped",
+		Help:        "number of job status change events dropped because the delivery buffer was full",
+		Measurement: "events",
+		Unit:        metric.Unit_COUNT,
+		MetricType:  io_prometheus_client.MetricType_COUNTER,
+	}
+
+	metaUpdatesStatusChanged = metric.Metadata{
+		Name:        "jobs.updates.status_changed",
+		Help:        "number of job updates which changed the status of the job",
+		Measurement: "updates",
+		Unit:        metric.Unit_COUNT,
+		MetricType:  io_prometheus_client.MetricType_COUNTER,
+	}
+
+	metaUpdatesPayloadWritten = metric.Metadata{
+		Name:        "jobs.updates.payload_written",
+		Help:        "number of job updates which wrote the payload of the job",
+		Measurement: "updates",
+		Unit:        metric.Unit_COUNT,
+		MetricType:  io_prometheus_client.MetricType_COUNTER,
+	}
+
+	metaUpdatesProgressWritten = metric.Metadata{
+		Name:        "jobs.updates.progress_written",
+		Help:        "number of job updates which wrote the progress of the job",
+		Measurement: "updates",
+		Unit:        metric.Unit_COUNT,
+		MetricType:  io_prometheus_client.MetricType_COUNTER,
+	}
+
+	metaUpdatesPayloadSkipped = metric.Metadata{
+		Name:        "jobs.updates.payload_skipped",
+		Help:        "number of job payload writes skipped because the payload was unchanged",
+		Measurement: "updates",
+		Unit:        metric.Unit_COUNT,
+		MetricType:  io_prometheus_client.MetricType_COUNTER,
+	}
+
+	metaUpdatesNoop = metric.Metadata{
+		Name:        "jobs.updates.noop",
+		Help:        "number of job updates which had nothing to write",
+		Measurement: "updates",
+		Unit:        metric.Unit_COUNT,
+		MetricType:  io_prometheus_client.MetricType_COUNTER,
+	}
+
+	metaUpdatesSessionMismatch = metric.Metadata{
+		Name:        "jobs.updates.session_mismatch",
+		Help:        "number of job updates rejected because the job was not claimed by the updating session and instance",
+		Measurement: "updates",
+		Unit:        metric.Unit_COUNT,
+		MetricType:  io_prometheus_client.MetricType_COUNTER,
+	}
+
 	// MetaRunningNonIdleJobs is the count of currently running jobs that are not
 	// reporting as being idle.
 	MetaRunningNonIdleJobs = metric.Metadata{
@@ -267,6 +359,14 @@ func (m *Metrics) init(histogramWindowInterval time.Duration, lookup *cidr.Looku
 	m.AdoptIterations = metric.NewCounter(metaAdoptIterations)
 	m.ClaimedJobs = metric.NewCounter(metaClaimedJobs)
 	m.ResumedJobs = metric.NewCounter(metaResumedClaimedJobs)
+	m.StatusChangeEventsCoalesced = metric.NewCounter(metaStatusChangeEventsCoalesced)
+	m.StatusChangeEventsDropped = metric.NewCounter(metaStatusChangeEventsDropped)
+	m.UpdatesStatusChanged = metric.NewCounter(metaUpdatesStatusChanged)
+	m.UpdatesPayloadWritten = metric.NewCounter(metaUpdatesPayloadWritten)
+	m.UpdatesProgressWritten = metric.NewCounter(metaUpdatesProgressWritten)
+	m.UpdatesPayloadSkipped = metric.NewCounter(metaUpdatesPayloadSkipped)
+	m.UpdatesNoop = metric.NewCounter(metaUpdatesNoop)
+	m.UpdatesSessionMismatch = metric.NewCounter(metaUpdatesSessionMismatch)
 	m.RunningNonIdleJobs = metric.NewGauge(MetaRunningNonIdleJobs)
 	for i := 0; i < jobspb.NumJobTypes; i++ {
 		jt := jobspb.Type(i)
@@ -0,0 +1,100 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/cockroachdb/cockroach/pkg/clusterversion"
+	"github.com/cockroachdb/cockroach/pkg/settings"
+	"github.com/cockroachdb/cockroach/pkg/settings/cluster"
+	"github.com/cockroachdb/errors"
+	"github.com/klauspost/compress/gzip"
+)
+
+var (
+	payloadCompressionEnabled = settings.RegisterBoolSetting(
+		settings.ApplicationLevel,
+		"jobs.payload.compression.enabled",
+		"if set, job payloads larger than jobs.payload.compression.threshold are "+
+			"written gzip-compressed once the cluster has been upgraded to a version "+
+			"which can read them",
+		false,
+	)
+
+	payloadCompressionThreshold = settings.RegisterByteSizeSetting(
+		settings.ApplicationLevel,
+		"jobs.payload.compression.threshold",
+		"the size above which job payloads are compressed, if "+
+			"jobs.payload.compression.enabled is set",
+		4<<10, /* 4 KiB */
+	)
+)
+
+// gzipMagic is the header of gzip-compressed data. A marshaled Payload never
+// starts with it, since its first byte would be the tag of a field with the
+// invalid wire type 7, which allows compressed payloads to be told apart from
+// uncompressed ones, such as those written by older nodes, by their value
+// alone.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// encodePayload compresses the marshaled payload if compression is enabled,
+// the payload is large enough and every node of the cluster is able to read
+// compressed payloads. It returns the value to write.
+func encodePayload(
+	ctx context.Context, st *cluster.Settings, payloadBytes []byte,
+) ([]byte, error) {
+	if !payloadCompressionEnabled.Get(&st.SV) ||
+		int64(len(payloadBytes)) <= payloadCompressionThreshold.Get(&st.SV) ||
+		!st.Version.IsActive(ctx, clusterversion.V24_3_JobsPayloadCompression) {
+		return payloadBytes, nil
+	}
+	compressed, err := compressChunk(payloadBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "compressing job payload")
+	}
+	return compressed, nil
+}
+
+// DecodePayload returns the marshaled payload stored as the value of a job's
+// legacy_payload info record, decompressing it if it was written compressed.
+func DecodePayload(value []byte) ([]byte, error) {
+	if !isCompressedPayload(value) {
+		return value, nil
+	}
+	r, err := gzip.NewReader(bytes.NewReader(value))
+	if err != nil {
+		return nil, errors.Wrap(err, "decompressing job payload")
+	}
+	decompressed, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "decompressing job payload")
+	}
+	return decompressed, nil
+}
+
+// isCompressedPayload returns whether the stored payload value was compressed
+// by encodePayload.
+func isCompressedPayload(value []byte) bool {
+	return bytes.HasPrefix(value, gzipMagic)
+}
+
+// DecodePayloadSQL returns a SQL expression which, like DecodePayload,
+// evaluates to the marshaled payload stored in the legacy_payload value
+// expression v, for SQL readers of system.job_info which decode the payload
+// themselves, e.g. with crdb_internal.pb_to_json.
+func DecodePayloadSQL(v string) string {
+	return fmt.Sprintf(`IF(substring(%[1]s, 1, %[2]d) = x'%[3]x', decompress(%[1]s, 'gzip'), %[1]s)`,
+		v, len(gzipMagic), gzipMagic)
+}
@@ -12,12 +12,15 @@ package jobs
 
 import (
 	"context"
+	"math"
 	"time"
 
 	"github.com/cockroachdb/cockroach/pkg/jobs/jobspb"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/util/startup"
 	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
 	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+	"github.com/cockroachdb/errors"
 )
 
 // For both backups and restores, we compute progress as the number of completed
@@ -32,6 +35,56 @@ var (
 	progressFractionThreshold float32 = 0.05
 )
 
+// minETAFraction is the fraction completed below which no estimate of the
+// time remaining is made, since estimates based on very little progress vary
+// wildly.
+const minETAFraction = 0.001
+
+// estimateRemaining naively estimates the time remaining until completion of
+// a job which completed the given fraction of its work in elapsed, assuming
+// the rest of the work proceeds at the same rate. It returns false if no
+// meaningful estimate can be made.
+func estimateRemaining(fraction float32, elapsed time.Duration) (time.Duration, bool) {
+	f := float64(fraction)
+	if math.IsNaN(f) || f < minETAFraction || elapsed < 0 {
+		return 0, false
+	}
+	if f >= 1 {
+		return 0, true
+	}
+	total := float64(elapsed) / f
+	return time.Duration(total) - elapsed, true
+}
+
+// completedSpans returns the spans recorded as completed in the type-specific
+// details of p. It fails for jobs whose progress doesn't track completed spans.
+func completedSpans(p *jobspb.Progress) ([]roachpb.Span, error) {
+	switch d := p.Details.(type) {
+	case *jobspb.Progress_Import:
+		if d.Import == nil {
+			return nil, nil
+		}
+		return d.Import.SpanProgress, nil
+	default:
+		return nil, errors.Newf("progress details %T do not track completed spans", p.Details)
+	}
+}
+
+// setCompletedSpans replaces the spans recorded as completed in the
+// type-specific details of p; see completedSpans.
+func setCompletedSpans(p *jobspb.Progress, spans []roachpb.Span) error {
+	switch d := p.Details.(type) {
+	case *jobspb.Progress_Import:
+		if d.Import == nil {
+			d.Import = &jobspb.ImportProgress{}
+		}
+		d.Import.SpanProgress = spans
+		return nil
+	default:
+		return errors.Newf("progress details %T do not track completed spans", p.Details)
+	}
+}
+
 // TestingSetProgressThresholds overrides batching limits to update more often.
 func TestingSetProgressThresholds() func() {
 	oldFraction := progressFractionThreshold
@@ -183,3 +236,94 @@ func (p *ProgressUpdateBatcher) Done(ctx context.Context) error {
 	}
 	return nil
 }
+
+const (
+	// adaptiveThrottleLatencyMultiple is the multiple of the smoothed latency
+	// of checkpoints used as the minimum interval between them by
+	// AdaptiveProgressThrottle, so that checkpointing consumes at most about
+	// 1% of a job's time.
+	adaptiveThrottleLatencyMultiple = 100
+	// adaptiveThrottleLatencyWeight is the weight of the latest observation in
+	// the exponential moving average of checkpoint latency.
+	adaptiveThrottleLatencyWeight = 0.2
+)
+
+// AdaptiveProgressThrottle limits how often a job checkpoints its progress
+// based on how long checkpoints take: as writes to the jobs tables slow down
+// under load, the minimum interval between checkpoints grows, protecting the
+// tables, while checkpoints remain frequent on an idle cluster. The interval
+// tracks an exponential moving average of observed checkpoint latencies and is
+// kept within [minInterval, maxInterval].
+type AdaptiveProgressThrottle struct {
+	minInterval, maxInterval time.Duration
+
+	mu struct {
+		syncutil.Mutex
+		// latencyEMA is the moving average of checkpoint latencies, in
+		// nanoseconds, or 0 before the first observation.
+		latencyEMA     float64
+		lastCheckpoint time.Time
+	}
+}
+
+// NewAdaptiveProgressThrottle returns an AdaptiveProgressThrottle whose
+// interval between checkpoints stays within [minInterval, maxInterval].
+func NewAdaptiveProgressThrottle(minInterval, maxInterval time.Duration) *AdaptiveProgressThrottle {
+	if maxInterval < minInterval {
+		maxInterval = minInterval
+	}
+	return &AdaptiveProgressThrottle{minInterval: minInterval, maxInterval: maxInterval}
+}
+
+// Interval returns the current minimum interval between checkpoints.
+func (t *AdaptiveProgressThrottle) Interval() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.intervalLocked()
+}
+
+func (t *AdaptiveProgressThrottle) intervalLocked() time.Duration {
+	interval := time.Duration(t.mu.latencyEMA * adaptiveThrottleLatencyMultiple)
+	if interval < t.minInterval {
+		return t.minInterval
+	}
+	if interval > t.maxInterval {
+		return t.maxInterval
+	}
+	return interval
+}
+
+// ShouldCheckpoint returns whether enough time has passed since the last
+// checkpoint for another one to be made at now.
+func (t *AdaptiveProgressThrottle) ShouldCheckpoint(now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.mu.lastCheckpoint.IsZero() || now.Sub(t.mu.lastCheckpoint) >= t.intervalLocked()
+}
+
+// RecordCheckpoint records that a checkpoint was made at now and took
+// latency to complete.
+func (t *AdaptiveProgressThrottle) RecordCheckpoint(now time.Time, latency time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.mu.lastCheckpoint = now
+	if t.mu.latencyEMA == 0 {
+		t.mu.latencyEMA = float64(latency)
+		return
+	}
+	t.mu.latencyEMA += adaptiveThrottleLatencyWeight * (float64(latency) - t.mu.latencyEMA)
+}
+
+// MaybeCheckpoint calls checkpoint if the throttle allows a checkpoint to be
+// made now, recording its latency. It returns whether checkpoint was called.
+func (t *AdaptiveProgressThrottle) MaybeCheckpoint(
+	ctx context.Context, checkpoint func(context.Context) error,
+) (bool, error) {
+	start := timeutil.Now()
+	if !t.ShouldCheckpoint(start) {
+		return false, nil
+	}
+	err := checkpoint(ctx)
+	t.RecordCheckpoint(start, timeutil.Since(start))
+	return true, err
+}
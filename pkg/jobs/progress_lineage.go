@@ -0,0 +1,120 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/jobs/jobspb"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/settings"
+	"github.com/cockroachdb/cockroach/pkg/sql/isql"
+	"github.com/cockroachdb/cockroach/pkg/sql/sem/tree"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+	"github.com/cockroachdb/errors"
+)
+
+var progressLineageRetainedVersions = settings.RegisterIntSetting(
+	settings.ApplicationLevel,
+	"jobs.progress_lineage.retained_versions",
+	"the number of versions of each job's progress retained in system.job_info "+
+		"for debugging; values below 2 retain only the latest version",
+	1,
+	settings.PositiveInt,
+)
+
+// ProgressVersion is a version of a job's progress along with the time at
+// which it was written.
+type ProgressVersion struct {
+	Written  time.Time
+	Progress *jobspb.Progress
+}
+
+// progressLineageKey returns the info_key under which the version of the job's
+// progress written at the given time is retained.
+func progressLineageKey(written time.Time) string {
+	return fmt.Sprintf("%s%019d", progressLineagePrefix, written.UnixNano())
+}
+
+// retainProgress copies the job's current progress, if any, into its progress
+// lineage before it is replaced, and drops the oldest versions in the lineage
+// beyond retained-1. The current progress itself remains the only
+// legacy_progress record of the job.
+func retainProgress(ctx context.Context, infoStorage InfoStorage, retained int64) error {
+	value, written, ok, err := infoStorage.getWithWritten(ctx, LegacyProgressKey)
+	if err != nil || !ok {
+		return err
+	}
+	if err := infoStorage.write(ctx, progressLineageKey(written), value); err != nil {
+		return err
+	}
+	end := string(roachpb.Key(progressLineagePrefix).PrefixEnd())
+	n, err := infoStorage.Count(ctx, progressLineagePrefix, end)
+	if err != nil {
+		return err
+	}
+	if excess := n - int(retained-1); excess > 0 {
+		return infoStorage.DeleteRange(ctx, progressLineagePrefix, end, excess)
+	}
+	return nil
+}
+
+// ProgressLineage returns up to limit of the most recent versions of the
+// job's progress, newest first. Older versions are only retained while
+// jobs.progress_lineage.retained_versions is above 1, so the lineage
+// otherwise consists of the current progress alone.
+func (j *Job) ProgressLineage(ctx context.Context, limit int) ([]ProgressVersion, error) {
+	if limit <= 0 {
+		return nil, errors.AssertionFailedf("invalid progress lineage limit %d", limit)
+	}
+	var lineage []ProgressVersion
+	if err := j.registry.db.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+		lineage = lineage[:0]
+		infoStorage := j.InfoStorage(txn)
+		value, written, ok, err := infoStorage.getWithWritten(ctx, LegacyProgressKey)
+		if err != nil || !ok {
+			return err
+		}
+		progress, err := UnmarshalProgress(tree.NewDBytes(tree.DBytes(value)))
+		if err != nil {
+			return err
+		}
+		lineage = append(lineage, ProgressVersion{Written: written, Progress: progress})
+
+		// The retained versions are iterated oldest first.
+		var older []ProgressVersion
+		if err := infoStorage.Iterate(ctx, progressLineagePrefix, func(infoKey string, value []byte) error {
+			nanos, err := strconv.ParseInt(strings.TrimPrefix(infoKey, progressLineagePrefix), 10, 64)
+			if err != nil {
+				return errors.Wrapf(err, "job %d: invalid progress lineage key %q", j.ID(), infoKey)
+			}
+			progress, err := UnmarshalProgress(tree.NewDBytes(tree.DBytes(value)))
+			if err != nil {
+				return err
+			}
+			older = append(older, ProgressVersion{Written: timeutil.Unix(0, nanos), Progress: progress})
+			return nil
+		}); err != nil {
+			return err
+		}
+		for k := len(older) - 1; k >= 0 && len(lineage) < limit; k-- {
+			lineage = append(lineage, older[k])
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return lineage, nil
+}
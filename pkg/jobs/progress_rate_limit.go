@@ -0,0 +1,76 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/jobs/jobspb"
+	"github.com/cockroachdb/cockroach/pkg/settings"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+)
+
+var (
+	fractionProgressMinDelta = settings.RegisterFloatSetting(
+		settings.ApplicationLevel,
+		"jobs.progress.fraction_min_delta",
+		"the minimum change of a job's completion fraction for which "+
+			"RateLimitedFractionProgressed writes the job's progress",
+		0.01,
+		settings.NonNegativeFloat,
+	)
+
+	fractionProgressMinInterval = settings.RegisterDurationSetting(
+		settings.ApplicationLevel,
+		"jobs.progress.fraction_min_interval",
+		"the interval after which RateLimitedFractionProgressed writes a job's "+
+			"progress regardless of how much its completion fraction changed",
+		30*time.Second,
+		settings.NonNegativeDuration,
+	)
+)
+
+// RateLimitedFractionProgressed is like FractionProgressed with a
+// FractionUpdater, but only writes the progress if fraction changed by at
+// least jobs.progress.fraction_min_delta since the last write through it, if
+// jobs.progress.fraction_min_interval has passed since then, or if fraction is
+// 1. Otherwise, only the in-memory progress of the job is updated.
+func (j *Job) RateLimitedFractionProgressed(ctx context.Context, fraction float32) error {
+	sv := &j.registry.settings.SV
+	now := timeutil.Now()
+
+	j.mu.Lock()
+	last := j.mu.lastFractionWrite
+	delta := fraction - last.fraction
+	if delta < 0 {
+		delta = -delta
+	}
+	if fraction < 1 && !last.at.IsZero() &&
+		float64(delta) < fractionProgressMinDelta.Get(sv) &&
+		now.Sub(last.at) < fractionProgressMinInterval.Get(sv) {
+		j.mu.progress.Progress = &jobspb.Progress_FractionCompleted{
+			FractionCompleted: fraction,
+		}
+		j.mu.Unlock()
+		return nil
+	}
+	j.mu.Unlock()
+
+	if err := j.NoTxn().FractionProgressed(ctx, FractionUpdater(fraction)); err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.mu.lastFractionWrite.at = now
+	j.mu.lastFractionWrite.fraction = fraction
+	return nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package jobs
+
+import (
+	"fmt"
+
+	"github.com/cockroachdb/cockroach/pkg/jobs/jobspb"
+)
+
+// ProgressRenderer renders the progress of a job as a human-readable string,
+// e.g. from the job type's ProgressDetails.
+type ProgressRenderer func(*jobspb.Progress) string
+
+// RegisterProgressRenderer registers the function used to render the progress
+// of jobs of the given type for display; see RenderProgress.
+func RegisterProgressRenderer(typ jobspb.Type, fn ProgressRenderer) {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	globalMu.progressRenderers[typ] = fn
+}
+
+// TestingRegisterProgressRenderer is like RegisterProgressRenderer but returns
+// a cleanup function which resets the registration for the given type.
+func TestingRegisterProgressRenderer(typ jobspb.Type, fn ProgressRenderer) func() {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	orig, found := globalMu.progressRenderers[typ]
+	globalMu.progressRenderers[typ] = fn
+	return func() {
+		globalMu.Lock()
+		defer globalMu.Unlock()
+		if found {
+			globalMu.progressRenderers[typ] = orig
+		} else {
+			delete(globalMu.progressRenderers, typ)
+		}
+	}
+}
+
+func getProgressRenderer(typ jobspb.Type) (ProgressRenderer, bool) {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	fn, ok := globalMu.progressRenderers[typ]
+	return fn, ok
+}
+
+// RenderProgress renders the progress of a job of the given type for display.
+// It uses the renderer registered for the type with RegisterProgressRenderer,
+// if any, and returns true if it did. Otherwise, the progress is rendered as
+// its high-water mark or its completion percentage.
+func RenderProgress(typ jobspb.Type, progress *jobspb.Progress) (_ string, custom bool) {
+	if progress == nil {
+		return "", false
+	}
+	if fn, ok := getProgressRenderer(typ); ok {
+		return fn(progress), true
+	}
+	if hw := progress.GetHighWater(); hw != nil {
+		return hw.AsOfSystemTime(), false
+	}
+	return fmt.Sprintf("%.2f%%", progress.GetFractionCompleted()*100), false
+}
@@ -12,7 +12,9 @@ package jobs
 
 import (
 	"context"
+	"math"
 	"testing"
+	"time"
 
 	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
@@ -39,3 +41,89 @@ func TestChunkProgressLoggerLimitsFloatingPointError(t *testing.T) {
 	}
 	require.Greater(t, lastReported, float32(0.99))
 }
+
+func TestEstimateRemaining(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	for _, tc := range []struct {
+		fraction  float32
+		elapsed   time.Duration
+		remaining time.Duration
+		ok        bool
+	}{
+		{fraction: 0.25, elapsed: time.Minute, remaining: 3 * time.Minute, ok: true},
+		{fraction: 0.5, elapsed: time.Hour, remaining: time.Hour, ok: true},
+		{fraction: 0.8, elapsed: 4 * time.Second, remaining: time.Second, ok: true},
+		{fraction: 1, elapsed: time.Hour, remaining: 0, ok: true},
+		// Too little progress for a meaningful estimate.
+		{fraction: 0, elapsed: time.Hour},
+		{fraction: 0.0001, elapsed: time.Second},
+		{fraction: float32(math.NaN()), elapsed: time.Second},
+	} {
+		remaining, ok := estimateRemaining(tc.fraction, tc.elapsed)
+		require.Equal(t, tc.ok, ok, "fraction %f", tc.fraction)
+		if tc.ok {
+			require.InDelta(t, tc.remaining, remaining, float64(time.Millisecond), "fraction %f", tc.fraction)
+		}
+	}
+}
+
+// simulateCheckpoints simulates a job that makes progress every tick for the
+// given duration and checkpoints it whenever throttle allows, each checkpoint
+// taking latency. It returns the number of checkpoints made.
+func simulateCheckpoints(
+	throttle *AdaptiveProgressThrottle, latency, tick, duration time.Duration,
+) int {
+	var checkpoints int
+	start := time.Unix(0, 0)
+	for now := start; now.Sub(start) < duration; now = now.Add(tick) {
+		if throttle.ShouldCheckpoint(now) {
+			throttle.RecordCheckpoint(now, latency)
+			checkpoints++
+		}
+	}
+	return checkpoints
+}
+
+func TestAdaptiveProgressThrottle(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	throttle := NewAdaptiveProgressThrottle(time.Second, time.Minute)
+	require.Equal(t, time.Second, throttle.Interval())
+
+	// Fast checkpoints keep the interval at its minimum.
+	simulateCheckpoints(throttle, time.Millisecond, 100*time.Millisecond, time.Minute)
+	require.Equal(t, time.Second, throttle.Interval())
+
+	// Slow checkpoints lengthen it...
+	simulateCheckpoints(throttle, 100*time.Millisecond, 100*time.Millisecond, 10*time.Minute)
+	require.InDelta(t, float64(10*time.Second), float64(throttle.Interval()), float64(time.Second))
+
+	// ...up to its maximum.
+	simulateCheckpoints(throttle, 5*time.Second, 100*time.Millisecond, 10*time.Minute)
+	require.Equal(t, time.Minute, throttle.Interval())
+
+	// Once the load subsides, the interval shrinks again.
+	simulateCheckpoints(throttle, time.Millisecond, 100*time.Millisecond, time.Hour)
+	require.Equal(t, time.Second, throttle.Interval())
+}
+
+func BenchmarkAdaptiveProgressThrottle(b *testing.B) {
+	for _, tc := range []struct {
+		name    string
+		latency time.Duration
+	}{
+		{name: "low-latency", latency: 2 * time.Millisecond},
+		{name: "high-latency", latency: 200 * time.Millisecond},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			var checkpoints int
+			for i := 0; i < b.N; i++ {
+				throttle := NewAdaptiveProgressThrottle(time.Second, time.Minute)
+				checkpoints = simulateCheckpoints(throttle, tc.latency, 100*time.Millisecond, time.Hour)
+			}
+			b.ReportMetric(float64(checkpoints)/60, "checkpoints/min")
+		})
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/jobs/jobspb"
+	"github.com/cockroachdb/cockroach/pkg/sql/isql"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+)
+
+// ProgressUpdater buffers the completion fraction of a job reporting progress
+// in a tight loop, so that only Flush, or MaybeFlush once
+// jobs.progress.fraction_min_interval has passed since the last write, writes
+// it. It is safe for concurrent use.
+type ProgressUpdater struct {
+	j *Job
+
+	mu struct {
+		syncutil.Mutex
+		// md is the job's metadata as of its latest load or flush.
+		md JobMetadata
+		// fraction is the latest fraction set, and dirty is set if it has not
+		// been written yet.
+		fraction float32
+		dirty    bool
+		// flushed is the time of the latest write.
+		flushed time.Time
+	}
+}
+
+// ProgressUpdater loads the job's metadata, checking that the job is still
+// claimed by its session, and returns a ProgressUpdater for it.
+func (j *Job) ProgressUpdater(ctx context.Context) (*ProgressUpdater, error) {
+	md, err := j.NoTxn().LoadMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := md.CheckRunningOrReverting(); err != nil {
+		return nil, err
+	}
+	p := &ProgressUpdater{j: j}
+	p.mu.md = md
+	p.mu.fraction = md.Progress.GetFractionCompleted()
+	p.mu.flushed = timeutil.Now()
+	return p, nil
+}
+
+// Set records the job's completion fraction in memory, to be written by the
+// next flush.
+func (p *ProgressUpdater) Set(fraction float32) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.mu.fraction = fraction
+	p.mu.dirty = true
+	p.j.mu.Lock()
+	defer p.j.mu.Unlock()
+	p.j.mu.progress.Progress = &jobspb.Progress_FractionCompleted{FractionCompleted: fraction}
+}
+
+// Metadata returns the job's metadata as of the latest load or flush.
+func (p *ProgressUpdater) Metadata() JobMetadata {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.mu.md
+}
+
+// MaybeFlush flushes the fraction set since the latest write if
+// jobs.progress.fraction_min_interval has passed since then.
+func (p *ProgressUpdater) MaybeFlush(ctx context.Context) error {
+	p.mu.Lock()
+	due := timeutil.Since(p.mu.flushed) >= fractionProgressMinInterval.Get(&p.j.registry.settings.SV)
+	p.mu.Unlock()
+	if !due {
+		return nil
+	}
+	return p.Flush(ctx)
+}
+
+// Flush writes the fraction set since the latest write, if any, checking that
+// the job is still claimed by its session. Set may be called concurrently, but
+// flushes must not be.
+func (p *ProgressUpdater) Flush(ctx context.Context) error {
+	p.mu.Lock()
+	fraction, dirty := p.mu.fraction, p.mu.dirty
+	p.mu.Unlock()
+	if !dirty {
+		return nil
+	}
+	var written JobMetadata
+	if err := p.j.NoTxn().Update(ctx, func(_ isql.Txn, md JobMetadata, ju *JobUpdater) error {
+		if err := md.CheckRunningOrReverting(); err != nil {
+			return err
+		}
+		md.Progress.Progress = &jobspb.Progress_FractionCompleted{FractionCompleted: fraction}
+		ju.UpdateProgress(md.Progress)
+		written = md
+		return nil
+	}); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.mu.md = written
+	// Fractions set during the write remain to be flushed.
+	p.mu.dirty = p.mu.fraction != fraction
+	p.mu.flushed = timeutil.Now()
+	return nil
+}
@@ -12,6 +12,7 @@ package jobs
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"os"
 	"strconv"
@@ -27,6 +28,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/server/telemetry"
 	"github.com/cockroachdb/cockroach/pkg/settings/cluster"
 	"github.com/cockroachdb/cockroach/pkg/sql/catalog/catpb"
+	"github.com/cockroachdb/cockroach/pkg/sql/catalog/descpb"
 	"github.com/cockroachdb/cockroach/pkg/sql/isql"
 	"github.com/cockroachdb/cockroach/pkg/sql/pgwire/pgerror"
 	"github.com/cockroachdb/cockroach/pkg/sql/sem/builtins"
@@ -136,6 +138,10 @@ type Registry struct {
 		// only be run when the transaction commits.
 		adoptedJobs map[jobspb.JobID]*adoptedJob
 
+		// maxActivePerType caps the number of claimed jobs of each type; see
+		// WithMaxActivePerType.
+		maxActivePerType map[jobspb.Type]int
+
 		// waiting is a set of jobs for which we're waiting to complete. In general,
 		// we expect these jobs to have been started with a claim by this instance.
 		// That may not have lasted to completion. Separately a goroutine will be
@@ -179,6 +185,18 @@ type Registry struct {
 
 	// test only overrides for resumer creation.
 	creationKnobs syncutil.Map[jobspb.Type, func(Resumer) Resumer]
+
+	// statusChanges batches and rate limits the delivery of job status
+	// change events.
+	statusChanges *statusChangePublisher
+
+	// inFlightUpdates tracks the job updates executing on this registry; see
+	// InFlightUpdates.
+	inFlightUpdates inFlightUpdates
+
+	// statusSubscriptions tracks the subscribers to job status transitions;
+	// see Subscribe.
+	statusSubscriptions statusSubscriptions
 }
 
 // UpdateJobWithTxn calls the Update method on an existing job with
@@ -257,9 +275,54 @@ func MakeRegistry(
 	r.mu.adoptedJobs = make(map[jobspb.JobID]*adoptedJob)
 	r.mu.waiting = make(map[jobspb.JobID]map[*waitingSet]struct{})
 	r.metrics.init(histogramWindowInterval, lookup)
+	r.statusChanges = makeStatusChangePublisher(settings, &r.metrics, r.deliverStatusChanges)
+	return r
+}
+
+// WithMaxActivePerType caps the number of jobs of each of the given types that
+// run at any one time across the cluster. The adoption loop does not claim jobs
+// of a type if as many jobs of the type as its cap are already claimed by any
+// node, so that they stay queued until a running job of the type finishes.
+// Jobs started directly by a registry, rather than through adoption, count
+// towards the cap but are not subject to it. Caps below 1 are raised to 1, so
+// that no type is starved indefinitely. The caps must be set on every node to
+// be enforced by all of their adoption loops. It replaces any caps previously
+// set and returns the registry.
+func (r *Registry) WithMaxActivePerType(caps map[jobspb.Type]int) *Registry {
+	maxActive := make(map[jobspb.Type]int, len(caps))
+	for typ, max := range caps {
+		if max < 1 {
+			max = 1
+		}
+		maxActive[typ] = max
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mu.maxActivePerType = maxActive
 	return r
 }
 
+// maxActivePerTypeArgs returns the job types capped with WithMaxActivePerType
+// and their caps, as the arrays cappedClaimQuery expects. It returns nil arrays
+// if no caps were set.
+func (r *Registry) maxActivePerTypeArgs() (typs, caps *tree.DArray, _ error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.mu.maxActivePerType) == 0 {
+		return nil, nil, nil
+	}
+	typs, caps = tree.NewDArray(types.String), tree.NewDArray(types.Int)
+	for typ, max := range r.mu.maxActivePerType {
+		if err := typs.Append(tree.NewDString(typ.String())); err != nil {
+			return nil, nil, err
+		}
+		if err := caps.Append(tree.NewDInt(tree.DInt(max))); err != nil {
+			return nil, nil, err
+		}
+	}
+	return typs, caps, nil
+}
+
 // SetInternalDB sets the DB that will be used by the job registry
 // executor. We expose this separately from the constructor to avoid a circular
 // dependency.
@@ -558,7 +621,7 @@ func (r *Registry) CreateJobWithTxn(
 		if err != nil {
 			return errors.Wrap(err, "error getting live session")
 		}
-		j.session = s
+		j.mu.session = s
 		start := timeutil.Now()
 		if txn != nil {
 			start = txn.KV().ReadTimestamp().GoTime()
@@ -808,7 +871,7 @@ func (r *Registry) CreateStartableJobWithTxn(
 		// Using a new context allows for independent lifetimes and cancellation.
 		resumerCtx, cancel = r.makeCtx()
 
-		if alreadyAdopted := r.addAdoptedJob(jobID, j.session, cancel, resumer); alreadyAdopted {
+		if alreadyAdopted := r.addAdoptedJob(jobID, j.Session(), cancel, resumer); alreadyAdopted {
 			log.Fatalf(
 				ctx,
 				"job %d: was just created but found in registered adopted jobs",
@@ -872,6 +935,106 @@ func (r *Registry) LoadJobWithTxn(
 	return j, nil
 }
 
+// MetadataFields is a bitmask selecting which parts of a job's metadata are
+// loaded by LoadMetadataProjected.
+type MetadataFields uint8
+
+const (
+	// MetadataStatus selects the job's status.
+	MetadataStatus MetadataFields = 1 << iota
+	// MetadataPayload selects the job's payload.
+	MetadataPayload
+	// MetadataProgress selects the job's progress.
+	MetadataProgress
+	// MetadataRunStats selects the job's run stats.
+	MetadataRunStats
+
+	// MetadataAll selects all of the job's metadata.
+	MetadataAll = MetadataStatus | MetadataPayload | MetadataProgress | MetadataRunStats
+)
+
+// LoadMetadataProjected loads the parts of the metadata of the job with the
+// given ID selected by fields; the others are left unset. The payload and
+// progress are only read from system.job_info, and unmarshaled, if selected,
+// so callers only interested in a job's status or run stats should not select
+// them.
+func (r *Registry) LoadMetadataProjected(
+	ctx context.Context, id jobspb.JobID, fields MetadataFields,
+) (JobMetadata, error) {
+	var md JobMetadata
+	if err := r.db.Txn(ctx, func(ctx context.Context, txn isql.Txn) (err error) {
+		md, err = loadMetadataProjected(ctx, txn, id, fields)
+		return err
+	}); err != nil {
+		return JobMetadata{}, err
+	}
+	return md, nil
+}
+
+func loadMetadataProjected(
+	ctx context.Context, txn isql.Txn, id jobspb.JobID, fields MetadataFields,
+) (JobMetadata, error) {
+	const infoQuery = `(SELECT value FROM system.job_info
+  WHERE job_id = j.id AND info_key = '%s'
+  ORDER BY written DESC LIMIT 1)`
+	cols := []string{"status"}
+	if fields&MetadataPayload != 0 {
+		cols = append(cols, fmt.Sprintf(infoQuery, LegacyPayloadKey))
+	}
+	if fields&MetadataProgress != 0 {
+		cols = append(cols, fmt.Sprintf(infoQuery, LegacyProgressKey))
+	}
+	if fields&MetadataRunStats != 0 {
+		cols = append(cols, "COALESCE(last_run, created)", "COALESCE(num_runs, 0)")
+	}
+	query := fmt.Sprintf("SELECT %s FROM system.jobs AS j WHERE id = $1", strings.Join(cols, ", "))
+	row, err := txn.QueryRowEx(
+		ctx, "load-job-metadata", txn.KV(),
+		sessiondata.NodeUserSessionDataOverride, query, id,
+	)
+	if err != nil {
+		return JobMetadata{}, err
+	}
+	if row == nil {
+		return JobMetadata{}, &JobNotFoundError{jobID: id}
+	}
+
+	md := JobMetadata{ID: id}
+	if fields&MetadataStatus != 0 {
+		if md.Status, err = unmarshalStatus(row[0]); err != nil {
+			return JobMetadata{}, err
+		}
+	}
+	row = row[1:]
+	if fields&MetadataPayload != 0 {
+		if row[0] == tree.DNull {
+			return JobMetadata{}, errors.Wrap(&JobNotFoundError{jobID: id}, "job payload not found in system.job_info")
+		}
+		if md.Payload, err = UnmarshalPayload(row[0]); err != nil {
+			return JobMetadata{}, err
+		}
+		row = row[1:]
+	}
+	if fields&MetadataProgress != 0 {
+		if md.Progress, err = UnmarshalProgress(row[0]); err != nil {
+			return JobMetadata{}, err
+		}
+		row = row[1:]
+	}
+	if fields&MetadataRunStats != 0 {
+		lastRun, ok := row[0].(*tree.DTimestamp)
+		if !ok {
+			return JobMetadata{}, errors.AssertionFailedf("expected timestamp last_run, but got %T", row[0])
+		}
+		numRuns, ok := row[1].(*tree.DInt)
+		if !ok {
+			return JobMetadata{}, errors.AssertionFailedf("expected int num_runs, but got %T", row[1])
+		}
+		md.RunStats = &RunStats{LastRun: lastRun.Time, NumRuns: int(*numRuns)}
+	}
+	return md, nil
+}
+
 // TODO (sajjad): make maxAdoptionsPerLoop a cluster setting.
 var maxAdoptionsPerLoop = envutil.EnvOrDefaultInt(`COCKROACH_JOB_ADOPTIONS_PER_PERIOD`, 10)
 
@@ -1082,6 +1245,9 @@ func (r *Registry) Start(ctx context.Context, stopper *stop.Stopper) error {
 				if err := r.cleanupOldJobs(ctx, old); err != nil {
 					log.Warningf(ctx, "error cleaning up old job records: %v", err)
 				}
+				if err := r.cleanupJobInfo(ctx); err != nil {
+					log.Warningf(ctx, "error cleaning up job info records: %v", err)
+				}
 				lc.onExecute()
 			}
 		}
@@ -1125,6 +1291,18 @@ func (r *Registry) Start(ctx context.Context, stopper *stop.Stopper) error {
 		r.startedControllerTasksWG.Done()
 		return err
 	}
+
+	r.startedControllerTasksWG.Add(1)
+	if err := stopper.RunAsyncTask(ctx, "jobs/status-change-events", func(ctx context.Context) {
+		defer r.startedControllerTasksWG.Done()
+
+		ctx, cancel := stopper.WithCancelOnQuiesce(ctx)
+		defer cancel()
+		r.statusChanges.run(ctx, stopper)
+	}); err != nil {
+		r.startedControllerTasksWG.Done()
+		return err
+	}
 	return nil
 }
 
@@ -1290,6 +1468,795 @@ func (r *Registry) DeleteTerminalJobByID(ctx context.Context, id jobspb.JobID) e
 	})
 }
 
+// deleteJobsBatchSize is the number of jobs deleted per transaction by
+// DeleteJobs.
+const deleteJobsBatchSize = 100
+
+// DeleteJobs deletes the given jobs, along with all of their job_info records,
+// provided that they are in a terminal state. Jobs in a non-terminal state,
+// and jobs which do not exist, are skipped. Jobs are deleted in batches, each
+// in its own transaction; the returned slice contains the IDs of the jobs
+// that were deleted, even if an error is returned for a later batch.
+func (r *Registry) DeleteJobs(
+	ctx context.Context, ids []jobspb.JobID,
+) (deleted []jobspb.JobID, err error) {
+	return r.deleteJobs(ctx, ids, false /* force */)
+}
+
+// ForceDeleteJobs is like DeleteJobs, but deletes the given jobs regardless of
+// their status. It does not stop jobs that are currently running.
+func (r *Registry) ForceDeleteJobs(
+	ctx context.Context, ids []jobspb.JobID,
+) (deleted []jobspb.JobID, err error) {
+	return r.deleteJobs(ctx, ids, true /* force */)
+}
+
+func (r *Registry) deleteJobs(
+	ctx context.Context, ids []jobspb.JobID, force bool,
+) (deleted []jobspb.JobID, _ error) {
+	const deleteTerminalStmt = `
+DELETE FROM system.jobs
+ WHERE id = ANY($1)
+   AND status IN ('` + string(StatusSucceeded) + `', '` + string(StatusCanceled) + `', '` +
+		string(StatusFailed) + `')
+RETURNING id`
+	const deleteStmt = `DELETE FROM system.jobs WHERE id = ANY($1) RETURNING id`
+	const deleteInfoStmt = `DELETE FROM system.job_info WHERE job_id = ANY($1)`
+
+	stmt := deleteTerminalStmt
+	if force {
+		stmt = deleteStmt
+	}
+	for len(ids) > 0 {
+		batch := ids
+		if len(batch) > deleteJobsBatchSize {
+			batch = batch[:deleteJobsBatchSize]
+		}
+		ids = ids[len(batch):]
+
+		toDelete := tree.NewDArray(types.Int)
+		for _, id := range batch {
+			if err := toDelete.Append(tree.NewDInt(tree.DInt(id))); err != nil {
+				return deleted, err
+			}
+		}
+		var batchDeleted []jobspb.JobID
+		if err := r.db.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+			batchDeleted = batchDeleted[:0]
+			rows, err := txn.QueryBufferedEx(
+				ctx, "delete-jobs", txn.KV(),
+				sessiondata.NodeUserSessionDataOverride,
+				stmt, toDelete,
+			)
+			if err != nil {
+				return err
+			}
+			if len(rows) == 0 {
+				return nil
+			}
+			deletedIDs := tree.NewDArray(types.Int)
+			for _, row := range rows {
+				batchDeleted = append(batchDeleted, jobspb.JobID(*row[0].(*tree.DInt)))
+				if err := deletedIDs.Append(row[0]); err != nil {
+					return err
+				}
+			}
+			_, err = txn.ExecEx(
+				ctx, "delete-job-infos", txn.KV(),
+				sessiondata.NodeUserSessionDataOverride,
+				deleteInfoStmt, deletedIDs,
+			)
+			return err
+		}); err != nil {
+			return deleted, errors.Wrap(err, "deleting jobs")
+		}
+		deleted = append(deleted, batchDeleted...)
+	}
+	if len(deleted) > 0 {
+		log.Infof(ctx, "deleted %d job records", len(deleted))
+	}
+	return deleted, nil
+}
+
+// SplitProgress moves the completed spans of the src job that match the
+// predicate to the completed spans of the dst job. Spans that do not match
+// remain with src. The completed spans are those of the type-specific progress
+// details of the jobs, e.g. the span progress of imports. Both jobs are updated
+// in a single transaction so that a repartitioned job never observes a span as
+// completed by both or neither.
+func (r *Registry) SplitProgress(
+	ctx context.Context, src, dst jobspb.JobID, predicate func(span roachpb.Span) bool,
+) error {
+	if src == dst {
+		return errors.AssertionFailedf("cannot split progress of job %d into itself", src)
+	}
+	return r.db.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+		srcJob, err := r.LoadJobWithTxn(ctx, src, txn)
+		if err != nil {
+			return err
+		}
+		dstJob, err := r.LoadJobWithTxn(ctx, dst, txn)
+		if err != nil {
+			return err
+		}
+		var moved []roachpb.Span
+		if err := srcJob.WithTxn(txn).Update(ctx, func(
+			_ isql.Txn, md JobMetadata, ju *JobUpdater,
+		) error {
+			spans, err := completedSpans(md.Progress)
+			if err != nil {
+				return err
+			}
+			var remaining []roachpb.Span
+			for _, sp := range spans {
+				if predicate(sp) {
+					moved = append(moved, sp)
+				} else {
+					remaining = append(remaining, sp)
+				}
+			}
+			if len(moved) == 0 {
+				return nil
+			}
+			if err := setCompletedSpans(md.Progress, remaining); err != nil {
+				return err
+			}
+			ju.UpdateProgress(md.Progress)
+			return nil
+		}); err != nil {
+			return err
+		}
+		if len(moved) == 0 {
+			return nil
+		}
+		return dstJob.WithTxn(txn).Update(ctx, func(
+			_ isql.Txn, md JobMetadata, ju *JobUpdater,
+		) error {
+			spans, err := completedSpans(md.Progress)
+			if err != nil {
+				return err
+			}
+			if err := setCompletedSpans(md.Progress, append(spans, moved...)); err != nil {
+				return err
+			}
+			ju.UpdateProgress(md.Progress)
+			return nil
+		})
+	})
+}
+
+// MergeProgressOptions control the behavior of MergeProgressWithOptions.
+type MergeProgressOptions struct {
+	// WorkUnits, if set, are the spans the survivor of the merge has to process
+	// in total, each counting as an equal unit of work. The survivor's fraction
+	// completed is then set to the number of these spans that are fully covered
+	// by its completed spans after the merge, divided by their count; partially
+	// covered spans do not count, so callers should split large spans into units
+	// of comparable size.
+	WorkUnits []roachpb.Span
+	// CompleteSources, if set, marks the sources of the merge as succeeded.
+	CompleteSources bool
+}
+
+// MergeProgress adds the completed spans of each of the from jobs to the
+// completed spans of the into job, atomically. See MergeProgressWithOptions.
+func (r *Registry) MergeProgress(ctx context.Context, into jobspb.JobID, from []jobspb.JobID) error {
+	return r.MergeProgressWithOptions(ctx, into, from, MergeProgressOptions{})
+}
+
+// MergeProgressWithOptions is used when jobs are consolidated into a single
+// surviving job: the completed spans of each of the from jobs are added to the
+// completed spans of the into job, which then holds their union. As with
+// SplitProgress, these are the completed spans of the jobs' progress details. All of the
+// jobs are read and updated in a single transaction.
+func (r *Registry) MergeProgressWithOptions(
+	ctx context.Context, into jobspb.JobID, from []jobspb.JobID, opts MergeProgressOptions,
+) error {
+	for _, id := range from {
+		if id == into {
+			return errors.AssertionFailedf("cannot merge progress of job %d into itself", into)
+		}
+	}
+	return r.db.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+		survivor, err := r.LoadJobWithTxn(ctx, into, txn)
+		if err != nil {
+			return err
+		}
+		var merged roachpb.SpanGroup
+		for _, id := range from {
+			source, err := r.LoadJobWithTxn(ctx, id, txn)
+			if err != nil {
+				return err
+			}
+			progress := source.Progress()
+			spans, err := completedSpans(&progress)
+			if err != nil {
+				return err
+			}
+			merged.Add(spans...)
+			if opts.CompleteSources {
+				if err := source.WithTxn(txn).succeeded(ctx, nil /* fn */); err != nil {
+					return err
+				}
+			}
+		}
+		if err := survivor.WithTxn(txn).Update(ctx, func(
+			_ isql.Txn, md JobMetadata, ju *JobUpdater,
+		) error {
+			spans, err := completedSpans(md.Progress)
+			if err != nil {
+				return err
+			}
+			merged.Add(spans...)
+			if err := setCompletedSpans(md.Progress, merged.Slice()); err != nil {
+				return err
+			}
+			ju.UpdateProgress(md.Progress)
+			return nil
+		}); err != nil {
+			return err
+		}
+		if len(opts.WorkUnits) == 0 {
+			return nil
+		}
+		var covered int
+		for _, sp := range opts.WorkUnits {
+			if merged.Encloses(sp) {
+				covered++
+			}
+		}
+		return survivor.WithTxn(txn).FractionProgressed(ctx, FractionUpdater(float32(covered)/float32(len(opts.WorkUnits))))
+	})
+}
+
+// transitionMatchingPageSize is the number of candidate jobs considered per
+// page by TransitionMatching, ResumePausedBy and CancelMatching.
+const transitionMatchingPageSize = 100
+
+// transitionSourceStatuses returns the statuses from which TransitionMatching
+// may transition a job to the given status.
+func transitionSourceStatuses(to Status) ([]Status, error) {
+	switch to {
+	case StatusPauseRequested:
+		return []Status{StatusPending, StatusRunning, StatusReverting}, nil
+	case StatusCancelRequested:
+		return []Status{StatusPending, StatusRunning, StatusPaused}, nil
+	case StatusRunning:
+		return []Status{StatusPaused}, nil
+	default:
+		return nil, errors.Newf("jobs cannot be transitioned to %s in bulk", to)
+	}
+}
+
+// TransitionMatching transitions every job of the given type whose payload
+// satisfies predicate to the status to, which must be one of pause-requested,
+// cancel-requested or running (i.e. resumed). Jobs are considered in pages of
+// IDs, and each job is checked and transitioned in its own transaction so that
+// a concurrent change of its status is never overwritten. Matching jobs whose
+// status does not allow the transition are skipped and logged. It returns the
+// IDs of the jobs that were transitioned, even if an error is returned.
+func (r *Registry) TransitionMatching(
+	ctx context.Context, typ jobspb.Type, predicate func(*jobspb.Payload) bool, to Status,
+) (affected []jobspb.JobID, _ error) {
+	sources, err := transitionSourceStatuses(to)
+	if err != nil {
+		return nil, err
+	}
+	legalSource := func(s Status) bool {
+		for _, source := range sources {
+			if s == source {
+				return true
+			}
+		}
+		return false
+	}
+
+	const pageQuery = `
+SELECT id FROM system.jobs
+ WHERE job_type = $1 AND id > $2
+ ORDER BY id
+ LIMIT $3`
+	var skipped []jobspb.JobID
+	var after jobspb.JobID
+	for {
+		var page []jobspb.JobID
+		if err := r.db.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+			page = page[:0]
+			rows, err := txn.QueryBufferedEx(
+				ctx, "transition-matching-page", txn.KV(),
+				sessiondata.NodeUserSessionDataOverride,
+				pageQuery, typ.String(), after, transitionMatchingPageSize,
+			)
+			if err != nil {
+				return err
+			}
+			for _, row := range rows {
+				page = append(page, jobspb.JobID(*row[0].(*tree.DInt)))
+			}
+			return nil
+		}); err != nil {
+			return affected, errors.Wrap(err, "listing jobs")
+		}
+
+		for _, id := range page {
+			var transitioned, illegal bool
+			if err := r.db.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+				transitioned, illegal = false, false
+				j, err := r.LoadJobWithTxn(ctx, id, txn)
+				if err != nil {
+					if HasJobNotFoundError(err) {
+						return nil
+					}
+					return err
+				}
+				return j.WithTxn(txn).Update(ctx, func(
+					txn isql.Txn, md JobMetadata, ju *JobUpdater,
+				) error {
+					if !predicate(md.Payload) || md.Status == to {
+						return nil
+					}
+					if !legalSource(md.Status) {
+						illegal = true
+						return nil
+					}
+					var err error
+					switch to {
+					case StatusPauseRequested:
+						err = ju.PauseRequested(ctx, txn, md, "bulk transition")
+					case StatusCancelRequested:
+						err = ju.CancelRequestedWithReason(ctx, md, errJobCanceled)
+					case StatusRunning:
+						err = ju.Unpaused(ctx, md)
+					}
+					transitioned = err == nil
+					return err
+				})
+			}); err != nil {
+				return affected, errors.Wrapf(err, "transitioning job %d to %s", id, to)
+			}
+			if transitioned {
+				affected = append(affected, id)
+			}
+			if illegal {
+				skipped = append(skipped, id)
+			}
+		}
+
+		if len(page) < transitionMatchingPageSize {
+			break
+		}
+		after = page[len(page)-1]
+	}
+	if len(skipped) > 0 {
+		log.Warningf(ctx, "skipped %d matching %s jobs which cannot be transitioned to %s: %v",
+			len(skipped), typ, to, skipped)
+	}
+	return affected, nil
+}
+
+// ResumePausedBy resumes every paused job whose recorded pause reason is
+// exactly reason, e.g. to undo a pause issued for a maintenance window without
+// resuming jobs that were paused for other reasons. Paused jobs are considered
+// in pages of IDs, and each job is rechecked and resumed in its own
+// transaction so that jobs which are no longer paused are left alone. It
+// returns the IDs of the jobs that were resumed, even if an error is returned.
+func (r *Registry) ResumePausedBy(
+	ctx context.Context, reason string,
+) (resumed []jobspb.JobID, _ error) {
+	const pageQuery = `
+SELECT id FROM system.jobs
+ WHERE status = '` + string(StatusPaused) + `' AND id > $1
+ ORDER BY id
+ LIMIT $2`
+	var after jobspb.JobID
+	for {
+		var page []jobspb.JobID
+		if err := r.db.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+			page = page[:0]
+			rows, err := txn.QueryBufferedEx(
+				ctx, "resume-paused-by-page", txn.KV(),
+				sessiondata.NodeUserSessionDataOverride,
+				pageQuery, after, transitionMatchingPageSize,
+			)
+			if err != nil {
+				return err
+			}
+			for _, row := range rows {
+				page = append(page, jobspb.JobID(*row[0].(*tree.DInt)))
+			}
+			return nil
+		}); err != nil {
+			return resumed, errors.Wrap(err, "listing paused jobs")
+		}
+
+		for _, id := range page {
+			var resumedJob bool
+			if err := r.db.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+				resumedJob = false
+				j, err := r.LoadJobWithTxn(ctx, id, txn)
+				if err != nil {
+					if HasJobNotFoundError(err) {
+						return nil
+					}
+					return err
+				}
+				return j.WithTxn(txn).Update(ctx, func(
+					txn isql.Txn, md JobMetadata, ju *JobUpdater,
+				) error {
+					if md.Status != StatusPaused || md.Payload.PauseReason != reason {
+						return nil
+					}
+					if err := ju.Unpaused(ctx, md); err != nil {
+						return err
+					}
+					resumedJob = true
+					return nil
+				})
+			}); err != nil {
+				return resumed, errors.Wrapf(err, "resuming job %d", id)
+			}
+			if resumedJob {
+				resumed = append(resumed, id)
+			}
+		}
+
+		if len(page) < transitionMatchingPageSize {
+			break
+		}
+		after = page[len(page)-1]
+	}
+	if len(resumed) > 0 {
+		log.Infof(ctx, "resumed %d jobs paused for %q: %v", len(resumed), reason, resumed)
+	}
+	return resumed, nil
+}
+
+// JobFilter selects jobs for CancelMatching and ListJobs. Its zero value
+// matches every job.
+type JobFilter struct {
+	// Type, if specified, is the type of the matching jobs.
+	Type jobspb.Type
+	// CreatedAfter, if set, matches jobs created at or after it.
+	CreatedAfter time.Time
+	// CreatedBefore, if set, matches jobs created before it.
+	CreatedBefore time.Time
+	// Statuses, if non-empty, are the statuses of the matching jobs.
+	Statuses []Status
+}
+
+// pageQuery returns the query selecting a page of the IDs of the jobs matching
+// f, along with its arguments, for the IDs after the given ID.
+func (f JobFilter) pageQuery(after jobspb.JobID, limit int) (string, []interface{}) {
+	var buf strings.Builder
+	args := []interface{}{after}
+	buf.WriteString("SELECT id FROM system.jobs WHERE id > $1")
+	if f.Type != jobspb.TypeUnspecified {
+		args = append(args, f.Type.String())
+		fmt.Fprintf(&buf, " AND job_type = $%d", len(args))
+	}
+	if !f.CreatedAfter.IsZero() {
+		args = append(args, f.CreatedAfter)
+		fmt.Fprintf(&buf, " AND created >= $%d", len(args))
+	}
+	if !f.CreatedBefore.IsZero() {
+		args = append(args, f.CreatedBefore)
+		fmt.Fprintf(&buf, " AND created < $%d", len(args))
+	}
+	if len(f.Statuses) > 0 {
+		statuses := tree.NewDArray(types.String)
+		for _, s := range f.Statuses {
+			_ = statuses.Append(tree.NewDString(string(s)))
+		}
+		args = append(args, statuses)
+		fmt.Fprintf(&buf, " AND status = ANY($%d)", len(args))
+	}
+	args = append(args, limit)
+	fmt.Fprintf(&buf, " ORDER BY id LIMIT $%d", len(args))
+	return buf.String(), args
+}
+
+// matchesStatus returns true if the status s is selected by f.
+func (f JobFilter) matchesStatus(s Status) bool {
+	if len(f.Statuses) == 0 {
+		return true
+	}
+	for _, status := range f.Statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// CancelMatching requests the cancellation of every job matching filter. Like
+// TransitionMatching, it considers matching jobs in pages of IDs and rechecks
+// and cancels each of them in its own transaction. Jobs which are finished, or
+// which cannot be canceled, e.g. because they are already reverting or are not
+// cancelable, are skipped; the latter are logged. It returns the IDs of the
+// jobs whose cancellation it requested, even if an error is returned.
+func (r *Registry) CancelMatching(
+	ctx context.Context, filter JobFilter,
+) (canceled []jobspb.JobID, _ error) {
+	var skipped []jobspb.JobID
+	var after jobspb.JobID
+	for {
+		var page []jobspb.JobID
+		if err := r.db.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+			page = page[:0]
+			query, args := filter.pageQuery(after, transitionMatchingPageSize)
+			rows, err := txn.QueryBufferedEx(
+				ctx, "cancel-matching-page", txn.KV(),
+				sessiondata.NodeUserSessionDataOverride,
+				query, args...,
+			)
+			if err != nil {
+				return err
+			}
+			for _, row := range rows {
+				page = append(page, jobspb.JobID(*row[0].(*tree.DInt)))
+			}
+			return nil
+		}); err != nil {
+			return canceled, errors.Wrap(err, "listing jobs")
+		}
+
+		for _, id := range page {
+			var canceledJob, skippedJob bool
+			if err := r.db.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+				canceledJob, skippedJob = false, false
+				j, err := r.LoadJobWithTxn(ctx, id, txn)
+				if err != nil {
+					if HasJobNotFoundError(err) {
+						return nil
+					}
+					return err
+				}
+				return j.WithTxn(txn).Update(ctx, func(
+					txn isql.Txn, md JobMetadata, ju *JobUpdater,
+				) error {
+					if md.Status.Terminal() || md.Status == StatusCancelRequested ||
+						!filter.matchesStatus(md.Status) {
+						return nil
+					}
+					if md.Payload.Noncancelable || !IsValidStatusTransition(md.Status, StatusCancelRequested) {
+						skippedJob = true
+						return nil
+					}
+					if err := ju.CancelRequestedWithReason(ctx, md, errJobCanceled); err != nil {
+						return err
+					}
+					canceledJob = true
+					return nil
+				})
+			}); err != nil {
+				return canceled, errors.Wrapf(err, "canceling job %d", id)
+			}
+			if canceledJob {
+				canceled = append(canceled, id)
+			}
+			if skippedJob {
+				skipped = append(skipped, id)
+			}
+		}
+
+		if len(page) < transitionMatchingPageSize {
+			break
+		}
+		after = page[len(page)-1]
+	}
+	if len(skipped) > 0 {
+		log.Warningf(ctx, "skipped %d matching jobs which cannot be canceled: %v", len(skipped), skipped)
+	}
+	return canceled, nil
+}
+
+// ListJobs returns the IDs, in increasing order, of up to limit jobs matching
+// filter, starting after the page identified by pageToken, along with the token
+// of the next page. An empty pageToken starts from the first page, and an
+// empty nextToken means that there are no further pages, though a full page
+// may be followed by an empty one. Only system.jobs is read.
+func (r *Registry) ListJobs(
+	ctx context.Context, filter JobFilter, pageToken string, limit int,
+) (ids []jobspb.JobID, nextToken string, _ error) {
+	if limit <= 0 {
+		return nil, "", errors.AssertionFailedf("invalid page size %d", limit)
+	}
+	var after jobspb.JobID
+	if pageToken != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(pageToken)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "decoding page token")
+		}
+		id, err := strconv.ParseInt(string(decoded), 10, 64)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "decoding page token")
+		}
+		after = jobspb.JobID(id)
+	}
+	query, args := filter.pageQuery(after, limit)
+	rows, err := r.db.Executor().QueryBufferedEx(
+		ctx, "list-jobs", nil, /* txn */
+		sessiondata.NodeUserSessionDataOverride,
+		query, args...,
+	)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "listing jobs")
+	}
+	for _, row := range rows {
+		ids = append(ids, jobspb.JobID(*row[0].(*tree.DInt)))
+	}
+	if len(ids) == limit {
+		last := strconv.FormatInt(int64(ids[len(ids)-1]), 10)
+		nextToken = base64.RawURLEncoding.EncodeToString([]byte(last))
+	}
+	return ids, nextToken, nil
+}
+
+// jobsForDescriptorPageSize is the number of job payloads read per
+// transaction by JobsForDescriptor.
+const jobsForDescriptorPageSize = 100
+
+// JobsForDescriptor returns the IDs of the jobs whose payload references the
+// given descriptor, in ascending order. The payloads of all jobs are scanned,
+// a page at a time, so this should not be called on hot paths.
+func (r *Registry) JobsForDescriptor(ctx context.Context, descID descpb.ID) ([]jobspb.JobID, error) {
+	const pageQuery = `
+SELECT DISTINCT ON (job_id) job_id, value FROM system.job_info
+ WHERE info_key = '` + LegacyPayloadKey + `' AND job_id > $1
+ ORDER BY job_id, written DESC
+ LIMIT $2`
+	var found []jobspb.JobID
+	var after jobspb.JobID
+	for {
+		var rows []tree.Datums
+		if err := r.db.Txn(ctx, func(ctx context.Context, txn isql.Txn) (err error) {
+			rows, err = txn.QueryBufferedEx(
+				ctx, "jobs-for-descriptor", txn.KV(),
+				sessiondata.NodeUserSessionDataOverride,
+				pageQuery, after, jobsForDescriptorPageSize,
+			)
+			return err
+		}); err != nil {
+			return nil, errors.Wrapf(err, "finding jobs referencing descriptor %d", descID)
+		}
+		for _, row := range rows {
+			id := jobspb.JobID(*row[0].(*tree.DInt))
+			after = id
+			payload, err := UnmarshalPayload(row[1])
+			if err != nil {
+				return nil, errors.Wrapf(err, "job %d", id)
+			}
+			for _, referenced := range payload.DescriptorIDs {
+				if referenced == descID {
+					found = append(found, id)
+					break
+				}
+			}
+		}
+		if len(rows) < jobsForDescriptorPageSize {
+			return found, nil
+		}
+	}
+}
+
+// JobsNeedingAttention returns the IDs of the non-terminal jobs currently
+// flagged with Updater.FlagForAttention, in ascending order. Only the
+// non-terminal jobs are scanned, and their flags looked up by job ID.
+func (r *Registry) JobsNeedingAttention(ctx context.Context) ([]jobspb.JobID, error) {
+	const query = `
+SELECT j.id FROM system.jobs AS j
+ WHERE j.status IN ` + NonTerminalStatusTupleString + `
+   AND EXISTS (
+       SELECT 1 FROM system.job_info AS i
+        WHERE i.job_id = j.id AND i.info_key = '` + attentionKey + `'
+       )
+ ORDER BY j.id`
+	var ids []jobspb.JobID
+	if err := r.db.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+		ids = ids[:0]
+		rows, err := txn.QueryBufferedEx(
+			ctx, "jobs-needing-attention", txn.KV(),
+			sessiondata.NodeUserSessionDataOverride, query,
+		)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			ids = append(ids, jobspb.JobID(*row[0].(*tree.DInt)))
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// stuckPauseRequestedCandidatesQuery lists the jobs in pause-requested which
+// are not claimed by a live session, and so are candidates for
+// RecoverStuckPauseRequested.
+const stuckPauseRequestedCandidatesQuery = `
+SELECT id FROM system.jobs
+ WHERE status = '` + string(StatusPauseRequested) + `'
+   AND (claim_session_id IS NULL OR NOT crdb_internal.sql_liveness_is_alive(claim_session_id))
+`
+
+// RecoverStuckPauseRequested transitions jobs that have been in the
+// pause-requested state for longer than threshold, and which are not claimed
+// by a live session, to paused. Such jobs would otherwise never be paused
+// because only the claiming registry acknowledges pause requests. Jobs with
+// a live claim are left for their registry to handle. The time at which a
+// job's pause was requested is the one recorded by PauseRequestedWithFunc;
+// jobs whose pause was requested before it was recorded are considered to
+// have been waiting for longer than any threshold. Each job is transitioned
+// through its own update, so that the usual validation, status history and
+// status change events apply.
+func (r *Registry) RecoverStuckPauseRequested(
+	ctx context.Context, threshold time.Duration,
+) (recovered []jobspb.JobID, err error) {
+	var candidates []jobspb.JobID
+	if err := r.db.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+		candidates = candidates[:0]
+		rows, err := txn.QueryBufferedEx(
+			ctx, "stuck-pause-requested-candidates", txn.KV(),
+			sessiondata.NodeUserSessionDataOverride,
+			stuckPauseRequestedCandidatesQuery,
+		)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			candidates = append(candidates, jobspb.JobID(*row[0].(*tree.DInt)))
+		}
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "could not query jobs table")
+	}
+
+	cutoff := r.clock.Now().GoTime().Add(-threshold)
+	for _, id := range candidates {
+		var paused bool
+		if err := r.db.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+			paused = false
+			j, err := r.LoadJobWithTxn(ctx, id, txn)
+			if err != nil {
+				if HasJobNotFoundError(err) {
+					return nil
+				}
+				return err
+			}
+			// The claim may have been picked up since the candidates were listed.
+			state, err := j.WithTxn(txn).ClaimState(ctx)
+			if err != nil || state == ClaimStateLiveSession {
+				return err
+			}
+			return j.WithTxn(txn).Update(ctx, func(
+				txn isql.Txn, md JobMetadata, ju *JobUpdater,
+			) error {
+				if md.Status != StatusPauseRequested {
+					return nil
+				}
+				requestedAt, ok, err := getPauseRequestedAt(ctx, j.InfoStorage(txn))
+				if err != nil {
+					return err
+				}
+				if ok && requestedAt.After(cutoff) {
+					return nil
+				}
+				ju.UpdateStatus(StatusPaused)
+				paused = true
+				return nil
+			})
+		}); err != nil {
+			return recovered, errors.Wrapf(err, "recovering job %d", id)
+		}
+		if paused {
+			log.Infof(ctx, "job %d: recovered from stuck %s state", id, StatusPauseRequested)
+			recovered = append(recovered, id)
+		}
+	}
+	return recovered, nil
+}
+
 // PauseRequested marks the job with id as paused-requested using the specified txn (may be nil).
 func (r *Registry) PauseRequested(
 	ctx context.Context, txn isql.Txn, id jobspb.JobID, reason string,
@@ -1307,6 +2274,22 @@ func (r *Registry) Unpause(ctx context.Context, txn isql.Txn, id jobspb.JobID) e
 	})
 }
 
+// ResetBackoff resets the run stats of the job with id, in a transaction of
+// its own, so that it is no longer backing off from previous failed runs and
+// is resumed as soon as it is adopted. It is an error to reset the backoff of
+// a job which is already finished.
+func (r *Registry) ResetBackoff(ctx context.Context, id jobspb.JobID) error {
+	return r.UpdateJobWithTxn(ctx, id, nil /* txn */, func(
+		txn isql.Txn, md JobMetadata, ju *JobUpdater,
+	) error {
+		if md.Status.Terminal() {
+			return &InvalidStatusError{md.ID, md.Status, "reset backoff of", md.Payload.Error}
+		}
+		ju.ResetRunStats()
+		return nil
+	})
+}
+
 // UnsafeFailed marks the job with id as failed. Use outside of the
 // job system is discouraged.
 //
@@ -1413,6 +2396,50 @@ func WithJobMetrics(m metric.Struct) RegisterOption {
 	}
 }
 
+// ProgressKind is the kind of completion progress jobs of a type report.
+type ProgressKind int
+
+const (
+	// ProgressKindAny allows jobs to report either kind of progress.
+	ProgressKindAny ProgressKind = iota
+	// ProgressKindFraction is the kind of jobs reporting a completion
+	// fraction.
+	ProgressKindFraction
+	// ProgressKindHighWater is the kind of jobs reporting a high-water mark.
+	ProgressKindHighWater
+)
+
+func (k ProgressKind) String() string {
+	switch k {
+	case ProgressKindFraction:
+		return "fraction"
+	case ProgressKindHighWater:
+		return "high-water"
+	default:
+		return "any"
+	}
+}
+
+// WithProgressKind returns a RegisterOption which declares the kind of
+// progress jobs of this type report. Updates writing progress of the other
+// kind fail with ErrProgressKindMismatch.
+func WithProgressKind(kind ProgressKind) RegisterOption {
+	return func(opts *registerOptions) {
+		opts.progressKind = kind
+	}
+}
+
+// WithResumptionEpoch returns a RegisterOption which causes the resumption
+// epoch of jobs of this type to be bumped each time they are adopted, so that
+// their resumers can fence their writes on it with Updater.WithEpoch. The
+// epoch of jobs of other types is only bumped by Updater.BumpEpoch, and
+// Job.Epoch returns 0 for them once they have been adopted.
+func WithResumptionEpoch() RegisterOption {
+	return func(opts *registerOptions) {
+		opts.bumpsEpoch = true
+	}
+}
+
 // registerOptions are passed to RegisterConstructor and control how a job
 // resumer is created and configured.
 type registerOptions struct {
@@ -1427,6 +2454,14 @@ type registerOptions struct {
 
 	// metrics allow jobs to register job specific metrics.
 	metrics metric.Struct
+
+	// progressKind is the kind of progress jobs of the type report; see
+	// WithProgressKind.
+	progressKind ProgressKind
+
+	// bumpsEpoch is set if the resumption epoch of jobs of the type is bumped
+	// on adoption; see WithResumptionEpoch.
+	bumpsEpoch bool
 }
 
 // JobResultsReporter is an interface for reporting the results of the job execution.
@@ -1451,11 +2486,13 @@ type Constructor func(job *Job, settings *cluster.Settings) Resumer
 var globalMu = struct {
 	syncutil.Mutex
 
-	constructors map[jobspb.Type]Constructor
-	options      map[jobspb.Type]registerOptions
+	constructors      map[jobspb.Type]Constructor
+	options           map[jobspb.Type]registerOptions
+	progressRenderers map[jobspb.Type]ProgressRenderer
 }{
-	constructors: make(map[jobspb.Type]Constructor),
-	options:      make(map[jobspb.Type]registerOptions),
+	constructors:      make(map[jobspb.Type]Constructor),
+	options:           make(map[jobspb.Type]registerOptions),
+	progressRenderers: make(map[jobspb.Type]ProgressRenderer),
 }
 
 func getRegisterOptions(typ jobspb.Type) (registerOptions, bool) {
@@ -1886,11 +2923,12 @@ func (r *Registry) getClaimedJob(jobID jobspb.JobID) (*Job, error) {
 	if !ok {
 		return nil, &JobNotFoundError{jobID: jobID}
 	}
-	return &Job{
+	j := &Job{
 		id:       jobID,
-		session:  aj.session,
 		registry: r,
-	}, nil
+	}
+	j.mu.session = aj.session
+	return j, nil
 }
 
 // RetryInitialDelay returns the value of retryInitialDelaySetting cluster setting,
@@ -1911,6 +2949,19 @@ func (r *Registry) RetryMaxDelay() float64 {
 	return retryMaxDelaySetting.Get(&r.settings.SV).Seconds()
 }
 
+// NextRetry returns the earliest time at which the registry resumes the job
+// with the given metadata, based on its run stats and the backoff parameters
+// of the registry. It returns the zero time if the job has no run stats.
+func (r *Registry) NextRetry(md JobMetadata) time.Time {
+	if md.RunStats == nil {
+		return time.Time{}
+	}
+	return md.RunStats.NextRetry(
+		time.Duration(r.RetryInitialDelay()*float64(time.Second)),
+		time.Duration(r.RetryMaxDelay()*float64(time.Second)),
+	)
+}
+
 // maybeRecordExecutionFailure will record a
 // RetriableExecutionFailureError into the job payload.
 func (r *Registry) maybeRecordExecutionFailure(ctx context.Context, err error, j *Job) {
@@ -14,6 +14,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"regexp"
@@ -27,6 +28,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/jobs"
 	"github.com/cockroachdb/cockroach/pkg/jobs/jobspb"
 	"github.com/cockroachdb/cockroach/pkg/jobs/jobstest"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/security/username"
 	"github.com/cockroachdb/cockroach/pkg/settings"
 	"github.com/cockroachdb/cockroach/pkg/settings/cluster"
@@ -806,3 +808,925 @@ func TestWaitWithRetryableError(t *testing.T) {
 		require.GreaterOrEqualf(t, numberOfTimesDetected.Load(), int64(2), "jobs query did not retry")
 	}
 }
+
+func TestSplitProgress(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+
+	span := func(start, end string) roachpb.Span {
+		return roachpb.Span{Key: roachpb.Key(start), EndKey: roachpb.Key(end)}
+	}
+	create := func(spans ...roachpb.Span) jobspb.JobID {
+		j, err := registry.CreateJobWithTxn(ctx, jobs.Record{
+			Details:  jobspb.ImportDetails{},
+			Progress: jobspb.ImportProgress{SpanProgress: spans},
+			Username: username.TestUserName(),
+		}, registry.MakeJobID(), nil /* txn */)
+		require.NoError(t, err)
+		return j.ID()
+	}
+	completedSpans := func(id jobspb.JobID) []roachpb.Span {
+		j, err := registry.LoadJob(ctx, id)
+		require.NoError(t, err)
+		p := j.Progress()
+		return p.GetImport().SpanProgress
+	}
+	src := create(span("a", "b"), span("m", "n"), span("c", "d"), span("x", "z"))
+	dst := create(span("e", "f"))
+
+	require.NoError(t, registry.SplitProgress(ctx, src, dst, func(sp roachpb.Span) bool {
+		return sp.Key.Compare(roachpb.Key("m")) >= 0
+	}))
+	require.Equal(t, []roachpb.Span{span("a", "b"), span("c", "d")}, completedSpans(src))
+	require.Equal(t, []roachpb.Span{span("e", "f"), span("m", "n"), span("x", "z")}, completedSpans(dst))
+
+	// Splitting into a job that does not exist must not modify the source.
+	err := registry.SplitProgress(ctx, src, registry.MakeJobID(), func(roachpb.Span) bool {
+		return true
+	})
+	require.True(t, jobs.HasJobNotFoundError(err))
+	require.Equal(t, []roachpb.Span{span("a", "b"), span("c", "d")}, completedSpans(src))
+
+	// Jobs whose progress doesn't track completed spans can't be split.
+	other, err := registry.CreateJobWithTxn(ctx, jobs.Record{
+		Details:  jobspb.BackupDetails{},
+		Progress: jobspb.BackupProgress{},
+		Username: username.TestUserName(),
+	}, registry.MakeJobID(), nil /* txn */)
+	require.NoError(t, err)
+	err = registry.SplitProgress(ctx, other.ID(), dst, func(roachpb.Span) bool { return true })
+	require.ErrorContains(t, err, "do not track completed spans")
+}
+
+func TestRecoverStuckPauseRequested(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	// Neither adopt nor serve pause requests so that jobs stay in
+	// pause-requested until recovered.
+	interval := 10 * time.Hour
+	args := base.TestServerArgs{Knobs: base.TestingKnobs{
+		JobsTestingKnobs: jobs.NewTestingKnobsWithIntervals(interval, interval, interval, interval),
+	}}
+
+	ctx := context.Background()
+	s, sqlDB, _ := serverutils.StartServer(t, args)
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	tdb := sqlutils.MakeSQLRunner(sqlDB)
+
+	record := jobs.Record{
+		Details:  jobspb.ImportDetails{},
+		Progress: jobspb.ImportProgress{},
+		Username: username.TestUserName(),
+	}
+	// Both jobs are created with a claim held by the registry's live session.
+	stuck, err := registry.CreateJobWithTxn(ctx, record, registry.MakeJobID(), nil /* txn */)
+	require.NoError(t, err)
+	claimed, err := registry.CreateJobWithTxn(ctx, record, registry.MakeJobID(), nil /* txn */)
+	require.NoError(t, err)
+	for _, id := range []jobspb.JobID{stuck.ID(), claimed.ID()} {
+		require.NoError(t, registry.PauseRequested(ctx, nil /* txn */, id, "test"))
+	}
+	// Simulate the resumer of the stuck job having died by dropping its claim.
+	tdb.Exec(t, `UPDATE system.jobs SET claim_session_id = NULL, claim_instance_id = NULL WHERE id = $1`,
+		stuck.ID())
+
+	var numRunsBefore int
+	tdb.QueryRow(t, `SELECT COALESCE(num_runs, 0) FROM system.jobs WHERE id = $1`, stuck.ID()).Scan(&numRunsBefore)
+
+	// A threshold longer than the time spent in pause-requested recovers nothing.
+	recovered, err := registry.RecoverStuckPauseRequested(ctx, time.Hour)
+	require.NoError(t, err)
+	require.Empty(t, recovered)
+
+	recovered, err = registry.RecoverStuckPauseRequested(ctx, 0 /* threshold */)
+	require.NoError(t, err)
+	require.Equal(t, []jobspb.JobID{stuck.ID()}, recovered)
+
+	getStatus := func(id jobspb.JobID) jobs.Status {
+		var status string
+		tdb.QueryRow(t, `SELECT status FROM system.jobs WHERE id = $1`, id).Scan(&status)
+		return jobs.Status(status)
+	}
+	require.Equal(t, jobs.StatusPaused, getStatus(stuck.ID()))
+	require.Equal(t, jobs.StatusPauseRequested, getStatus(claimed.ID()))
+
+	// The job went through a regular update: its run stats are untouched and
+	// the pause is recorded in its status history.
+	var numRuns int
+	tdb.QueryRow(t, `SELECT COALESCE(num_runs, 0) FROM system.jobs WHERE id = $1`, stuck.ID()).Scan(&numRuns)
+	require.Equal(t, numRunsBefore, numRuns)
+	history, err := stuck.StatusHistory(ctx)
+	require.NoError(t, err)
+	require.NotEmpty(t, history)
+	last := history[len(history)-1]
+	require.Equal(t, jobs.StatusPauseRequested, last.From)
+	require.Equal(t, jobs.StatusPaused, last.To)
+}
+
+func TestDeleteJobs(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s, sqlDB, _ := serverutils.StartServer(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	idb := s.InternalDB().(isql.DB)
+	tdb := sqlutils.MakeSQLRunner(sqlDB)
+
+	record := jobs.Record{
+		Details:  jobspb.ImportDetails{},
+		Progress: jobspb.ImportProgress{},
+		Username: username.TestUserName(),
+	}
+	terminal, err := registry.CreateJobWithTxn(ctx, record, registry.MakeJobID(), nil /* txn */)
+	require.NoError(t, err)
+	running, err := registry.CreateJobWithTxn(ctx, record, registry.MakeJobID(), nil /* txn */)
+	require.NoError(t, err)
+	require.NoError(t, registry.Succeeded(ctx, nil /* txn */, terminal.ID()))
+	require.NoError(t, idb.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+		return jobs.InfoStorageForJob(txn, terminal.ID()).Write(ctx, "extra", []byte("info"))
+	}))
+
+	countRows := func(id jobspb.JobID) (jobRows, infoRows int) {
+		tdb.QueryRow(t, `SELECT count(*) FROM system.jobs WHERE id = $1`, id).Scan(&jobRows)
+		tdb.QueryRow(t, `SELECT count(*) FROM system.job_info WHERE job_id = $1`, id).Scan(&infoRows)
+		return jobRows, infoRows
+	}
+
+	deleted, err := registry.DeleteJobs(ctx, []jobspb.JobID{terminal.ID(), running.ID()})
+	require.NoError(t, err)
+	require.Equal(t, []jobspb.JobID{terminal.ID()}, deleted)
+	jobRows, infoRows := countRows(terminal.ID())
+	require.Zero(t, jobRows)
+	require.Zero(t, infoRows)
+	jobRows, infoRows = countRows(running.ID())
+	require.Equal(t, 1, jobRows)
+	require.NotZero(t, infoRows)
+
+	deleted, err = registry.ForceDeleteJobs(ctx, []jobspb.JobID{running.ID()})
+	require.NoError(t, err)
+	require.Equal(t, []jobspb.JobID{running.ID()}, deleted)
+	jobRows, infoRows = countRows(running.ID())
+	require.Zero(t, jobRows)
+	require.Zero(t, infoRows)
+}
+
+func TestTransitionMatching(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+
+	create := func(description string) jobspb.JobID {
+		record := jobs.Record{
+			Description: description,
+			Details:     jobspb.ImportDetails{},
+			Progress:    jobspb.ImportProgress{},
+			Username:    username.TestUserName(),
+		}
+		j, err := registry.CreateJobWithTxn(ctx, record, registry.MakeJobID(), nil /* txn */)
+		require.NoError(t, err)
+		return j.ID()
+	}
+	failing1 := create("import from failing endpoint")
+	healthy := create("import from healthy endpoint")
+	failing2 := create("import from failing endpoint")
+	failingDone := create("import from failing endpoint")
+	require.NoError(t, registry.Succeeded(ctx, nil /* txn */, failingDone))
+
+	status := func(id jobspb.JobID) jobs.Status {
+		j, err := registry.LoadJob(ctx, id)
+		require.NoError(t, err)
+		return j.Status()
+	}
+	onFailingEndpoint := func(p *jobspb.Payload) bool {
+		return strings.Contains(p.Description, "failing")
+	}
+
+	affected, err := registry.TransitionMatching(
+		ctx, jobspb.TypeImport, onFailingEndpoint, jobs.StatusPauseRequested,
+	)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []jobspb.JobID{failing1, failing2}, affected)
+	require.Equal(t, jobs.StatusPauseRequested, status(failing1))
+	require.Equal(t, jobs.StatusPauseRequested, status(failing2))
+	require.Equal(t, jobs.StatusRunning, status(healthy))
+	require.Equal(t, jobs.StatusSucceeded, status(failingDone))
+
+	// Jobs that were already transitioned are not affected again.
+	affected, err = registry.TransitionMatching(
+		ctx, jobspb.TypeImport, onFailingEndpoint, jobs.StatusPauseRequested,
+	)
+	require.NoError(t, err)
+	require.Empty(t, affected)
+
+	_, err = registry.TransitionMatching(ctx, jobspb.TypeImport, onFailingEndpoint, jobs.StatusSucceeded)
+	require.Error(t, err)
+}
+
+func TestResumePausedBy(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	idb := s.InternalDB().(isql.DB)
+
+	create := func() jobspb.JobID {
+		record := jobs.Record{
+			Details:  jobspb.ImportDetails{},
+			Progress: jobspb.ImportProgress{},
+			Username: username.TestUserName(),
+		}
+		j, err := registry.CreateJobWithTxn(ctx, record, registry.MakeJobID(), nil /* txn */)
+		require.NoError(t, err)
+		return j.ID()
+	}
+	// pause requests a pause of the job with the given reason and, since
+	// nothing adopts jobs in this test, completes it directly.
+	pause := func(id jobspb.JobID, reason string) {
+		require.NoError(t, idb.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+			return registry.PauseRequested(ctx, txn, id, reason)
+		}))
+		j, err := registry.LoadJob(ctx, id)
+		require.NoError(t, err)
+		require.NoError(t, j.NoTxn().Update(ctx, func(
+			_ isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater,
+		) error {
+			ju.UpdateStatus(jobs.StatusPaused)
+			return nil
+		}))
+	}
+	status := func(id jobspb.JobID) jobs.Status {
+		j, err := registry.LoadJob(ctx, id)
+		require.NoError(t, err)
+		return j.Status()
+	}
+
+	const maintenance = "maintenance window"
+	matching1, matching2 := create(), create()
+	pause(matching1, maintenance)
+	pause(matching2, maintenance)
+	other := create()
+	pause(other, "investigating failure")
+	requested := create()
+	require.NoError(t, idb.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+		return registry.PauseRequested(ctx, txn, requested, maintenance)
+	}))
+	running := create()
+
+	resumed, err := registry.ResumePausedBy(ctx, maintenance)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []jobspb.JobID{matching1, matching2}, resumed)
+	require.Equal(t, jobs.StatusRunning, status(matching1))
+	require.Equal(t, jobs.StatusRunning, status(matching2))
+	require.Equal(t, jobs.StatusPaused, status(other))
+	// Only paused jobs are touched, even if the reason matches.
+	require.Equal(t, jobs.StatusPauseRequested, status(requested))
+	require.Equal(t, jobs.StatusRunning, status(running))
+
+	resumed, err = registry.ResumePausedBy(ctx, maintenance)
+	require.NoError(t, err)
+	require.Empty(t, resumed)
+}
+
+func TestCancelMatching(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+
+	create := func() *jobs.Job {
+		record := jobs.Record{
+			Details:  jobspb.ImportDetails{},
+			Progress: jobspb.ImportProgress{},
+			Username: username.TestUserName(),
+		}
+		j, err := registry.CreateJobWithTxn(ctx, record, registry.MakeJobID(), nil /* txn */)
+		require.NoError(t, err)
+		return j
+	}
+	status := func(id jobspb.JobID) jobs.Status {
+		j, err := registry.LoadJob(ctx, id)
+		require.NoError(t, err)
+		return j.Status()
+	}
+
+	old1, old2 := create(), create()
+	succeeded := create()
+	require.NoError(t, registry.Succeeded(ctx, nil /* txn */, succeeded.ID()))
+	reverting := create()
+	require.NoError(t, reverting.NoTxn().SetStatusWithReason(ctx, jobs.StatusReverting, ""))
+	time.Sleep(time.Millisecond)
+	cutoff := timeutil.Now()
+	time.Sleep(time.Millisecond)
+	recent := create()
+
+	// Only jobs in the given statuses match.
+	canceled, err := registry.CancelMatching(ctx, jobs.JobFilter{
+		Type:          jobspb.TypeImport,
+		CreatedBefore: cutoff,
+		Statuses:      []jobs.Status{jobs.StatusPaused},
+	})
+	require.NoError(t, err)
+	require.Empty(t, canceled)
+
+	canceled, err = registry.CancelMatching(ctx, jobs.JobFilter{
+		Type:          jobspb.TypeImport,
+		CreatedBefore: cutoff,
+	})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []jobspb.JobID{old1.ID(), old2.ID()}, canceled)
+	require.Equal(t, jobs.StatusCancelRequested, status(old1.ID()))
+	require.Equal(t, jobs.StatusCancelRequested, status(old2.ID()))
+	// Finished jobs and jobs which cannot be canceled are skipped.
+	require.Equal(t, jobs.StatusSucceeded, status(succeeded.ID()))
+	require.Equal(t, jobs.StatusReverting, status(reverting.ID()))
+	require.Equal(t, jobs.StatusRunning, status(recent.ID()))
+
+	canceled, err = registry.CancelMatching(ctx, jobs.JobFilter{
+		Type:          jobspb.TypeImport,
+		CreatedBefore: cutoff,
+	})
+	require.NoError(t, err)
+	require.Empty(t, canceled)
+}
+
+func TestJobsForDescriptor(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+
+	create := func(ids ...descpb.ID) jobspb.JobID {
+		record := jobs.Record{
+			DescriptorIDs: ids,
+			Details:       jobspb.ImportDetails{},
+			Progress:      jobspb.ImportProgress{},
+			Username:      username.TestUserName(),
+		}
+		j, err := registry.CreateJobWithTxn(ctx, record, registry.MakeJobID(), nil /* txn */)
+		require.NoError(t, err)
+		return j.ID()
+	}
+	const descID = descpb.ID(1000)
+	first := create(descID-1, descID)
+	create(descID + 1)
+	second := create(descID)
+
+	found, err := registry.JobsForDescriptor(ctx, descID)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []jobspb.JobID{first, second}, found)
+
+	found, err = registry.JobsForDescriptor(ctx, descID+2)
+	require.NoError(t, err)
+	require.Empty(t, found)
+}
+
+func TestJobsNeedingAttention(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+
+	record := jobs.Record{
+		Details:  jobspb.ImportDetails{},
+		Progress: jobspb.ImportProgress{},
+		Username: username.TestUserName(),
+	}
+	flagged, err := registry.CreateJobWithTxn(ctx, record, registry.MakeJobID(), nil /* txn */)
+	require.NoError(t, err)
+	other, err := registry.CreateJobWithTxn(ctx, record, registry.MakeJobID(), nil /* txn */)
+	require.NoError(t, err)
+
+	ids, err := registry.JobsNeedingAttention(ctx)
+	require.NoError(t, err)
+	require.Empty(t, ids)
+
+	require.NoError(t, flagged.NoTxn().FlagForAttention(ctx, "source bucket is unreadable"))
+	ids, err = registry.JobsNeedingAttention(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []jobspb.JobID{flagged.ID()}, ids)
+
+	reason, at, ok, err := flagged.AttentionFlag(ctx)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "source bucket is unreadable", reason)
+	require.False(t, at.IsZero())
+	_, _, ok, err = other.AttentionFlag(ctx)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// Flagging does not otherwise affect the job.
+	loaded, err := registry.LoadJob(ctx, flagged.ID())
+	require.NoError(t, err)
+	require.Equal(t, jobs.StatusRunning, loaded.Status())
+
+	require.NoError(t, flagged.NoTxn().ClearAttentionFlag(ctx))
+	ids, err = registry.JobsNeedingAttention(ctx)
+	require.NoError(t, err)
+	require.Empty(t, ids)
+	_, _, ok, err = flagged.AttentionFlag(ctx)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// Terminal jobs are not listed, flagged or not.
+	require.NoError(t, other.NoTxn().FlagForAttention(ctx, "destination is full"))
+	require.NoError(t, other.NoTxn().Update(ctx, func(
+		_ isql.Txn, _ jobs.JobMetadata, ju *jobs.JobUpdater,
+	) error {
+		ju.UpdateStatus(jobs.StatusCanceled)
+		return nil
+	}))
+	ids, err = registry.JobsNeedingAttention(ctx)
+	require.NoError(t, err)
+	require.Empty(t, ids)
+}
+
+func TestLoadMetadataProjected(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s, sqlDB, _ := serverutils.StartServer(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	tdb := sqlutils.MakeSQLRunner(sqlDB)
+
+	record := jobs.Record{
+		Description: "projected",
+		Details:     jobspb.ImportDetails{},
+		Progress:    jobspb.ImportProgress{},
+		Username:    username.TestUserName(),
+	}
+	j, err := registry.CreateJobWithTxn(ctx, record, registry.MakeJobID(), nil /* txn */)
+	require.NoError(t, err)
+	require.NoError(t, j.NoTxn().FractionProgressed(ctx, jobs.FractionUpdater(0.5)))
+
+	md, err := registry.LoadMetadataProjected(ctx, j.ID(), jobs.MetadataAll)
+	require.NoError(t, err)
+	require.Equal(t, jobs.StatusRunning, md.Status)
+	require.Equal(t, "projected", md.Payload.Description)
+	require.Equal(t, float32(0.5), md.Progress.GetFractionCompleted())
+	require.NotNil(t, md.RunStats)
+
+	md, err = registry.LoadMetadataProjected(ctx, j.ID(), jobs.MetadataProgress)
+	require.NoError(t, err)
+	require.Equal(t, jobs.Status(""), md.Status)
+	require.Nil(t, md.Payload)
+	require.Nil(t, md.RunStats)
+	require.Equal(t, float32(0.5), md.Progress.GetFractionCompleted())
+
+	// Without its job_info records, only projections which do not read them
+	// can be loaded.
+	tdb.Exec(t, `DELETE FROM system.job_info WHERE job_id = $1`, j.ID())
+	md, err = registry.LoadMetadataProjected(ctx, j.ID(), jobs.MetadataStatus|jobs.MetadataRunStats)
+	require.NoError(t, err)
+	require.Equal(t, jobs.StatusRunning, md.Status)
+	require.NotNil(t, md.RunStats)
+	require.Nil(t, md.Payload)
+	require.Nil(t, md.Progress)
+	_, err = registry.LoadMetadataProjected(ctx, j.ID(), jobs.MetadataPayload)
+	require.True(t, jobs.HasJobNotFoundError(err), "unexpected error: %v", err)
+
+	_, err = registry.LoadMetadataProjected(ctx, registry.MakeJobID(), jobs.MetadataStatus)
+	require.True(t, jobs.HasJobNotFoundError(err), "unexpected error: %v", err)
+}
+
+func TestMergeProgress(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+
+	create := func(spans ...roachpb.Span) jobspb.JobID {
+		j, err := registry.CreateJobWithTxn(ctx, jobs.Record{
+			Details:  jobspb.ImportDetails{},
+			Progress: jobspb.ImportProgress{SpanProgress: spans},
+			Username: username.TestUserName(),
+		}, registry.MakeJobID(), nil /* txn */)
+		require.NoError(t, err)
+		return j.ID()
+	}
+	span := func(start, end string) roachpb.Span {
+		return roachpb.Span{Key: roachpb.Key(start), EndKey: roachpb.Key(end)}
+	}
+	load := func(id jobspb.JobID) *jobs.Job {
+		j, err := registry.LoadJob(ctx, id)
+		require.NoError(t, err)
+		return j
+	}
+	completedSpans := func(id jobspb.JobID) []roachpb.Span {
+		p := load(id).Progress()
+		return p.GetImport().SpanProgress
+	}
+
+	t.Run("union", func(t *testing.T) {
+		into := create(span("a", "b"))
+		from1 := create(span("b", "c"), span("x", "z"))
+		from2 := create(span("m", "n"))
+		require.NoError(t, registry.MergeProgress(ctx, into, []jobspb.JobID{from1, from2}))
+		require.Equal(t, []roachpb.Span{span("a", "c"), span("m", "n"), span("x", "z")}, completedSpans(into))
+		// The sources are left alone.
+		require.Equal(t, []roachpb.Span{span("m", "n")}, completedSpans(from2))
+		require.Equal(t, jobs.StatusRunning, load(from1).Status())
+	})
+
+	t.Run("fraction and completion", func(t *testing.T) {
+		into := create(span("a", "b"))
+		from := create(span("m", "n"), span("x", "z"))
+		require.NoError(t, registry.MergeProgressWithOptions(ctx, into, []jobspb.JobID{from}, jobs.MergeProgressOptions{
+			WorkUnits:       []roachpb.Span{span("a", "b"), span("c", "d"), span("m", "n"), span("x", "z")},
+			CompleteSources: true,
+		}))
+		require.Equal(t, float32(0.75), load(into).FractionCompleted())
+
+		// A work unit which is only partially completed does not count.
+		into = create(span("a", "b"))
+		from = create(span("m", "n"))
+		require.NoError(t, registry.MergeProgressWithOptions(ctx, into, []jobspb.JobID{from}, jobs.MergeProgressOptions{
+			WorkUnits: []roachpb.Span{span("a", "c"), span("m", "n")},
+		}))
+		require.Equal(t, float32(0.5), load(into).FractionCompleted())
+		require.Equal(t, jobs.StatusSucceeded, load(from).Status())
+	})
+
+	t.Run("missing source", func(t *testing.T) {
+		into := create(span("a", "b"))
+		from := create(span("c", "d"))
+		err := registry.MergeProgress(ctx, into, []jobspb.JobID{from, registry.MakeJobID()})
+		require.True(t, jobs.HasJobNotFoundError(err), "unexpected error: %v", err)
+		require.Equal(t, []roachpb.Span{span("a", "b")}, completedSpans(into))
+	})
+}
+
+func TestGenerateReport(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s, sqlDB, _ := serverutils.StartServer(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	tdb := sqlutils.MakeSQLRunner(sqlDB)
+	tdb.Exec(t, `SET CLUSTER SETTING jobs.report.stall_threshold = '1h'`)
+
+	create := func() *jobs.Job {
+		record := jobs.Record{
+			Details:  jobspb.ImportDetails{},
+			Progress: jobspb.ImportProgress{},
+			Username: username.TestUserName(),
+		}
+		j, err := registry.CreateJobWithTxn(ctx, record, registry.MakeJobID(), nil /* txn */)
+		require.NoError(t, err)
+		return j
+	}
+	// The stalled job was created long ago and last checkpointed two hours
+	// ago.
+	stalled := create()
+	tdb.Exec(t, `UPDATE system.jobs SET created = now() - '2 days'::INTERVAL WHERE id = $1`, stalled.ID())
+	tdb.Exec(t, `UPDATE system.job_info SET written = now() - '2 hours'::INTERVAL WHERE job_id = $1 AND info_key = $2`,
+		stalled.ID(), jobs.LegacyProgressKey)
+	// The live job was created long ago too, but heartbeats.
+	live := create()
+	tdb.Exec(t, `UPDATE system.jobs SET created = now() - '1 day'::INTERVAL WHERE id = $1`, live.ID())
+	tdb.Exec(t, `UPDATE system.job_info SET written = now() - '2 hours'::INTERVAL WHERE job_id = $1 AND info_key = $2`,
+		live.ID(), jobs.LegacyProgressKey)
+	require.NoError(t, live.NoTxn().Heartbeat(ctx))
+	paused := create()
+	require.NoError(t, paused.NoTxn().SetStatusWithReason(ctx, jobs.StatusPaused, "report"))
+	succeeded := create()
+	require.NoError(t, registry.Succeeded(ctx, nil /* txn */, succeeded.ID()))
+
+	report, err := registry.GenerateReport(ctx)
+	require.NoError(t, err)
+	require.Equal(t, map[jobs.Status]int{
+		jobs.StatusRunning:   2,
+		jobs.StatusPaused:    1,
+		jobs.StatusSucceeded: 1,
+	}, report.Counts[jobspb.TypeImport.String()])
+	require.NotNil(t, report.OldestRunning)
+	require.Equal(t, stalled.ID(), report.OldestRunning.ID)
+	require.Equal(t, jobspb.TypeImport.String(), report.OldestRunning.Type)
+	require.Equal(t, 1, report.Stalled)
+	// The paused job entered its status when it was paused, not when it was
+	// created.
+	require.Less(t, report.AverageTimeInStatus[jobs.StatusPaused], time.Hour)
+	require.Greater(t, report.AverageTimeInStatus[jobs.StatusRunning], time.Hour)
+	require.NotContains(t, report.AverageTimeInStatus, jobs.StatusSucceeded)
+
+	encoded, err := json.Marshal(report)
+	require.NoError(t, err)
+	var decoded jobs.Report
+	require.NoError(t, json.Unmarshal(encoded, &decoded))
+	require.Equal(t, report.Counts, decoded.Counts)
+	require.Equal(t, report.Stalled, decoded.Stalled)
+	require.Equal(t, report.OldestRunning.ID, decoded.OldestRunning.ID)
+}
+
+func TestUpdateBatch(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+
+	var ids []jobspb.JobID
+	for i := 0; i < 3; i++ {
+		record := jobs.Record{
+			Details:  jobspb.ImportDetails{},
+			Progress: jobspb.ImportProgress{},
+			Username: username.TestUserName(),
+		}
+		j, err := registry.CreateJobWithTxn(ctx, record, registry.MakeJobID(), nil /* txn */)
+		require.NoError(t, err)
+		ids = append(ids, j.ID())
+	}
+	load := func(id jobspb.JobID) *jobs.Job {
+		j, err := registry.LoadJob(ctx, id)
+		require.NoError(t, err)
+		return j
+	}
+
+	require.NoError(t, registry.UpdateBatch(ctx, ids, func(
+		_ isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater,
+	) error {
+		ju.UpdateStatus(jobs.StatusPaused)
+		md.Progress.Progress = &jobspb.Progress_FractionCompleted{FractionCompleted: 0.5}
+		ju.UpdateProgress(md.Progress)
+		return nil
+	}))
+	for _, id := range ids {
+		j := load(id)
+		require.Equal(t, jobs.StatusPaused, j.Status())
+		require.Equal(t, float32(0.5), j.FractionCompleted())
+		history, err := j.StatusHistory(ctx)
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+		require.Equal(t, jobs.StatusPaused, history[0].To)
+	}
+
+	// A failure for any job rolls back the updates of all of them.
+	err := registry.UpdateBatch(ctx, ids, func(
+		_ isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater,
+	) error {
+		if md.ID == ids[1] {
+			return errors.New("boom")
+		}
+		ju.UpdateStatus(jobs.StatusRunning)
+		return nil
+	})
+	require.ErrorContains(t, err, "boom")
+	for _, id := range ids {
+		require.Equal(t, jobs.StatusPaused, load(id).Status())
+	}
+
+	// Jobs that no longer have the expected status fail the whole batch.
+	require.NoError(t, load(ids[2]).NoTxn().Update(ctx, func(
+		_ isql.Txn, _ jobs.JobMetadata, ju *jobs.JobUpdater,
+	) error {
+		ju.UpdateStatus(jobs.StatusRunning)
+		return nil
+	}))
+	err = registry.UpdateBatch(ctx, ids, func(
+		txn isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater,
+	) error {
+		if md.ID == ids[2] {
+			// Simulate a concurrent transition of the last job after it was
+			// loaded.
+			if _, err := txn.ExecEx(
+				ctx, "concurrent-status-change", txn.KV(),
+				sessiondata.NodeUserSessionDataOverride,
+				`UPDATE system.jobs SET status = $2 WHERE id = $1`, md.ID, jobs.StatusPaused,
+			); err != nil {
+				return err
+			}
+		}
+		ju.UpdateStatusCAS(md.Status, jobs.StatusCancelRequested)
+		return nil
+	})
+	require.True(t, errors.Is(err, jobs.ErrConcurrentStatusChange), "unexpected error: %v", err)
+	require.Equal(t, jobs.StatusPaused, load(ids[0]).Status())
+
+	err = registry.UpdateBatch(ctx, append(ids, registry.MakeJobID()), func(
+		isql.Txn, jobs.JobMetadata, *jobs.JobUpdater,
+	) error {
+		return nil
+	})
+	require.True(t, jobs.HasJobNotFoundError(err), "unexpected error: %v", err)
+}
+
+func TestLoadMetadataBatch(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+
+	var ids []jobspb.JobID
+	for i := 0; i < 3; i++ {
+		record := jobs.Record{
+			Details:  jobspb.ImportDetails{},
+			Progress: jobspb.ImportProgress{},
+			Username: username.TestUserName(),
+		}
+		j, err := registry.CreateJobWithTxn(ctx, record, registry.MakeJobID(), nil /* txn */)
+		require.NoError(t, err)
+		require.NoError(t, j.NoTxn().FractionProgressed(ctx, jobs.FractionUpdater(float32(i)/10)))
+		ids = append(ids, j.ID())
+	}
+
+	missing := registry.MakeJobID()
+	mds, err := registry.LoadMetadataBatch(ctx, append(ids, missing))
+	require.NoError(t, err)
+	require.Len(t, mds, len(ids))
+	require.NotContains(t, mds, missing)
+	for _, id := range ids {
+		j, err := registry.LoadJob(ctx, id)
+		require.NoError(t, err)
+		md, err := j.NoTxn().LoadMetadata(ctx)
+		require.NoError(t, err)
+		require.Equal(t, md, mds[id])
+	}
+}
+
+func TestListJobs(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+
+	var paused []jobspb.JobID
+	for i := 0; i < 5; i++ {
+		record := jobs.Record{
+			Details:  jobspb.ImportDetails{},
+			Progress: jobspb.ImportProgress{},
+			Username: username.TestUserName(),
+		}
+		j, err := registry.CreateJobWithTxn(ctx, record, registry.MakeJobID(), nil /* txn */)
+		require.NoError(t, err)
+		if i%2 == 0 {
+			require.NoError(t, j.NoTxn().Update(ctx, func(
+				_ isql.Txn, _ jobs.JobMetadata, ju *jobs.JobUpdater,
+			) error {
+				ju.UpdateStatus(jobs.StatusPaused)
+				return nil
+			}))
+			paused = append(paused, j.ID())
+		}
+	}
+
+	filter := jobs.JobFilter{Type: jobspb.TypeImport, Statuses: []jobs.Status{jobs.StatusPaused}}
+	var listed []jobspb.JobID
+	var token string
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 3)
+		ids, next, err := registry.ListJobs(ctx, filter, token, 2 /* limit */)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(ids), 2)
+		listed = append(listed, ids...)
+		if next == "" {
+			break
+		}
+		token = next
+	}
+	require.Equal(t, paused, listed)
+
+	// The creation time of the jobs can be filtered on too.
+	ids, _, err := registry.ListJobs(ctx, jobs.JobFilter{
+		Type:         jobspb.TypeImport,
+		CreatedAfter: timeutil.Now().Add(time.Hour),
+	}, "", 10 /* limit */)
+	require.NoError(t, err)
+	require.Empty(t, ids)
+
+	_, _, err = registry.ListJobs(ctx, filter, "not a token", 2 /* limit */)
+	require.ErrorContains(t, err, "decoding page token")
+}
+
+func TestPayloadCompression(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s, sqlDB, _ := serverutils.StartServer(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	tdb := sqlutils.MakeSQLRunner(sqlDB)
+
+	create := func(description string) *jobs.Job {
+		record := jobs.Record{
+			Description: description,
+			Details:     jobspb.ImportDetails{},
+			Progress:    jobspb.ImportProgress{},
+			Username:    username.TestUserName(),
+		}
+		j, err := registry.CreateJobWithTxn(ctx, record, registry.MakeJobID(), nil /* txn */)
+		require.NoError(t, err)
+		return j
+	}
+	rawPayload := func(id jobspb.JobID) (value []byte) {
+		tdb.QueryRow(t, `SELECT value FROM system.job_info WHERE job_id = $1 AND info_key = $2`,
+			id, jobs.LegacyPayloadKey).Scan(&value)
+		return value
+	}
+	isCompressed := func(value []byte) bool {
+		return bytes.HasPrefix(value, []byte{0x1f, 0x8b})
+	}
+	large := strings.Repeat("a very long description ", 1000)
+
+	// Payloads are written uncompressed by default.
+	uncompressed := create(large)
+	require.False(t, isCompressed(rawPayload(uncompressed.ID())))
+
+	tdb.Exec(t, `SET CLUSTER SETTING jobs.payload.compression.enabled = true`)
+	tdb.Exec(t, `SET CLUSTER SETTING jobs.payload.compression.threshold = '4KiB'`)
+
+	// Only payloads above the threshold are compressed.
+	small := create("short")
+	require.False(t, isCompressed(rawPayload(small.ID())))
+	compressed := create(large)
+	require.True(t, isCompressed(rawPayload(compressed.ID())))
+	require.Less(t, len(rawPayload(compressed.ID())), len(large))
+
+	// Compressed and uncompressed payloads are read transparently.
+	for _, j := range []*jobs.Job{uncompressed, small, compressed} {
+		loaded, err := registry.LoadJob(ctx, j.ID())
+		require.NoError(t, err)
+		require.Equal(t, j.Payload().Description, loaded.Payload().Description)
+		md, err := loaded.NoTxn().LoadMetadata(ctx)
+		require.NoError(t, err)
+		require.Equal(t, j.Payload().Description, md.Payload.Description)
+	}
+	payload, err := jobs.UnmarshalPayload(tree.NewDBytes(tree.DBytes(rawPayload(compressed.ID()))))
+	require.NoError(t, err)
+	require.Equal(t, large, payload.Description)
+
+	// SQL readers of the payload decode compressed payloads too.
+	var description string
+	tdb.QueryRow(t, `
+SELECT crdb_internal.pb_to_json('cockroach.sql.jobs.jobspb.Payload', `+jobs.DecodePayloadSQL("value")+`)->>'description'
+  FROM system.job_info WHERE job_id = $1 AND info_key = $2`,
+		compressed.ID(), jobs.LegacyPayloadKey).Scan(&description)
+	require.Equal(t, large, description)
+	tdb.QueryRow(t, `
+SELECT crdb_internal.pb_to_json('cockroach.sql.jobs.jobspb.Payload', `+jobs.DecodePayloadSQL("value")+`)->>'description'
+  FROM system.job_info WHERE job_id = $1 AND info_key = $2`,
+		small.ID(), jobs.LegacyPayloadKey).Scan(&description)
+	require.Equal(t, "short", description)
+	var systemJobsPayload []byte
+	tdb.QueryRow(t, `SELECT payload FROM crdb_internal.system_jobs WHERE id = $1`,
+		compressed.ID()).Scan(&systemJobsPayload)
+	require.False(t, isCompressed(systemJobsPayload))
+
+	// Updates of the payload are compressed too.
+	require.NoError(t, uncompressed.NoTxn().Update(ctx, func(
+		_ isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater,
+	) error {
+		md.Payload.Description += "."
+		ju.UpdatePayload(md.Payload)
+		return nil
+	}))
+	require.True(t, isCompressed(rawPayload(uncompressed.ID())))
+	tdb.Exec(t, `SET CLUSTER SETTING jobs.payload.compression.enabled = false`)
+	require.NoError(t, compressed.NoTxn().Update(ctx, func(
+		_ isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater,
+	) error {
+		md.Payload.Description += "."
+		ju.UpdatePayload(md.Payload)
+		return nil
+	}))
+	require.False(t, isCompressed(rawPayload(compressed.ID())))
+}
@@ -48,6 +48,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/util/log"
 	"github.com/cockroachdb/cockroach/pkg/util/metric"
 	"github.com/cockroachdb/cockroach/pkg/util/protoutil"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
 	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
 	"github.com/cockroachdb/errors"
 	"github.com/stretchr/testify/assert"
@@ -1754,3 +1755,574 @@ func TestGetClaimedResumerFromRegistry(t *testing.T) {
 	require.NoError(t, resumer.OnFailOrCancel(ctx, nil, nil))
 	require.Equal(t, 1, counter)
 }
+
+// startManualClaimServer starts a server whose registry neither claims jobs
+// nor releases claims on its own, so that tests can drive claimJobs directly.
+func startManualClaimServer(
+	t *testing.T,
+) (serverutils.TestServerInterface, *Registry, *sqlutils.SQLRunner) {
+	knobs := NewTestingKnobsWithIntervals(10*time.Hour, 10*time.Hour, time.Second, time.Second)
+	knobs.DisableAdoptions = true
+	args := base.TestServerArgs{
+		Knobs: base.TestingKnobs{
+			JobsTestingKnobs: knobs,
+			// DisableAdoptions needs this.
+			UpgradeManager: &upgradebase.TestingKnobs{
+				DontUseJobs:                       true,
+				SkipJobMetricsPollingJobBootstrap: true,
+			},
+			KeyVisualizer: &keyvisualizer.TestingKnobs{
+				SkipJobBootstrap: true,
+			},
+		},
+	}
+	s, sqlDB, _ := serverutils.StartServer(t, args)
+	return s, s.JobRegistry().(*Registry), sqlutils.MakeSQLRunner(sqlDB)
+}
+
+// TestClaimJobsRespectsPriority verifies that the adoption loop claims jobs
+// with a higher priority before otherwise-equal jobs.
+func TestClaimJobsRespectsPriority(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s, r, tdb := startManualClaimServer(t)
+	defer s.Stopper().Stop(ctx)
+
+	defer func(prev int) { maxAdoptionsPerLoop = prev }(maxAdoptionsPerLoop)
+	maxAdoptionsPerLoop = 1
+
+	record := Record{
+		Details:  jobspb.ImportDetails{},
+		Progress: jobspb.ImportProgress{},
+		Username: username.TestUserName(),
+	}
+	var created []*Job
+	for i := 0; i < 3; i++ {
+		j, err := r.CreateAdoptableJobWithTxn(ctx, record, r.MakeJobID(), nil /* txn */)
+		require.NoError(t, err)
+		created = append(created, j)
+	}
+	// Make the jobs newer than any other unclaimed job, except for the one with
+	// the highest priority, which is older than all of them, and which must be
+	// claimed first nonetheless. A bytewise comparison of the priorities would
+	// order 9 before 12.
+	for _, j := range created {
+		tdb.Exec(t, `UPDATE system.jobs SET created = now() + '1h' WHERE id = $1`, j.ID())
+	}
+	tdb.Exec(t, `UPDATE system.jobs SET created = now() - '1h' WHERE id = $1`, created[0].ID())
+	require.NoError(t, created[0].NoTxn().SetPriority(ctx, 12))
+	require.NoError(t, created[2].NoTxn().SetPriority(ctx, 9))
+
+	isClaimed := func(j *Job) bool {
+		var claimed bool
+		tdb.QueryRow(t, `SELECT claim_session_id IS NOT NULL FROM system.jobs WHERE id = $1`,
+			j.ID()).Scan(&claimed)
+		return claimed
+	}
+	session, err := r.sqlInstance.Session(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, r.claimJobs(ctx, session))
+	require.True(t, isClaimed(created[0]))
+	require.False(t, isClaimed(created[1]))
+	require.False(t, isClaimed(created[2]))
+
+	require.NoError(t, r.claimJobs(ctx, session))
+	require.True(t, isClaimed(created[2]))
+	require.False(t, isClaimed(created[1]))
+
+	// Negative priorities are rejected, and malformed ones don't prevent
+	// adoption.
+	require.Error(t, created[1].NoTxn().SetPriority(ctx, -1))
+	tdb.Exec(t, `INSERT INTO system.job_info (job_id, info_key, value) VALUES ($1, 'priority', 'x')`,
+		created[1].ID())
+	require.NoError(t, r.claimJobs(ctx, session))
+	require.True(t, isClaimed(created[1]))
+}
+
+// TestResumeSkipsJobsRequiringNewerBinary verifies that a node does not resume
+// jobs which require a newer binary than the local one, and releases its claim
+// on them instead.
+func TestResumeSkipsJobsRequiringNewerBinary(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s, r, tdb := startManualClaimServer(t)
+	defer s.Stopper().Stop(ctx)
+
+	record := Record{
+		Details:  jobspb.ImportDetails{},
+		Progress: jobspb.ImportProgress{},
+		Username: username.TestUserName(),
+	}
+	createJob := func() *Job {
+		j, err := r.CreateAdoptableJobWithTxn(ctx, record, r.MakeJobID(), nil /* txn */)
+		require.NoError(t, err)
+		return j
+	}
+	local := r.settings.Version.LatestVersion()
+	newer := local
+	newer.Internal += 2
+
+	gated := createJob()
+	require.NoError(t, gated.NoTxn().RequireBinaryVersion(ctx, newer))
+	compatible := createJob()
+	require.NoError(t, compatible.NoTxn().RequireBinaryVersion(ctx, local))
+	ungated := createJob()
+
+	session, err := r.sqlInstance.Session(ctx)
+	require.NoError(t, err)
+	isClaimed := func(j *Job) bool {
+		var claimed bool
+		tdb.QueryRow(t, `SELECT claim_session_id IS NOT NULL FROM system.jobs WHERE id = $1`,
+			j.ID()).Scan(&claimed)
+		return claimed
+	}
+	for _, j := range []*Job{gated, compatible, ungated} {
+		tdb.Exec(t, `UPDATE system.jobs SET claim_session_id = $2, claim_instance_id = $3 WHERE id = $1`,
+			j.ID(), session.ID().UnsafeBytes(), r.ID())
+	}
+
+	loaded, err := r.loadJobForResume(ctx, gated.ID(), session)
+	require.NoError(t, err)
+	require.Nil(t, loaded)
+	require.False(t, isClaimed(gated))
+
+	for _, j := range []*Job{compatible, ungated} {
+		loaded, err := r.loadJobForResume(ctx, j.ID(), session)
+		require.NoError(t, err)
+		require.NotNil(t, loaded)
+		require.True(t, isClaimed(j))
+	}
+}
+
+// TestRunStatsHistory verifies that bumps of a job's run stats are journaled,
+// in order, up to the configured number of samples.
+func TestRunStatsHistory(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s, r, _ := startManualClaimServer(t)
+	defer s.Stopper().Stop(ctx)
+
+	record := Record{
+		Details:  jobspb.ImportDetails{},
+		Progress: jobspb.ImportProgress{},
+		Username: username.TestUserName(),
+	}
+	j, err := r.CreateJobWithTxn(ctx, record, r.MakeJobID(), nil /* txn */)
+	require.NoError(t, err)
+
+	bump := func() {
+		require.NoError(t, j.NoTxn().Update(ctx, func(_ isql.Txn, md JobMetadata, ju *JobUpdater) error {
+			ju.UpdateRunStats(md.RunStats.NumRuns+1, r.clock.Now().GoTime())
+			return nil
+		}))
+	}
+
+	// History is not recorded by default.
+	bump()
+	history, err := j.RunStatsHistory(ctx)
+	require.NoError(t, err)
+	require.Empty(t, history)
+
+	runStatsHistoryMaxSamples.Override(ctx, &s.ClusterSettings().SV, 3)
+	for i := 0; i < 4; i++ {
+		bump()
+	}
+	history, err = j.RunStatsHistory(ctx)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	for i, sample := range history {
+		require.Equal(t, i+3, sample.NumRuns)
+		if i > 0 {
+			require.False(t, sample.Time.Before(history[i-1].Time))
+		}
+	}
+}
+
+// TestJobLog verifies that entries appended to a job's log are returned in
+// order, with long messages truncated and old entries dropped.
+func TestJobLog(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s, r, tdb := startManualClaimServer(t)
+	defer s.Stopper().Stop(ctx)
+	jobLogMaxEntries.Override(ctx, &s.ClusterSettings().SV, 3)
+
+	record := Record{
+		Details:  jobspb.ImportDetails{},
+		Progress: jobspb.ImportProgress{},
+		Username: username.TestUserName(),
+	}
+	j, err := r.CreateJobWithTxn(ctx, record, r.MakeJobID(), nil /* txn */)
+	require.NoError(t, err)
+
+	appendEntries := func(messages ...string) {
+		require.NoError(t, j.NoTxn().Update(ctx, func(_ isql.Txn, _ JobMetadata, ju *JobUpdater) error {
+			for _, m := range messages {
+				if err := ju.AppendLogEntry(JobLogEntry{Level: "INFO", Message: m}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}))
+	}
+	messages := func() []string {
+		entries, err := j.LogEntries(ctx)
+		require.NoError(t, err)
+		var res []string
+		for i, e := range entries {
+			require.Equal(t, "INFO", e.Level)
+			require.False(t, e.Timestamp.IsZero())
+			if i > 0 {
+				require.False(t, e.Timestamp.Before(entries[i-1].Timestamp))
+			}
+			res = append(res, e.Message)
+		}
+		return res
+	}
+
+	appendEntries("a", "b")
+	require.Equal(t, []string{"a", "b"}, messages())
+	appendEntries("c", "d")
+	require.Equal(t, []string{"b", "c", "d"}, messages())
+
+	// The entries can be read from SQL, in order, by decoding their values as
+	// JSON.
+	require.Equal(t, [][]string{{"b"}, {"c"}, {"d"}}, tdb.QueryStr(t, `
+SELECT convert_from(value, 'UTF8')::JSONB->>'message' FROM system.job_info
+ WHERE job_id = $1 AND info_key LIKE '`+JobLogEntryPrefix+`%' ORDER BY info_key`, j.ID()))
+
+	appendEntries(strings.Repeat("x", 2*maxJobLogMessageRunes))
+	got := messages()
+	require.Len(t, got, 3)
+	require.Len(t, got[2], maxJobLogMessageRunes)
+
+	jobLogMaxEntries.Override(ctx, &s.ClusterSettings().SV, 0)
+	appendEntries("e")
+	require.Empty(t, messages())
+}
+
+// TestProgressLineage verifies that retained versions of a job's progress are
+// returned newest-first with their written timestamps.
+func TestProgressLineage(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s, r, tdb := startManualClaimServer(t)
+	defer s.Stopper().Stop(ctx)
+	progressLineageRetainedVersions.Override(ctx, &s.ClusterSettings().SV, 3)
+
+	record := Record{
+		Details:  jobspb.ImportDetails{},
+		Progress: jobspb.ImportProgress{},
+		Username: username.TestUserName(),
+	}
+	j, err := r.CreateJobWithTxn(ctx, record, r.MakeJobID(), nil /* txn */)
+	require.NoError(t, err)
+	for _, f := range []float32{0.1, 0.2, 0.3, 0.4} {
+		require.NoError(t, j.NoTxn().FractionProgressed(ctx, FractionUpdater(f)))
+	}
+
+	lineage, err := j.ProgressLineage(ctx, 10 /* limit */)
+	require.NoError(t, err)
+	require.Len(t, lineage, 3)
+	for i, f := range []float32{0.4, 0.3, 0.2} {
+		require.Equal(t, f, lineage[i].Progress.GetFractionCompleted())
+		if i > 0 {
+			require.True(t, lineage[i].Written.Before(lineage[i-1].Written))
+		}
+	}
+
+	lineage, err = j.ProgressLineage(ctx, 1 /* limit */)
+	require.NoError(t, err)
+	require.Len(t, lineage, 1)
+	require.Equal(t, float32(0.4), lineage[0].Progress.GetFractionCompleted())
+
+	// Readers of the progress observe the latest version, and only it.
+	loaded, err := r.LoadJob(ctx, j.ID())
+	require.NoError(t, err)
+	require.Equal(t, float32(0.4), loaded.FractionCompleted())
+	var n int
+	var fraction float64
+	tdb.QueryRow(t, `SELECT count(*), max(fraction_completed) FROM [SHOW JOBS] WHERE job_id = $1`,
+		j.ID()).Scan(&n, &fraction)
+	require.Equal(t, 1, n)
+	require.InDelta(t, 0.4, fraction, 0.001)
+	tdb.QueryRow(t, `SELECT count(*) FROM crdb_internal.system_jobs WHERE id = $1`, j.ID()).Scan(&n)
+	require.Equal(t, 1, n)
+}
+
+// TestMaxActivePerType verifies that the registry does not adopt more jobs of a
+// type than its cap, and adopts queued jobs once a running one finishes.
+func TestMaxActivePerType(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	started := make(chan jobspb.JobID, 10)
+	var mu syncutil.Mutex
+	release := make(map[jobspb.JobID]chan struct{})
+	releaseCh := func(id jobspb.JobID) chan struct{} {
+		mu.Lock()
+		defer mu.Unlock()
+		if _, ok := release[id]; !ok {
+			release[id] = make(chan struct{})
+		}
+		return release[id]
+	}
+	cleanup := TestingRegisterConstructor(jobspb.TypeImport, func(job *Job, cs *cluster.Settings) Resumer {
+		return jobstest.FakeResumer{
+			OnResume: func(ctx context.Context) error {
+				started <- job.ID()
+				select {
+				case <-releaseCh(job.ID()):
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			},
+		}
+	}, UsesTenantCostControl)
+	defer cleanup()
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, base.TestServerArgs{
+		Knobs: base.TestingKnobs{
+			JobsTestingKnobs: NewTestingKnobsWithShortIntervals(),
+		},
+	})
+	defer s.Stopper().Stop(ctx)
+	r := s.JobRegistry().(*Registry)
+	const maxActive = 2
+	r.WithMaxActivePerType(map[jobspb.Type]int{jobspb.TypeImport: maxActive})
+
+	record := Record{
+		Details:  jobspb.ImportDetails{},
+		Progress: jobspb.ImportProgress{},
+		Username: username.TestUserName(),
+	}
+	for i := 0; i < maxActive+1; i++ {
+		_, err := r.CreateAdoptableJobWithTxn(ctx, record, r.MakeJobID(), nil /* txn */)
+		require.NoError(t, err)
+	}
+
+	var running []jobspb.JobID
+	for len(running) < maxActive {
+		running = append(running, <-started)
+	}
+	select {
+	case id := <-started:
+		t.Fatalf("job %d adopted beyond the cap of %d", id, maxActive)
+	case <-time.After(3 * time.Second):
+	}
+
+	// Once a running job finishes, the queued one is adopted.
+	close(releaseCh(running[0]))
+	queued := <-started
+	require.NotContains(t, running, queued)
+
+	close(releaseCh(running[1]))
+	close(releaseCh(queued))
+}
+
+// TestMaxActivePerTypeIsClusterWide verifies that the adoption loop counts the
+// jobs claimed by other nodes towards the cap of their type, and leaves the
+// jobs over the cap unclaimed.
+func TestMaxActivePerTypeIsClusterWide(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s, r, tdb := startManualClaimServer(t)
+	defer s.Stopper().Stop(ctx)
+	r.WithMaxActivePerType(map[jobspb.Type]int{jobspb.TypeImport: 1})
+
+	record := Record{
+		Details:  jobspb.ImportDetails{},
+		Progress: jobspb.ImportProgress{},
+		Username: username.TestUserName(),
+	}
+	running, err := r.CreateAdoptableJobWithTxn(ctx, record, r.MakeJobID(), nil /* txn */)
+	require.NoError(t, err)
+	queued, err := r.CreateAdoptableJobWithTxn(ctx, record, r.MakeJobID(), nil /* txn */)
+	require.NoError(t, err)
+	// Occupy the only slot for imports with a claim by another node.
+	tdb.Exec(t, `UPDATE system.jobs SET claim_session_id = 'other', claim_instance_id = 2 WHERE id = $1`,
+		running.ID())
+
+	isClaimed := func(j *Job) bool {
+		var claimed bool
+		tdb.QueryRow(t, `SELECT claim_session_id IS NOT NULL FROM system.jobs WHERE id = $1`,
+			j.ID()).Scan(&claimed)
+		return claimed
+	}
+	session, err := r.sqlInstance.Session(ctx)
+	require.NoError(t, err)
+	require.NoError(t, r.claimJobs(ctx, session))
+	require.False(t, isClaimed(queued))
+
+	// Once the running job finishes, the queued one is claimed.
+	tdb.Exec(t, `UPDATE system.jobs SET status = $2 WHERE id = $1`, running.ID(), StatusSucceeded)
+	require.NoError(t, r.claimJobs(ctx, session))
+	require.True(t, isClaimed(queued))
+}
+
+func TestExclusionGroups(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	started := make(chan jobspb.JobID, 10)
+	var mu syncutil.Mutex
+	release := make(map[jobspb.JobID]chan struct{})
+	releaseCh := func(id jobspb.JobID) chan struct{} {
+		mu.Lock()
+		defer mu.Unlock()
+		if _, ok := release[id]; !ok {
+			release[id] = make(chan struct{})
+		}
+		return release[id]
+	}
+	cleanup := TestingRegisterConstructor(jobspb.TypeImport, func(job *Job, cs *cluster.Settings) Resumer {
+		return jobstest.FakeResumer{
+			OnResume: func(ctx context.Context) error {
+				started <- job.ID()
+				select {
+				case <-releaseCh(job.ID()):
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			},
+		}
+	}, UsesTenantCostControl)
+	defer cleanup()
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, base.TestServerArgs{
+		Knobs: base.TestingKnobs{
+			JobsTestingKnobs: NewTestingKnobsWithShortIntervals(),
+		},
+	})
+	defer s.Stopper().Stop(ctx)
+	r := s.JobRegistry().(*Registry)
+
+	record := Record{
+		Details:  jobspb.ImportDetails{},
+		Progress: jobspb.ImportProgress{},
+		Username: username.TestUserName(),
+	}
+	create := func(group string) (id jobspb.JobID) {
+		require.NoError(t, r.db.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+			j, err := r.CreateAdoptableJobWithTxn(ctx, record, r.MakeJobID(), txn)
+			if err != nil {
+				return err
+			}
+			id = j.ID()
+			return j.WithTxn(txn).SetExclusionGroup(ctx, group)
+		}))
+		return id
+	}
+	backupA1 := create("backup db a")
+	backupA2 := create("backup db a")
+	backupB := create("backup db b")
+
+	// One job of each group runs.
+	running := []jobspb.JobID{<-started, <-started}
+	require.Contains(t, running, backupB)
+	var runningA, queuedA jobspb.JobID
+	if running[0] == backupA1 || running[1] == backupA1 {
+		runningA, queuedA = backupA1, backupA2
+	} else {
+		runningA, queuedA = backupA2, backupA1
+	}
+	require.Contains(t, running, runningA)
+	select {
+	case id := <-started:
+		t.Fatalf("job %d started while another job of its exclusion group is running", id)
+	case <-time.After(3 * time.Second):
+	}
+
+	// The queued job is left unclaimed, so that any node may adopt it once the
+	// group is free.
+	tdb := sqlutils.MakeSQLRunner(s.SQLConn(t))
+	testutils.SucceedsSoon(t, func() error {
+		var claimed bool
+		tdb.QueryRow(t, `SELECT claim_session_id IS NOT NULL FROM system.jobs WHERE id = $1`,
+			queuedA).Scan(&claimed)
+		if claimed {
+			return errors.Newf("job %d is claimed while it waits", queuedA)
+		}
+		return nil
+	})
+
+	// Once the running job of the group finishes, the queued one runs.
+	close(releaseCh(runningA))
+	require.Equal(t, queuedA, <-started)
+
+	close(releaseCh(queuedA))
+	close(releaseCh(backupB))
+
+	// Once the jobs of a group have finished, their holder rows are gone.
+	testutils.SucceedsSoon(t, func() error {
+		var n int
+		tdb.QueryRow(t, `SELECT count(*) FROM system.job_info WHERE job_id IN ($1, $2, $3) AND info_key = $4`,
+			backupA1, backupA2, backupB, exclusionGroupHolderKey).Scan(&n)
+		if n != 0 {
+			return errors.Newf("%d exclusion group holder rows remain", n)
+		}
+		return nil
+	})
+}
+
+// TestResumeBumpsEpochOnlyWhenRegistered verifies that adopting a job bumps its
+// resumption epoch only if its type was registered WithResumptionEpoch.
+func TestResumeBumpsEpochOnlyWhenRegistered(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s, r, tdb := startManualClaimServer(t)
+	defer s.Stopper().Stop(ctx)
+
+	session, err := r.sqlInstance.Session(ctx)
+	require.NoError(t, err)
+	resume := func(opts ...RegisterOption) *Job {
+		defer TestingRegisterConstructor(jobspb.TypeImport, func(*Job, *cluster.Settings) Resumer {
+			return jobstest.FakeResumer{}
+		}, append(opts, UsesTenantCostControl)...)()
+		j, err := r.CreateAdoptableJobWithTxn(ctx, Record{
+			Details:  jobspb.ImportDetails{},
+			Progress: jobspb.ImportProgress{},
+			Username: username.TestUserName(),
+		}, r.MakeJobID(), nil /* txn */)
+		require.NoError(t, err)
+		tdb.Exec(t, `UPDATE system.jobs SET claim_session_id = $2, claim_instance_id = $3 WHERE id = $1`,
+			j.ID(), session.ID().UnsafeBytes(), r.ID())
+		loaded, err := r.loadJobForResume(ctx, j.ID(), session)
+		require.NoError(t, err)
+		require.NotNil(t, loaded)
+		return loaded
+	}
+	epochRows := func(j *Job) int {
+		var n int
+		tdb.QueryRow(t, `SELECT count(*) FROM system.job_info WHERE job_id = $1 AND info_key = 'epoch'`,
+			j.ID()).Scan(&n)
+		return n
+	}
+
+	unfenced := resume()
+	require.Zero(t, unfenced.Epoch())
+	require.Zero(t, epochRows(unfenced))
+
+	fenced := resume(WithResumptionEpoch())
+	require.Equal(t, int64(1), fenced.Epoch())
+	require.Equal(t, 1, epochRows(fenced))
+}
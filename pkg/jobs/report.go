@@ -0,0 +1,149 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/jobs/jobspb"
+	"github.com/cockroachdb/cockroach/pkg/settings"
+	"github.com/cockroachdb/cockroach/pkg/sql/isql"
+	"github.com/cockroachdb/cockroach/pkg/sql/sem/tree"
+	"github.com/cockroachdb/cockroach/pkg/sql/sessiondata"
+	"github.com/cockroachdb/errors"
+)
+
+var reportStallThreshold = settings.RegisterDurationSetting(
+	settings.ApplicationLevel,
+	"jobs.report.stall_threshold",
+	"the duration after which a running job that has neither checkpointed its progress "+
+		"nor heartbeated is counted as stalled in job reports",
+	10*time.Minute,
+	settings.PositiveDuration,
+)
+
+// Report is a snapshot of the health of the jobs subsystem, as generated by
+// Registry.GenerateReport. It is meant to be serialized to JSON.
+type Report struct {
+	// Time is the time at which the report was generated.
+	Time time.Time `json:"time"`
+	// Counts holds the number of jobs of each type, by status.
+	Counts map[string]map[Status]int `json:"counts"`
+	// AverageTimeInStatus holds, for each non-terminal status, the average
+	// time for which the jobs currently in that status have been in it.
+	AverageTimeInStatus map[Status]time.Duration `json:"average_time_in_status"`
+	// OldestRunning is the running job that was created first, if any.
+	OldestRunning *ReportJob `json:"oldest_running,omitempty"`
+	// Stalled is the number of running jobs that have neither been resumed,
+	// checkpointed their progress nor heartbeated for longer than
+	// jobs.report.stall_threshold.
+	Stalled int `json:"stalled"`
+}
+
+// ReportJob identifies a job in a Report.
+type ReportJob struct {
+	ID      jobspb.JobID `json:"id"`
+	Type    string       `json:"type"`
+	Created time.Time    `json:"created"`
+}
+
+// GenerateReport aggregates the jobs in the jobs table into a Report. A job
+// is considered to have entered its current status at the time of the
+// matching entry of its status history or, lacking one, when it was created.
+// All jobs are scanned, so this should not be called on hot paths.
+func (r *Registry) GenerateReport(ctx context.Context) (_ Report, retErr error) {
+	const reportQuery = `
+SELECT j.id, j.job_type, j.status, j.created, h.value,
+       greatest(
+         j.last_run::TIMESTAMPTZ,
+         (SELECT max(written) FROM system.job_info
+           WHERE job_id = j.id AND info_key IN ('` + LegacyProgressKey + `', '` + heartbeatKey + `'))
+       )
+  FROM system.jobs AS j
+  LEFT JOIN system.job_info AS h ON h.job_id = j.id AND h.info_key = '` + statusHistoryKey + `'`
+
+	now := r.clock.Now().GoTime()
+	report := Report{
+		Time:                now,
+		Counts:              make(map[string]map[Status]int),
+		AverageTimeInStatus: make(map[Status]time.Duration),
+	}
+	stallThreshold := reportStallThreshold.Get(&r.settings.SV)
+	inStatus := make(map[Status]int)
+
+	it, err := r.db.Executor().QueryIteratorEx(
+		ctx, "generate-jobs-report", nil, /* txn */
+		sessiondata.NodeUserSessionDataOverride, reportQuery,
+	)
+	if err != nil {
+		return Report{}, errors.Wrap(err, "generating jobs report")
+	}
+	defer func(it isql.Rows) { retErr = errors.CombineErrors(retErr, it.Close()) }(it)
+
+	var ok bool
+	for ok, err = it.Next(ctx); ok; ok, err = it.Next(ctx) {
+		row := it.Cur()
+		id := jobspb.JobID(*row[0].(*tree.DInt))
+		var typ string
+		if row[1] != tree.DNull {
+			typ = string(tree.MustBeDString(row[1]))
+		}
+		status := Status(tree.MustBeDString(row[2]))
+		created := tree.MustBeDTimestamp(row[3]).Time
+
+		if report.Counts[typ] == nil {
+			report.Counts[typ] = make(map[Status]int)
+		}
+		report.Counts[typ][status]++
+
+		if !status.Terminal() {
+			entered := created
+			if row[4] != tree.DNull {
+				history, err := decodeStatusHistory([]byte(tree.MustBeDBytes(row[4])))
+				if err != nil {
+					return Report{}, errors.Wrapf(err, "job %d", id)
+				}
+				for i := len(history) - 1; i >= 0; i-- {
+					if history[i].To == status {
+						entered = history[i].Time
+						break
+					}
+				}
+			}
+			report.AverageTimeInStatus[status] += now.Sub(entered)
+			inStatus[status]++
+		}
+
+		if status != StatusRunning {
+			continue
+		}
+		if report.OldestRunning == nil || created.Before(report.OldestRunning.Created) {
+			report.OldestRunning = &ReportJob{ID: id, Type: typ, Created: created}
+		}
+		lastActive := created
+		if row[5] != tree.DNull {
+			if active := tree.MustBeDTimestampTZ(row[5]).Time; active.After(lastActive) {
+				lastActive = active
+			}
+		}
+		if now.Sub(lastActive) > stallThreshold {
+			report.Stalled++
+		}
+	}
+	if err != nil {
+		return Report{}, errors.Wrap(err, "generating jobs report")
+	}
+	for status, n := range inStatus {
+		report.AverageTimeInStatus[status] /= time.Duration(n)
+	}
+	return report, nil
+}
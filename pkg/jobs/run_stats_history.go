@@ -0,0 +1,116 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/settings"
+	"github.com/cockroachdb/cockroach/pkg/sql/isql"
+	"github.com/cockroachdb/errors"
+)
+
+var runStatsHistoryMaxSamples = settings.RegisterIntSetting(
+	settings.ApplicationLevel,
+	"jobs.run_stats_history.max_samples",
+	"the maximum number of (time, num_runs) samples retained in each job's "+
+		"run-stats history; if 0, no history is recorded",
+	0,
+	settings.NonNegativeInt,
+)
+
+// RunStatsSample is a snapshot of a job's run stats taken when its number of
+// runs was increased.
+type RunStatsSample struct {
+	Time    time.Time
+	NumRuns int
+}
+
+// encodeRunStatsHistory encodes samples as newline-separated
+// "<unix nanos>,<num runs>" pairs.
+func encodeRunStatsHistory(samples []RunStatsSample) []byte {
+	var buf bytes.Buffer
+	for _, s := range samples {
+		fmt.Fprintf(&buf, "%d,%d\n", s.Time.UnixNano(), s.NumRuns)
+	}
+	return buf.Bytes()
+}
+
+// decodeRunStatsHistory decodes a history encoded by encodeRunStatsHistory.
+func decodeRunStatsHistory(value []byte) ([]RunStatsSample, error) {
+	var samples []RunStatsSample
+	for _, line := range bytes.Split(bytes.TrimSuffix(value, []byte("\n")), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		ts, runs, ok := bytes.Cut(line, []byte(","))
+		if !ok {
+			return nil, errors.Newf("invalid run stats sample %q", line)
+		}
+		nanos, err := strconv.ParseInt(string(ts), 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid run stats sample %q", line)
+		}
+		numRuns, err := strconv.Atoi(string(runs))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid run stats sample %q", line)
+		}
+		samples = append(samples, RunStatsSample{Time: time.Unix(0, nanos).UTC(), NumRuns: numRuns})
+	}
+	return samples, nil
+}
+
+// recordRunStatsSample appends a sample of rs to the job's run-stats history,
+// dropping the oldest samples beyond jobs.run_stats_history.max_samples. It
+// is a no-op if the history is disabled.
+func recordRunStatsSample(
+	ctx context.Context, infoStorage InfoStorage, sv *settings.Values, rs RunStats,
+) error {
+	maxSamples := int(runStatsHistoryMaxSamples.Get(sv))
+	if maxSamples == 0 {
+		return nil
+	}
+	value, _, err := infoStorage.get(ctx, runStatsHistoryKey)
+	if err != nil {
+		return err
+	}
+	samples, err := decodeRunStatsHistory(value)
+	if err != nil {
+		return err
+	}
+	samples = append(samples, RunStatsSample{Time: rs.LastRun, NumRuns: rs.NumRuns})
+	if len(samples) > maxSamples {
+		samples = samples[len(samples)-maxSamples:]
+	}
+	return infoStorage.write(ctx, runStatsHistoryKey, encodeRunStatsHistory(samples))
+}
+
+// RunStatsHistory returns the run-stats samples recorded for the job, oldest
+// first. Samples are only recorded while
+// jobs.run_stats_history.max_samples is positive.
+func (j *Job) RunStatsHistory(ctx context.Context) ([]RunStatsSample, error) {
+	var samples []RunStatsSample
+	if err := j.registry.db.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+		value, _, err := j.InfoStorage(txn).get(ctx, runStatsHistoryKey)
+		if err != nil {
+			return err
+		}
+		samples, err = decodeRunStatsHistory(value)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return samples, nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package jobs
+
+import (
+	"context"
+
+	"github.com/cockroachdb/cockroach/pkg/sql/isql"
+	"github.com/cockroachdb/cockroach/pkg/sql/sem/tree"
+	"github.com/cockroachdb/cockroach/pkg/sql/sessiondata"
+	"github.com/cockroachdb/errors"
+)
+
+// SizeBreakdown is the size, in bytes, of the state of a job stored in
+// system.job_info, as returned by Updater.StateSize.
+type SizeBreakdown struct {
+	// Payload and Progress are the sizes of the latest versions of the job's
+	// payload and progress.
+	Payload  int64
+	Progress int64
+	// Other is the total size of the latest versions of the job's other info
+	// records.
+	Other int64
+	// Historical is the total size of the versions of the job's info records,
+	// including its payload and progress, that have been superseded by a later
+	// version, and HistoricalVersions is their number.
+	Historical         int64
+	HistoricalVersions int
+}
+
+// Total returns the total size of the job's stored state.
+func (b SizeBreakdown) Total() int64 {
+	return b.Payload + b.Progress + b.Other + b.Historical
+}
+
+// stateSizeQuery returns, for each of the job_info records of the job $1, its
+// info_key, the length of its value and whether it is the latest version of
+// its info_key.
+const stateSizeQuery = `
+SELECT info_key::STRING, length(value),
+       row_number() OVER (PARTITION BY info_key ORDER BY written DESC) = 1
+FROM system.job_info
+WHERE job_id = $1
+`
+
+// StateSize returns the size of the job's state stored in system.job_info,
+// broken down as described by SizeBreakdown, e.g. to find the jobs bloating
+// the jobs tables. The job is only read, and its claim is not checked.
+func (u Updater) StateSize(ctx context.Context) (SizeBreakdown, error) {
+	if u.txn == nil {
+		var b SizeBreakdown
+		err := u.internalDB().Txn(ctx, func(ctx context.Context, txn isql.Txn) (err error) {
+			u.txn = txn
+			b, err = u.StateSize(ctx)
+			return err
+		})
+		return b, err
+	}
+	rows, err := u.txn.QueryBufferedEx(
+		ctx, "job-state-size", u.txn.KV(),
+		sessiondata.NodeUserSessionDataOverride,
+		stateSizeQuery, u.j.ID(),
+	)
+	if err != nil {
+		return SizeBreakdown{}, err
+	}
+	var b SizeBreakdown
+	for _, row := range rows {
+		key, ok := row[0].(*tree.DString)
+		if !ok {
+			return SizeBreakdown{}, errors.AssertionFailedf(
+				"job %d: expected info_key to be DString (was %T)", u.j.ID(), row[0])
+		}
+		var size int64
+		if n, ok := row[1].(*tree.DInt); ok {
+			size = int64(*n)
+		}
+		switch {
+		case !bool(tree.MustBeDBool(row[2])):
+			b.Historical += size
+			b.HistoricalVersions++
+		case string(*key) == LegacyPayloadKey:
+			b.Payload = size
+		case string(*key) == LegacyProgressKey:
+			b.Progress = size
+		default:
+			b.Other += size
+		}
+	}
+	return b, nil
+}
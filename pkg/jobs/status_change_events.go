@@ -0,0 +1,286 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package jobs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/jobs/jobspb"
+	"github.com/cockroachdb/cockroach/pkg/settings"
+	"github.com/cockroachdb/cockroach/pkg/settings/cluster"
+	"github.com/cockroachdb/cockroach/pkg/util/metric"
+	"github.com/cockroachdb/cockroach/pkg/util/stop"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+)
+
+var (
+	statusChangeEventsBatchSize = settings.RegisterIntSetting(
+		settings.ApplicationLevel,
+		"jobs.status_change_events.batch_size",
+		"the maximum number of job status change events delivered in a single batch",
+		100,
+		settings.PositiveInt,
+	)
+
+	statusChangeEventsInterval = settings.RegisterDurationSetting(
+		settings.ApplicationLevel,
+		"jobs.status_change_events.interval",
+		"the minimum interval between two deliveries of job status change event batches",
+		100*time.Millisecond,
+		settings.NonNegativeDuration,
+	)
+
+	statusChangeEventsMaxBuffered = settings.RegisterIntSetting(
+		settings.ApplicationLevel,
+		"jobs.status_change_events.max_buffered",
+		"the maximum number of job status change events buffered for delivery; "+
+			"events published while the buffer is full are dropped",
+		10000,
+		settings.PositiveInt,
+	)
+)
+
+// StatusChangeEvent describes the transition of a job from one status to
+// another.
+type StatusChangeEvent struct {
+	JobID      jobspb.JobID
+	JobType    jobspb.Type
+	PrevStatus Status
+	Status     Status
+	// Time is the time at which the (latest) transition was published.
+	Time time.Time
+}
+
+// statusChangePublisher buffers StatusChangeEvents and delivers them in
+// batches of at most jobs.status_change_events.batch_size, no more often than
+// every jobs.status_change_events.interval. Transitions of a job that are
+// buffered at the same time are coalesced into a single event from the
+// earliest previous status to the latest status.
+type statusChangePublisher struct {
+	settings  *cluster.Settings
+	deliver   func(context.Context, []StatusChangeEvent)
+	coalesced *metric.Counter
+	dropped   *metric.Counter
+
+	// notify is signaled when an event is added to an empty buffer.
+	notify chan struct{}
+
+	mu struct {
+		syncutil.Mutex
+		// pending holds the buffered events in the order in which their jobs
+		// were first published. index maps job IDs to positions in pending.
+		pending []StatusChangeEvent
+		index   map[jobspb.JobID]int
+	}
+}
+
+func makeStatusChangePublisher(
+	settings *cluster.Settings,
+	metrics *Metrics,
+	deliver func(context.Context, []StatusChangeEvent),
+) *statusChangePublisher {
+	p := &statusChangePublisher{
+		settings:  settings,
+		deliver:   deliver,
+		coalesced: metrics.StatusChangeEventsCoalesced,
+		dropped:   metrics.StatusChangeEventsDropped,
+		notify:    make(chan struct{}, 1),
+	}
+	p.mu.index = make(map[jobspb.JobID]int)
+	return p
+}
+
+// publish buffers ev for delivery. It never blocks.
+func (p *statusChangePublisher) publish(ev StatusChangeEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if i, ok := p.mu.index[ev.JobID]; ok {
+		ev.PrevStatus = p.mu.pending[i].PrevStatus
+		p.mu.pending[i] = ev
+		p.coalesced.Inc(1)
+		return
+	}
+	if int64(len(p.mu.pending)) >= statusChangeEventsMaxBuffered.Get(&p.settings.SV) {
+		p.dropped.Inc(1)
+		return
+	}
+	p.mu.index[ev.JobID] = len(p.mu.pending)
+	p.mu.pending = append(p.mu.pending, ev)
+	if len(p.mu.pending) == 1 {
+		select {
+		case p.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// nextBatch removes and returns up to batch_size of the oldest buffered
+// events, along with whether any events remain buffered.
+func (p *statusChangePublisher) nextBatch() (batch []StatusChangeEvent, more bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := len(p.mu.pending)
+	if max := int(statusChangeEventsBatchSize.Get(&p.settings.SV)); n > max {
+		n = max
+	}
+	batch = append([]StatusChangeEvent(nil), p.mu.pending[:n]...)
+	p.mu.pending = append(p.mu.pending[:0], p.mu.pending[n:]...)
+	for id := range p.mu.index {
+		delete(p.mu.index, id)
+	}
+	for i := range p.mu.pending {
+		p.mu.index[p.mu.pending[i].JobID] = i
+	}
+	return batch, len(p.mu.pending) > 0
+}
+
+// run delivers buffered batches until the stopper quiesces, waiting for at
+// least jobs.status_change_events.interval after each delivery before the
+// next one.
+func (p *statusChangePublisher) run(ctx context.Context, stopper *stop.Stopper) {
+	var timer timeutil.Timer
+	defer timer.Stop()
+	var lastDelivery time.Time
+	for {
+		select {
+		case <-stopper.ShouldQuiesce():
+			return
+		case <-p.notify:
+		}
+		for {
+			if wait := statusChangeEventsInterval.Get(&p.settings.SV) - timeutil.Since(lastDelivery); wait > 0 {
+				timer.Reset(wait)
+				select {
+				case <-stopper.ShouldQuiesce():
+					return
+				case <-timer.C:
+					timer.Read = true
+				}
+			}
+			batch, more := p.nextBatch()
+			if len(batch) > 0 {
+				p.deliver(ctx, batch)
+				lastDelivery = timeutil.Now()
+			}
+			if !more {
+				break
+			}
+		}
+	}
+}
+
+// hasStatusChangeConsumers returns whether anything consumes status change
+// events, i.e. any job has subscribers or the testing knob is set, allowing
+// the publishing path to be skipped otherwise.
+func (r *Registry) hasStatusChangeConsumers() bool {
+	if r.knobs.OnStatusChangeEvents != nil {
+		return true
+	}
+	s := &r.statusSubscriptions
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.mu.subs) > 0
+}
+
+// publishStatusChange publishes a status change event for the given job if
+// there are consumers for it.
+func (r *Registry) publishStatusChange(
+	id jobspb.JobID, typ jobspb.Type, prevStatus, status Status,
+) {
+	if r.statusChanges == nil || !r.hasStatusChangeConsumers() {
+		return
+	}
+	r.statusChanges.publish(StatusChangeEvent{
+		JobID:      id,
+		JobType:    typ,
+		PrevStatus: prevStatus,
+		Status:     status,
+		Time:       timeutil.Now(),
+	})
+}
+
+// deliverStatusChanges hands a batch of status change events to consumers:
+// the subscribers of the events' jobs and the testing knob.
+func (r *Registry) deliverStatusChanges(ctx context.Context, batch []StatusChangeEvent) {
+	for _, ev := range batch {
+		r.notifyStatusSubscribers(ev.JobID, ev.Status)
+	}
+	if fn := r.knobs.OnStatusChangeEvents; fn != nil {
+		fn(batch)
+	}
+}
+
+// statusSubscriptionBufferSize is the number of status transitions buffered
+// for each subscriber of Registry.Subscribe; transitions published while the
+// buffer is full are dropped.
+const statusSubscriptionBufferSize = 16
+
+// statusSubscriptions tracks the subscribers of Registry.Subscribe.
+type statusSubscriptions struct {
+	mu struct {
+		syncutil.Mutex
+		subs map[jobspb.JobID]map[chan Status]struct{}
+	}
+}
+
+// Subscribe returns a channel on which the statuses that the job with the
+// given ID transitions to through updates on this registry are delivered,
+// once the transactions of the updates commit. Transitions are delivered
+// along with the other status change events, so they are batched and rate
+// limited as configured by the jobs.status_change_events settings, and
+// transitions made in quick succession may be coalesced into the latest one.
+// Delivery is best-effort: transitions are dropped, rather than blocking the
+// update, if the subscriber doesn't keep up, and transitions made through
+// other nodes are not observed.
+// The returned function unsubscribes and closes the channel; it must be called
+// once the subscriber is done.
+func (r *Registry) Subscribe(id jobspb.JobID) (<-chan Status, func()) {
+	ch := make(chan Status, statusSubscriptionBufferSize)
+	s := &r.statusSubscriptions
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.mu.subs == nil {
+		s.mu.subs = make(map[jobspb.JobID]map[chan Status]struct{})
+	}
+	if s.mu.subs[id] == nil {
+		s.mu.subs[id] = make(map[chan Status]struct{})
+	}
+	s.mu.subs[id][ch] = struct{}{}
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			delete(s.mu.subs[id], ch)
+			if len(s.mu.subs[id]) == 0 {
+				delete(s.mu.subs, id)
+			}
+			close(ch)
+		})
+	}
+}
+
+// notifyStatusSubscribers delivers the status a job transitioned to to the
+// job's subscribers. It never blocks.
+func (r *Registry) notifyStatusSubscribers(id jobspb.JobID, status Status) {
+	s := &r.statusSubscriptions
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.mu.subs[id] {
+		select {
+		case ch <- status:
+		default:
+		}
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/jobs/jobspb"
+	"github.com/cockroachdb/cockroach/pkg/settings/cluster"
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/metric"
+	"github.com/cockroachdb/cockroach/pkg/util/stop"
+	"github.com/stretchr/testify/require"
+)
+
+func makeStatusChangeTestMetrics() Metrics {
+	return Metrics{
+		StatusChangeEventsCoalesced: metric.NewCounter(metaStatusChangeEventsCoalesced),
+		StatusChangeEventsDropped:   metric.NewCounter(metaStatusChangeEventsDropped),
+	}
+}
+
+func TestStatusChangePublisherCoalescesAndBatches(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	st := cluster.MakeTestingClusterSettings()
+	statusChangeEventsBatchSize.Override(ctx, &st.SV, 2)
+	statusChangeEventsMaxBuffered.Override(ctx, &st.SV, 3)
+
+	m := makeStatusChangeTestMetrics()
+	p := makeStatusChangePublisher(st, &m, nil /* deliver */)
+
+	transition := func(id jobspb.JobID, prev, next Status) {
+		p.publish(StatusChangeEvent{
+			JobID: id, JobType: jobspb.TypeImport, PrevStatus: prev, Status: next,
+		})
+	}
+	// Rapid transitions of job 1 coalesce into a single event.
+	transition(1, StatusRunning, StatusPauseRequested)
+	transition(2, StatusRunning, StatusSucceeded)
+	transition(1, StatusPauseRequested, StatusPaused)
+	transition(1, StatusPaused, StatusRunning)
+	transition(3, StatusRunning, StatusFailed)
+	// The buffer holds three distinct jobs; a fourth is dropped.
+	transition(4, StatusRunning, StatusCanceled)
+	require.Equal(t, int64(2), m.StatusChangeEventsCoalesced.Count())
+	require.Equal(t, int64(1), m.StatusChangeEventsDropped.Count())
+
+	batch, more := p.nextBatch()
+	require.True(t, more)
+	require.Len(t, batch, 2)
+	require.Equal(t, jobspb.JobID(1), batch[0].JobID)
+	require.Equal(t, StatusRunning, batch[0].PrevStatus)
+	require.Equal(t, StatusRunning, batch[0].Status)
+	require.Equal(t, jobspb.JobID(2), batch[1].JobID)
+
+	// Job 1 is no longer buffered, so a new transition is not coalesced.
+	transition(1, StatusRunning, StatusSucceeded)
+	require.Equal(t, int64(2), m.StatusChangeEventsCoalesced.Count())
+
+	batch, more = p.nextBatch()
+	require.False(t, more)
+	require.Len(t, batch, 2)
+	require.Equal(t, jobspb.JobID(3), batch[0].JobID)
+	require.Equal(t, jobspb.JobID(1), batch[1].JobID)
+	require.Equal(t, StatusSucceeded, batch[1].Status)
+}
+
+func TestStatusChangePublisherDelivers(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	stopper := stop.NewStopper()
+	defer stopper.Stop(ctx)
+	st := cluster.MakeTestingClusterSettings()
+	statusChangeEventsInterval.Override(ctx, &st.SV, 0)
+
+	m := makeStatusChangeTestMetrics()
+	delivered := make(chan []StatusChangeEvent, 1)
+	p := makeStatusChangePublisher(st, &m, func(_ context.Context, batch []StatusChangeEvent) {
+		delivered <- batch
+	})
+	require.NoError(t, stopper.RunAsyncTask(ctx, "publisher", func(ctx context.Context) {
+		p.run(ctx, stopper)
+	}))
+
+	p.publish(StatusChangeEvent{JobID: 1, PrevStatus: StatusRunning, Status: StatusPaused})
+	batch := <-delivered
+	require.Len(t, batch, 1)
+	require.Equal(t, StatusPaused, batch[0].Status)
+}
+
+func TestStatusChangePublisherRateLimits(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	stopper := stop.NewStopper()
+	defer stopper.Stop(ctx)
+	st := cluster.MakeTestingClusterSettings()
+	statusChangeEventsInterval.Override(ctx, &st.SV, time.Hour)
+
+	m := makeStatusChangeTestMetrics()
+	delivered := make(chan []StatusChangeEvent, 1)
+	p := makeStatusChangePublisher(st, &m, func(_ context.Context, batch []StatusChangeEvent) {
+		delivered <- batch
+	})
+	require.NoError(t, stopper.RunAsyncTask(ctx, "publisher", func(ctx context.Context) {
+		p.run(ctx, stopper)
+	}))
+
+	// The first event is delivered right away, but an event published after
+	// the buffer was drained waits for the interval to pass.
+	p.publish(StatusChangeEvent{JobID: 1, PrevStatus: StatusRunning, Status: StatusPaused})
+	require.Len(t, <-delivered, 1)
+	p.publish(StatusChangeEvent{JobID: 2, PrevStatus: StatusRunning, Status: StatusPaused})
+	select {
+	case batch := <-delivered:
+		t.Fatalf("unexpected delivery within the interval: %v", batch)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestRegistrySubscribe(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	st := cluster.MakeTestingClusterSettings()
+	m := makeStatusChangeTestMetrics()
+	r := &Registry{settings: st}
+	r.statusChanges = makeStatusChangePublisher(st, &m, r.deliverStatusChanges)
+	// flush delivers the buffered events, as the publisher's run loop does.
+	flush := func() {
+		for more := true; more; {
+			var batch []StatusChangeEvent
+			batch, more = r.statusChanges.nextBatch()
+			r.deliverStatusChanges(ctx, batch)
+		}
+	}
+
+	// Nothing is published without subscribers.
+	r.publishStatusChange(1, jobspb.TypeImport, StatusRunning, StatusPaused)
+	_, more := r.statusChanges.nextBatch()
+	require.False(t, more)
+
+	ch, cancel := r.Subscribe(1)
+	other, cancelOther := r.Subscribe(2)
+	defer cancelOther()
+
+	// Transitions are delivered through the publisher, coalesced.
+	r.publishStatusChange(1, jobspb.TypeImport, StatusRunning, StatusPauseRequested)
+	r.publishStatusChange(1, jobspb.TypeImport, StatusPauseRequested, StatusPaused)
+	require.Empty(t, ch)
+	flush()
+	require.Equal(t, StatusPaused, <-ch)
+	require.Empty(t, ch)
+	require.Empty(t, other)
+
+	// Delivery never blocks on subscribers which don't keep up.
+	for i := 0; i < 2*statusSubscriptionBufferSize; i++ {
+		r.publishStatusChange(1, jobspb.TypeImport, StatusPaused, StatusRunning)
+		flush()
+	}
+	require.Len(t, ch, statusSubscriptionBufferSize)
+
+	cancel()
+	cancel()
+	r.publishStatusChange(1, jobspb.TypeImport, StatusRunning, StatusSucceeded)
+	flush()
+	var received int
+	for range ch {
+		received++
+	}
+	require.Equal(t, statusSubscriptionBufferSize, received)
+}
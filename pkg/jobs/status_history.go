@@ -0,0 +1,103 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package jobs
+
+import (
+	"context"
+	gojson "encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/sql/isql"
+	"github.com/cockroachdb/errors"
+)
+
+// maxStatusHistoryEntries is the number of most recent status transitions
+// retained in a job's status history.
+const maxStatusHistoryEntries = 100
+
+// StatusHistoryEntry records a transition of a job from one status to another.
+type StatusHistoryEntry struct {
+	Time   time.Time `json:"time"`
+	From   Status    `json:"from"`
+	To     Status    `json:"to"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+func decodeStatusHistory(value []byte) ([]StatusHistoryEntry, error) {
+	if len(value) == 0 {
+		return nil, nil
+	}
+	var entries []StatusHistoryEntry
+	if err := gojson.Unmarshal(value, &entries); err != nil {
+		return nil, errors.Wrap(err, "decoding status history")
+	}
+	return entries, nil
+}
+
+// appendStatusHistory appends e to the job's status history, dropping the
+// oldest entries beyond maxStatusHistoryEntries.
+func appendStatusHistory(ctx context.Context, infoStorage InfoStorage, e StatusHistoryEntry) error {
+	value, _, err := infoStorage.get(ctx, statusHistoryKey)
+	if err != nil {
+		return err
+	}
+	entries, err := decodeStatusHistory(value)
+	if err != nil {
+		return err
+	}
+	entries = append(entries, e)
+	if len(entries) > maxStatusHistoryEntries {
+		entries = entries[len(entries)-maxStatusHistoryEntries:]
+	}
+	value, err = gojson.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	return infoStorage.write(ctx, statusHistoryKey, value)
+}
+
+// StatusHistory returns the job's most recent status transitions, oldest
+// first.
+func (j *Job) StatusHistory(ctx context.Context) ([]StatusHistoryEntry, error) {
+	var entries []StatusHistoryEntry
+	if err := j.registry.db.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+		value, _, err := j.InfoStorage(txn).get(ctx, statusHistoryKey)
+		if err != nil {
+			return err
+		}
+		entries, err = decodeStatusHistory(value)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// SetStatusWithReason transitions the job to the next status, recording the
+// reason for the transition in the job's status history. Jobs in a terminal
+// status cannot be transitioned. Transitioning a job to its current status is
+// a no-op.
+func (u Updater) SetStatusWithReason(ctx context.Context, next Status, reason string) error {
+	return u.Update(ctx, func(_ isql.Txn, md JobMetadata, ju *JobUpdater) error {
+		if md.Status == next {
+			return nil
+		}
+		if md.Status.Terminal() {
+			return &InvalidStatusError{
+				md.ID, md.Status, fmt.Sprintf("transition to %s", next), md.Payload.Error,
+			}
+		}
+		ju.UpdateStatus(next)
+		ju.statusReason = reason
+		return nil
+	})
+}
@@ -59,6 +59,30 @@ type TestingKnobs struct {
 	// not be committed.
 	BeforeUpdate func(orig, updated JobMetadata) error
 
+	// SkipUpdate is called in the update transaction after the update function
+	// has run. If it returns true, the update is abandoned as if the update
+	// function had made no changes: nothing is written and no error is
+	// returned, e.g. to simulate the update being preempted.
+	SkipUpdate func(orig, updated JobMetadata) bool
+
+	// AfterUpdate is called once the transaction of an update which wrote
+	// anything has committed, with the job's metadata before the update and
+	// the changes made by it. It is not called if the transaction fails.
+	AfterUpdate func(orig, updated JobMetadata)
+
+	// OverrideUpdateRowCount, if set, is called after an update has written the
+	// job's system.jobs row. If it returns ok, n replaces the number of rows
+	// affected by the write, e.g. to simulate the row vanishing mid-update.
+	OverrideUpdateRowCount func() (n int, ok bool)
+
+	// OnLargePayload is called when an update writes a payload larger than
+	// jobs.payload.warn_size, with the job's ID and the payload's size.
+	OnLargePayload func(id jobspb.JobID, size int)
+
+	// OnStatusChangeEvents, if set, is called with every batch of job status
+	// change events delivered by the registry.
+	OnStatusChangeEvents func([]StatusChangeEvent)
+
 	// IntervalOverrides consists of override knobs for job intervals.
 	IntervalOverrides TestingIntervalOverrides
 
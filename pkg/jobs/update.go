@@ -14,21 +14,59 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"math"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/cockroachdb/cockroach/pkg/base"
 	"github.com/cockroachdb/cockroach/pkg/jobs/jobspb"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvpb"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/concurrency/isolation"
+	"github.com/cockroachdb/cockroach/pkg/settings"
 	"github.com/cockroachdb/cockroach/pkg/sql/isql"
+	"github.com/cockroachdb/cockroach/pkg/sql/pgwire/pgcode"
+	"github.com/cockroachdb/cockroach/pkg/sql/pgwire/pgerror"
 	"github.com/cockroachdb/cockroach/pkg/sql/sem/tree"
 	"github.com/cockroachdb/cockroach/pkg/sql/sessiondata"
 	"github.com/cockroachdb/cockroach/pkg/sql/sqlliveness"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
 	"github.com/cockroachdb/cockroach/pkg/util/protoutil"
+	"github.com/cockroachdb/cockroach/pkg/util/retry"
 	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
 	"github.com/cockroachdb/cockroach/pkg/util/tracing"
 	"github.com/cockroachdb/errors"
 )
 
+// updateMaxRetries bounds the number of times an update that runs in its own
+// transaction is retried after a transaction retry error escapes that
+// transaction.
+var updateMaxRetries = settings.RegisterIntSetting(
+	settings.ApplicationLevel,
+	"jobs.update.max_retries",
+	"the maximum number of times a job update running in its own transaction "+
+		"is retried after a retryable transaction error",
+	5,
+	settings.NonNegativeInt,
+)
+
+// payloadWarnSize is the size of a marshaled payload above which writing it
+// logs a warning, since payloads are rewritten in full on every change and
+// large state belongs in info records of its own.
+var payloadWarnSize = settings.RegisterByteSizeSetting(
+	settings.ApplicationLevel,
+	"jobs.payload.warn_size",
+	"the size of a job payload above which writing it logs a warning; "+
+		"if 0, no warning is logged",
+	512<<10, /* 512 KiB */
+)
+
+const (
+	updateRetryInitialBackoff = 10 * time.Millisecond
+	updateRetryMaxBackoff     = time.Second
+)
+
 // UpdateFn is the callback passed to Job.Update. It is called from the context
 // of a transaction and is passed the current metadata for the job. The callback
 // can modify metadata using the JobUpdater and the changes will be persisted
@@ -38,9 +76,76 @@ import (
 // changes will be ignored unless JobUpdater is used).
 type UpdateFn func(txn isql.Txn, md JobMetadata, ju *JobUpdater) error
 
+// UpdateFnInfo is like UpdateFn, but is additionally passed the job's
+// InfoStorage bound to the update's transaction; see Updater.UpdateWithInfo.
+type UpdateFnInfo func(txn isql.Txn, md JobMetadata, ju *JobUpdater, info InfoStorage) error
+
 type Updater struct {
 	j   *Job
 	txn isql.Txn
+
+	// normalizeProgress, if set, causes any progress written by the update to
+	// be passed through normalizeProgress first.
+	normalizeProgress bool
+
+	// epoch, if non-zero, is the resumption epoch the update is fenced on:
+	// the update fails with ErrStaleEpoch if the job's epoch has moved on.
+	epoch int64
+
+	// stats, if set, is populated with the UpdateStats of successful updates.
+	stats *UpdateStats
+
+	// sequence, if set, is the update sequence number the update is
+	// conditioned on: the update fails with ErrStaleSequence if the job has
+	// been updated since.
+	sequence *int64
+
+	// skipSessionCheck, if set, lets the update proceed even if the job is not
+	// claimed by the session of the Job; see WithoutSessionCheck.
+	skipSessionCheck bool
+
+	// skipRedundantWrites, if set, causes the update not to write the payload
+	// or progress if they are unchanged; see SkipRedundantWrites.
+	skipRedundantWrites bool
+
+	// noWait, if set, causes the update to fail immediately with a
+	// LockNotAvailable error if the job's row is locked; see TryUpdate.
+	noWait bool
+
+	// progressMicros, if non-zero, is the ModifiedMicros stamped on progress
+	// written by the update instead of the current time; see
+	// WithProgressTimestamp.
+	progressMicros int64
+
+	// inFlight tracks the update while it executes; see
+	// Registry.InFlightUpdates.
+	inFlight *inFlightUpdate
+
+	// isoLevel is the isolation level of the transactions updates run in when
+	// the Updater isn't bound to a transaction; see WithIsolation.
+	isoLevel isolation.Level
+
+	// dryRun is set by DryRunUpdate, which computes an update without
+	// writing it.
+	dryRun bool
+
+	// db, if set, is the database the transactions of updates run in when the
+	// Updater isn't bound to a transaction, instead of that of the job's
+	// registry; see WithDB.
+	db isql.DB
+
+	// statementTimeout, if non-zero, bounds the time spent loading and writing
+	// the job; see WithStatementTimeout.
+	statementTimeout time.Duration
+}
+
+// internalDB returns the database the Updater runs its transactions in when
+// it isn't bound to one.
+func (u Updater) internalDB() isql.DB {
+	if u.db != nil {
+		return u.db
+	}
+	return u.j.registry.db
 }
 
 func (j *Job) NoTxn() Updater {
@@ -51,14 +156,556 @@ func (j *Job) WithTxn(txn isql.Txn) Updater {
 	return Updater{j: j, txn: txn}
 }
 
-func (u Updater) update(ctx context.Context, updateFn UpdateFn) (retErr error) {
+// WithProgressNormalization returns an Updater which normalizes the progress
+// before writing it: fractions outside of [0, 1] are clamped and NaN or
+// infinite fractions are dropped. A warning is logged whenever progress needed
+// to be normalized.
+func (u Updater) WithProgressNormalization() Updater {
+	u.normalizeProgress = true
+	return u
+}
+
+// WithEpoch returns an Updater whose updates are rejected with ErrStaleEpoch
+// unless the job's resumption epoch is still epoch, preventing a resumer of
+// an earlier generation from writing once the job has been adopted again. See
+// Job.Epoch.
+func (u Updater) WithEpoch(epoch int64) Updater {
+	u.epoch = epoch
+	return u
+}
+
+// WithSequence returns an Updater whose updates are rejected with
+// ErrStaleSequence unless the job's update sequence number is still sequence,
+// i.e. unless the job hasn't been updated since JobMetadata.Sequence was read
+// as sequence.
+func (u Updater) WithSequence(sequence int64) Updater {
+	u.sequence = &sequence
+	return u
+}
+
+// WithoutSessionCheck returns an Updater whose updates don't verify that the
+// job is still claimed by the session, and instance, the Job was loaded with.
+// It is meant for administrative overrides, e.g. failing a job whose
+// coordinator is gone, and must not be used by the coordinator of a job, for
+// which the check is what prevents it from writing after losing its claim.
+// Updates which would have failed the check log a warning.
+func (u Updater) WithoutSessionCheck() Updater {
+	u.skipSessionCheck = true
+	return u
+}
+
+// WithDB returns an Updater whose updates, when it isn't bound to a
+// transaction, run in transactions of db rather than of the database of the
+// job's registry, e.g. to write the state of a job loaded from one cluster
+// into another. The registry's clock, settings and testing knobs are still
+// used. Since the job's session is meaningless in db, the updates skip the
+// check of the job's claim, as with WithoutSessionCheck.
+func (u Updater) WithDB(db isql.DB) Updater {
+	u.db = db
+	u.skipSessionCheck = true
+	return u
+}
+
+// SkipRedundantWrites returns an Updater whose updates don't write the job's
+// progress if it is identical to the stored one, ignoring its ModifiedMicros,
+// as is always the case for the payload. This costs an extra marshaling of
+// the progress, so it is meant for callers, like idempotent checkpointers,
+// that frequently write unchanged state.
+func (u Updater) SkipRedundantWrites() Updater {
+	u.skipRedundantWrites = true
+	return u
+}
+
+// WithProgressTimestamp returns an Updater which stamps the progress written by
+// its updates with the given ModifiedMicros rather than the current time, e.g.
+// to preserve the original timestamp when backfilling progress, or to make
+// tests deterministic.
+func (u Updater) WithProgressTimestamp(micros int64) Updater {
+	u.progressMicros = micros
+	return u
+}
+
+// WithIsolation returns an Updater whose updates run in transactions of the
+// given isolation level, rather than serializable, when the Updater is not
+// bound to a transaction. Only updates which write nothing but the job's
+// progress and info records may run under an isolation level weaker than
+// serializable: updates of the job's status or payload fail.
+func (u Updater) WithIsolation(level isolation.Level) Updater {
+	u.isoLevel = level
+	return u
+}
+
+// WithStatementTimeout returns an Updater whose updates fail with an error
+// marked with ErrUpdateTimeout if loading the job, or writing it, takes longer
+// than d, so that a single unhealthy range cannot wedge the job's coordinator.
+// The UpdateFn is not subject to the timeout. A zero d means no timeout, which
+// is the default.
+func (u Updater) WithStatementTimeout(d time.Duration) Updater {
+	u.statementTimeout = d
+	return u
+}
+
+// withStatementTimeout runs fn, which executes statements of the update
+// described by op, subject to the statement timeout of u, if any.
+func (u Updater) withStatementTimeout(
+	ctx context.Context, op string, fn func(ctx context.Context) error,
+) error {
+	if u.statementTimeout == 0 {
+		return fn(ctx)
+	}
+	timeoutCtx, cancel := context.WithTimeout(ctx, u.statementTimeout)
+	defer cancel()
+	err := fn(timeoutCtx)
+	// Only a deadline of our own is reported as a timeout, not one of ctx.
+	if err != nil && ctx.Err() == nil && errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
+		return errors.Mark(
+			errors.Wrapf(err, "%s timed out after %s", op, u.statementTimeout), ErrUpdateTimeout)
+	}
+	return err
+}
+
+// UpdateStats describes what an update of a job wrote.
+type UpdateStats struct {
+	// StatusChanged is set if the update changed the job's status.
+	StatusChanged bool
+	// PayloadWritten and ProgressWritten are set if the update wrote the
+	// job's payload and progress, respectively.
+	PayloadWritten  bool
+	ProgressWritten bool
+	// BytesWritten is the total size of the payload, progress and other
+	// job_info values written by the update, before any compression.
+	BytesWritten int
+}
+
+// WithStats returns an Updater which, whenever an update succeeds, populates
+// stats with what the update wrote. stats is left untouched by updates that
+// fail. This allows callers to observe, e.g., how often they write progress.
+func (u Updater) WithStats(stats *UpdateStats) Updater {
+	u.stats = stats
+	return u
+}
+
+// normalizeProgress clamps the fraction completed of p to [0, 1], resetting
+// NaN or infinite fractions to 0. It returns the original fraction and true if
+// p was modified.
+func normalizeProgress(p *jobspb.Progress) (orig float32, changed bool) {
+	fc, ok := p.Progress.(*jobspb.Progress_FractionCompleted)
+	if !ok || fc == nil {
+		return 0, false
+	}
+	orig = fc.FractionCompleted
+	f := float64(orig)
+	switch {
+	case math.IsNaN(f) || math.IsInf(f, 0):
+		f = 0
+	case f < 0:
+		f = 0
+	case f > 1:
+		f = 1
+	default:
+		return orig, false
+	}
+	p.Progress = &jobspb.Progress_FractionCompleted{FractionCompleted: float32(f)}
+	return orig, true
+}
+
+// loadJobQuery loads the current status, payload, progress, claim, run stats,
+// update sequence number, last updater, creation time and checkpoint ID of a
+// job. The latter three info records are read with a single scan of the job's
+// job_info rows.
+const loadJobQuery = `
+WITH
+  latestpayload AS (
+    SELECT job_id, value
+    FROM system.job_info AS payload
+    WHERE info_key = 'legacy_payload' AND job_id = $1
+    ORDER BY written DESC LIMIT 1
+  ),
+  latestprogress AS (
+    SELECT job_id, value
+    FROM system.job_info AS progress
+    WHERE info_key = 'legacy_progress' AND job_id = $1
+    ORDER BY written DESC LIMIT 1
+  ),
+  latestinfo AS (
+    SELECT job_id,
+           max(value) FILTER (WHERE info_key = '` + sequenceKey + `') AS sequence,
+           max(value) FILTER (WHERE info_key = '` + lastUpdatedByKey + `') AS updated_by,
+           max(value) FILTER (WHERE info_key = '` + checkpointIDKey + `') AS checkpoint_id
+    FROM (
+      SELECT DISTINCT ON (info_key) job_id, info_key, value
+      FROM system.job_info
+      WHERE info_key IN ('` + sequenceKey + `', '` + lastUpdatedByKey + `', '` + checkpointIDKey + `')
+        AND job_id = $1
+      ORDER BY info_key, written DESC
+    ) AS latest
+    GROUP BY job_id
+  )
+SELECT status, payload.value AS payload, progress.value AS progress,
+       claim_session_id, COALESCE(last_run, created), COALESCE(num_runs, 0),
+       claim_instance_id, info.sequence, info.updated_by, created,
+       info.checkpoint_id
+FROM system.jobs AS j
+INNER JOIN latestpayload AS payload ON j.id = payload.job_id
+LEFT JOIN latestprogress AS progress ON j.id = progress.job_id
+LEFT JOIN latestinfo AS info ON j.id = info.job_id
+WHERE id = $1
+`
+
+// LoadMetadataAsOf loads the job's metadata as of the given historical
+// timestamp, i.e. its status and run stats as of then and the latest payload
+// and progress written at or before it. The job's claim is not checked and the
+// in-memory state of the job is not modified. It cannot be used with an
+// Updater bound to a transaction, and fails with an error wrapping a
+// kvpb.BatchTimestampBeforeGCError if ts is below the GC threshold.
+func (u Updater) LoadMetadataAsOf(ctx context.Context, ts hlc.Timestamp) (JobMetadata, error) {
+	if u.txn != nil {
+		return JobMetadata{}, errors.AssertionFailedf(
+			"cannot load historical job metadata in a transaction")
+	}
+	j := u.j
+	// All the tables read by loadJobQuery are read as of ts by running it in a
+	// transaction fixed at that timestamp.
+	var row tree.Datums
+	if err := u.internalDB().Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+		if err := txn.KV().SetFixedTimestamp(ctx, ts); err != nil {
+			return err
+		}
+		var err error
+		row, err = txn.QueryRowEx(
+			ctx, "select-job-as-of", txn.KV(),
+			sessiondata.NodeUserSessionDataOverride,
+			loadJobQuery, j.ID(),
+		)
+		return err
+	}); err != nil {
+		if errors.HasType(err, (*kvpb.BatchTimestampBeforeGCError)(nil)) {
+			return JobMetadata{}, errors.Wrapf(err,
+				"job %d: timestamp %s is below the GC threshold", j.ID(), ts)
+		}
+		return JobMetadata{}, errors.Wrapf(err, "job %d as of %s", j.ID(), ts)
+	}
+	if row == nil {
+		return JobMetadata{}, &JobNotFoundError{jobID: j.ID()}
+	}
+	md := JobMetadata{ID: j.ID()}
+	var err error
+	if md.Status, err = unmarshalStatus(row[0]); err != nil {
+		return JobMetadata{}, errors.Wrapf(err, "job %d", j.ID())
+	}
+	if md.Payload, err = UnmarshalPayload(row[1]); err != nil {
+		return JobMetadata{}, errors.Wrapf(err, "job %d", j.ID())
+	}
+	if md.Progress, err = UnmarshalProgress(row[2]); err != nil {
+		return JobMetadata{}, errors.Wrapf(err, "job %d", j.ID())
+	}
+	md.ClaimSessionID = unmarshalClaimSessionID(row[3])
+	if md.RunStats, err = unmarshalRunStats(row[4], row[5]); err != nil {
+		return JobMetadata{}, errors.Wrapf(err, "job %d", j.ID())
+	}
+	if md.Sequence, err = unmarshalSequence(row[7]); err != nil {
+		return JobMetadata{}, errors.Wrapf(err, "job %d", j.ID())
+	}
+	if md.LastUpdatedBy, err = unmarshalLastUpdatedBy(row[8]); err != nil {
+		return JobMetadata{}, errors.Wrapf(err, "job %d", j.ID())
+	}
+	if md.Created, err = unmarshalCreated(row[9]); err != nil {
+		return JobMetadata{}, errors.Wrapf(err, "job %d", j.ID())
+	}
+	if md.CheckpointID, err = unmarshalCheckpointID(row[10]); err != nil {
+		return JobMetadata{}, errors.Wrapf(err, "job %d", j.ID())
+	}
+	return md, nil
+}
+
+// loadMetadata loads the job's current metadata in u.txn, checking that the
+// job is still claimed by the job's session, if any, and that its epoch is
+// still the one u is fenced on, if any.
+func (u Updater) loadMetadata(ctx context.Context) (JobMetadata, error) {
+	j := u.j
+	if u.noWait {
+		if _, err := u.txn.ExecEx(
+			ctx, "lock-job-nowait", u.txn.KV(),
+			sessiondata.NodeUserSessionDataOverride,
+			"SELECT id FROM system.jobs WHERE id = $1 FOR UPDATE NOWAIT", j.ID(),
+		); err != nil {
+			return JobMetadata{}, err
+		}
+	}
+	row, err := u.txn.QueryRowEx(
+		ctx, "select-job", u.txn.KV(),
+		sessiondata.NodeUserSessionDataOverride,
+		loadJobQuery, j.ID(),
+	)
+	if err != nil {
+		return JobMetadata{}, err
+	}
+	if row == nil {
+		return JobMetadata{}, &JobNotFoundError{jobID: j.ID()}
+	}
+
+	status, err := unmarshalStatus(row[0])
+	if err != nil {
+		return JobMetadata{}, err
+	}
+	payload, payloadBytes, err := unmarshalPayloadWithBytes(row[1])
+	if err != nil {
+		return JobMetadata{}, errors.Wrapf(err, "job %d", j.ID())
+	}
+	progress, err := UnmarshalProgress(row[2])
+	if err != nil {
+		return JobMetadata{}, errors.Wrapf(err, "job %d", j.ID())
+	}
+	claimSessionID := unmarshalClaimSessionID(row[3])
+	if session := j.Session(); session != nil && u.skipSessionCheck {
+		if claimSessionID != session.ID() {
+			log.Warningf(ctx, "job %d: updating without session check: expected session %q but found %q",
+				j.ID(), session.ID(), claimSessionID)
+		}
+	} else if session != nil {
+		if row[3] == tree.DNull {
+			j.registry.metrics.UpdatesSessionMismatch.Inc(1)
+			return JobMetadata{}, errors.Errorf(
+				"with status %q: expected session %q but found NULL",
+				status, session.ID())
+		}
+		if !bytes.Equal(claimSessionID.UnsafeBytes(), session.ID().UnsafeBytes()) {
+			j.registry.metrics.UpdatesSessionMismatch.Inc(1)
+			return JobMetadata{}, errors.Errorf(
+				"with status %q: expected session %q but found %q",
+				status, session.ID(), claimSessionID)
+		}
+		// Defend against a claim that was handed to another instance while
+		// retaining this instance's session.
+		storedInstance, ok := row[6].(*tree.DInt)
+		if !ok || base.SQLInstanceID(*storedInstance) != j.registry.ID() {
+			j.registry.metrics.UpdatesSessionMismatch.Inc(1)
+			return JobMetadata{}, errors.Wrapf(ErrClaimInstanceMismatch,
+				"with status %q: expected instance %d but found %s",
+				status, j.registry.ID(), row[6])
+		}
+	} else {
+		log.VInfof(ctx, 1, "job %d: update called with no session ID", j.ID())
+	}
+
+	if u.epoch != 0 {
+		epoch, err := getEpoch(ctx, j.InfoStorage(u.txn))
+		if err != nil {
+			return JobMetadata{}, err
+		}
+		if epoch != u.epoch {
+			return JobMetadata{}, errors.Wrapf(ErrStaleEpoch, "expected epoch %d but found %d", u.epoch, epoch)
+		}
+	}
+
+	runStats, err := unmarshalRunStats(row[4], row[5])
+	if err != nil {
+		return JobMetadata{}, err
+	}
+	sequence, err := unmarshalSequence(row[7])
+	if err != nil {
+		return JobMetadata{}, err
+	}
+	if u.sequence != nil && sequence != *u.sequence {
+		return JobMetadata{}, errors.Wrapf(ErrStaleSequence,
+			"expected sequence %d but found %d", *u.sequence, sequence)
+	}
+	lastUpdatedBy, err := unmarshalLastUpdatedBy(row[8])
+	if err != nil {
+		return JobMetadata{}, err
+	}
+	created, err := unmarshalCreated(row[9])
+	if err != nil {
+		return JobMetadata{}, err
+	}
+	checkpointID, err := unmarshalCheckpointID(row[10])
+	if err != nil {
+		return JobMetadata{}, err
+	}
+	return JobMetadata{
+		ID:             j.ID(),
+		Status:         status,
+		Payload:        payload,
+		Progress:       progress,
+		RunStats:       runStats,
+		ClaimSessionID: claimSessionID,
+		Sequence:       sequence,
+		LastUpdatedBy:  lastUpdatedBy,
+		Created:        created,
+		CheckpointID:   checkpointID,
+		payloadBytes:   payloadBytes,
+	}, nil
+}
+
+// unmarshalSequence unmarshals the value of a job's sequenceKey, which is
+// NULL if the job has never been updated.
+func unmarshalSequence(datum tree.Datum) (int64, error) {
+	if datum == tree.DNull {
+		return 0, nil
+	}
+	sequence, err := strconv.ParseInt(string(*datum.(*tree.DBytes)), 10, 64)
+	if err != nil {
+		return 0, errors.Wrap(err, "invalid update sequence")
+	}
+	return sequence, nil
+}
+
+// unmarshalLastUpdatedBy unmarshals the value of the lastUpdatedByKey info
+// record of a job, which is NULL if no update of the job has been recorded.
+func unmarshalLastUpdatedBy(datum tree.Datum) (base.SQLInstanceID, error) {
+	if datum == tree.DNull {
+		return 0, nil
+	}
+	id, err := strconv.ParseInt(string(*datum.(*tree.DBytes)), 10, 32)
+	if err != nil {
+		return 0, errors.Wrap(err, "invalid last updated by instance ID")
+	}
+	return base.SQLInstanceID(id), nil
+}
+
+// unmarshalCheckpointID unmarshals the value of a job's checkpointIDKey, which
+// is NULL if the job's progress was never written with a checkpoint ID.
+func unmarshalCheckpointID(datum tree.Datum) (uint64, error) {
+	if datum == tree.DNull {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(string(*datum.(*tree.DBytes)), 10, 64)
+	if err != nil {
+		return 0, errors.Wrap(err, "invalid checkpoint ID")
+	}
+	return id, nil
+}
+
+// unmarshalCreated unmarshals the created column of a job.
+func unmarshalCreated(datum tree.Datum) (time.Time, error) {
+	created, ok := datum.(*tree.DTimestamp)
+	if !ok {
+		return time.Time{}, errors.AssertionFailedf("expected timestamp created, but got %T", datum)
+	}
+	return created.Time, nil
+}
+
+// unmarshalClaimSessionID unmarshals the claim_session_id column of a job,
+// which is NULL if the job is not claimed.
+func unmarshalClaimSessionID(datum tree.Datum) sqlliveness.SessionID {
+	if datum == tree.DNull {
+		return ""
+	}
+	return sqlliveness.SessionID(*datum.(*tree.DBytes))
+}
+
+// unmarshalRunStats unmarshals the COALESCE(last_run, created) and
+// COALESCE(num_runs, 0) columns of a job.
+func unmarshalRunStats(lastRunDatum, numRunsDatum tree.Datum) (*RunStats, error) {
+	lastRun, ok := lastRunDatum.(*tree.DTimestamp)
+	if !ok {
+		return nil, errors.AssertionFailedf("expected timestamp last_run, but got %T", lastRunDatum)
+	}
+	numRuns, ok := numRunsDatum.(*tree.DInt)
+	if !ok {
+		return nil, errors.AssertionFailedf("expected int num_runs, but got %T", numRunsDatum)
+	}
+	return &RunStats{
+		NumRuns: int(*numRuns),
+		LastRun: lastRun.Time,
+	}, nil
+}
+
+// LoadMetadata returns the job's current metadata, refreshing the in-memory
+// copy of the job along the way. Unlike an Update with a no-op UpdateFn, it
+// only reads the job, but it performs the same checks of the job's claim.
+func (u Updater) LoadMetadata(ctx context.Context) (JobMetadata, error) {
 	if u.txn == nil {
-		return u.j.registry.db.Txn(ctx, func(
-			ctx context.Context, txn isql.Txn,
-		) error {
+		var md JobMetadata
+		err := u.internalDB().Txn(ctx, func(ctx context.Context, txn isql.Txn) (err error) {
+			u.txn = txn
+			md, err = u.LoadMetadata(ctx)
+			return err
+		})
+		return md, err
+	}
+	ctx, sp := tracing.ChildSpan(ctx, "load-job-metadata")
+	defer sp.Finish()
+
+	md, err := u.loadMetadata(ctx)
+	if err != nil {
+		if HasJobNotFoundError(err) {
+			return JobMetadata{}, err
+		}
+		return JobMetadata{}, errors.Wrapf(err, "job %d", u.j.ID())
+	}
+	u.j.mu.Lock()
+	defer u.j.mu.Unlock()
+	u.j.mu.payload = *md.Payload
+	u.j.mu.progress = *md.Progress
+	u.j.mu.status = md.Status
+	return md, nil
+}
+
+// updateWithRetry runs the update in a new transaction, retrying it with
+// jittered exponential backoff if it fails with a transaction retry error.
+// Every attempt runs in a fresh transaction, so the job is re-read and
+// updateFn sees the current metadata. Other errors are returned immediately,
+// as is the last error if the retries or the context's deadline run out.
+func (u Updater) updateWithRetry(ctx context.Context, updateFn UpdateFn) error {
+	maxRetries := int(updateMaxRetries.Get(&u.j.registry.settings.SV))
+	opts := retry.Options{
+		InitialBackoff:      updateRetryInitialBackoff,
+		MaxBackoff:          updateRetryMaxBackoff,
+		Multiplier:          2,
+		RandomizationFactor: 0.5,
+		MaxRetries:          maxRetries,
+	}
+	var err error
+	// Next gives up once the context is done, so a deadline bounds the loop
+	// along with maxRetries.
+	for r := retry.StartWithCtx(ctx, opts); r.Next(); {
+		u.inFlight.setPhase(UpdatePhaseBegin)
+		err = u.internalDB().Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+			if err := txn.KV().SetIsoLevel(u.isoLevel); err != nil {
+				return err
+			}
 			u.txn = txn
 			return u.update(ctx, updateFn)
 		})
+		// A MaxRetries of zero means retrying forever to retry.Options, so the
+		// case of no retries at all is handled here.
+		if err == nil || !isRetryableUpdateError(err) || maxRetries == 0 {
+			return err
+		}
+		log.VEventf(ctx, 2, "job %d: retrying update after attempt %d: %v",
+			u.j.ID(), r.CurrentAttempt()+1, err)
+	}
+	return err
+}
+
+// isRetryableUpdateError returns true if err is a transaction retry error
+// which escaped the update's transaction, and so is expected to go away when
+// the update is retried in a new transaction.
+func isRetryableUpdateError(err error) bool {
+	return errors.HasInterface(err, (*pgerror.ClientVisibleRetryError)(nil))
+}
+
+func (u Updater) update(ctx context.Context, updateFn UpdateFn) (retErr error) {
+	if u.inFlight == nil {
+		inFlight := &u.j.registry.inFlightUpdates
+		u.inFlight = inFlight.start(u.j.ID())
+		defer inFlight.finish(u.inFlight)
+	}
+	if u.txn == nil {
+		// Only report the stats of the update once its transaction commits.
+		var stats UpdateStats
+		reportTo := u.stats
+		if reportTo != nil {
+			u.stats = &stats
+		}
+		if err := u.updateWithRetry(ctx, updateFn); err != nil {
+			return err
+		}
+		if reportTo != nil {
+			*reportTo = stats
+		}
+		return nil
 	}
 	ctx, sp := tracing.ChildSpan(ctx, "update-job")
 	defer sp.Finish()
@@ -67,12 +714,16 @@ func (u Updater) update(ctx context.Context, updateFn UpdateFn) (retErr error) {
 	var progress *jobspb.Progress
 	var status Status
 	var runStats *RunStats
+	var stats UpdateStats
 	j := u.j
 	defer func() {
 		if retErr != nil && !HasJobNotFoundError(retErr) {
 			retErr = errors.Wrapf(retErr, "job %d", j.id)
 			return
 		}
+		if retErr == nil && u.stats != nil {
+			*u.stats = stats
+		}
 		j.mu.Lock()
 		defer j.mu.Unlock()
 		if payload != nil {
@@ -89,87 +740,233 @@ func (u Updater) update(ctx context.Context, updateFn UpdateFn) (retErr error) {
 		}
 	}()
 
-	const loadJobQuery = `
-WITH
-  latestpayload AS (
-    SELECT job_id, value
-    FROM system.job_info AS payload
-    WHERE info_key = 'legacy_payload' AND job_id = $1
-    ORDER BY written DESC LIMIT 1
-  ),
-  latestprogress AS (
-    SELECT job_id, value
-    FROM system.job_info AS progress
-    WHERE info_key = 'legacy_progress' AND job_id = $1
-    ORDER BY written DESC LIMIT 1
-  )
-SELECT status, payload.value AS payload, progress.value AS progress,
-       claim_session_id, COALESCE(last_run, created), COALESCE(num_runs, 0)
-FROM system.jobs AS j
-INNER JOIN latestpayload AS payload ON j.id = payload.job_id
-LEFT JOIN latestprogress AS progress ON j.id = progress.job_id
-WHERE id = $1
-`
-	row, err := u.txn.QueryRowEx(
-		ctx, "select-job", u.txn.KV(),
-		sessiondata.NodeUserSessionDataOverride,
-		loadJobQuery, j.ID(),
-	)
+	u.inFlight.setPhase(UpdatePhaseLoad)
+	var md JobMetadata
+	if err := u.withStatementTimeout(ctx, "loading job", func(ctx context.Context) (err error) {
+		md, err = u.loadMetadata(ctx)
+		return err
+	}); err != nil {
+		return err
+	}
+	status, payload, progress = md.Status, md.Payload, md.Progress
+
+	u.inFlight.setPhase(UpdatePhaseUpdateFn)
+	pu, err := u.prepareUpdate(ctx, md, updateFn)
 	if err != nil {
 		return err
 	}
-	if row == nil {
-		return &JobNotFoundError{jobID: j.ID()}
+	if pu == nil {
+		j.registry.metrics.UpdatesNoop.Inc(1)
+		return nil
 	}
-
-	if status, err = unmarshalStatus(row[0]); err != nil {
+	if err := u.checkIsolation(pu); err != nil {
 		return err
 	}
-	if payload, err = UnmarshalPayload(row[1]); err != nil {
+	if pu.ju.md.Payload != nil {
+		payload = pu.ju.md.Payload
+	}
+	if pu.ju.md.Progress != nil {
+		progress = pu.ju.md.Progress
+	}
+	runStats = pu.ju.md.RunStats
+	stats = pu.stats()
+
+	u.inFlight.setPhase(UpdatePhaseWrite)
+	if err := u.withStatementTimeout(ctx, "writing job", func(ctx context.Context) error {
+		return u.writeUpdate(ctx, pu)
+	}); err != nil {
 		return err
 	}
-	if progress, err = UnmarshalProgress(row[2]); err != nil {
+
+	u.finishUpdate(pu)
+	return nil
+}
+
+// checkIsolation returns an error if the update pu of the job may not run
+// under the isolation level of u; see WithIsolation.
+func (u Updater) checkIsolation(pu *pendingUpdate) error {
+	if u.isoLevel.ToleratesWriteSkew() && pu.writesStatusOrPayload() {
+		return errors.AssertionFailedf(
+			"job status and payload updates cannot run under %s isolation", u.isoLevel)
+	}
+	return nil
+}
+
+// finishUpdate runs what follows the writes of the update pu of the job, both
+// in update and in Registry.UpdateBatch: the AfterUpdate testing knob, once the
+// transaction commits, and the update metrics.
+func (u Updater) finishUpdate(pu *pendingUpdate) {
+	if fn := u.j.registry.knobs.AfterUpdate; fn != nil {
+		u.txn.KV().AddCommitTrigger(func(context.Context) {
+			fn(pu.md, pu.ju.md)
+		})
+	}
+	u.j.registry.metrics.recordUpdate(pu)
+}
+
+// writeUpdate writes the prepared update pu of the job: its system.jobs row
+// and its job_info records.
+//
+// The progress, if updated, is always written in full as the job's
+// legacy_progress record rather than as a delta over a previous snapshot, even
+// when only a small part of it changed. crdb_internal.system_jobs, and so SHOW
+// JOBS, as well as loadJobQuery and the jobs of older nodes, read the latest
+// legacy_progress record directly in SQL and rely on it being complete, so
+// delta-encoded progress would need to be stored under separate keys alongside
+// that full snapshot, which would not reduce what is written.
+func (u Updater) writeUpdate(ctx context.Context, pu *pendingUpdate) error {
+	if err := u.writeJobsRow(ctx, pu); err != nil {
 		return err
 	}
-	if j.session != nil {
-		if row[3] == tree.DNull {
-			return errors.Errorf(
-				"with status %q: expected session %q but found NULL",
-				status, j.session.ID())
+
+	// Insert the job payload and progress into the system.jobs_info table.
+	infoStorage := u.j.InfoStorage(u.txn)
+	infoStorage.claimChecked = true
+	// The payload and progress, if they changed, are written in the same
+	// statement as the records identifying the update, below.
+	updateInfo := make(map[string][]byte, 5)
+	if pu.payloadBytes != nil {
+		u.checkPayloadSize(ctx, len(pu.payloadBytes))
+		value, err := encodePayload(ctx, u.j.registry.settings, pu.payloadBytes)
+		if err != nil {
+			return err
 		}
-		storedSession := []byte(*row[3].(*tree.DBytes))
-		if !bytes.Equal(storedSession, j.session.ID().UnsafeBytes()) {
-			return errors.Errorf(
-				"with status %q: expected session %q but found %q",
-				status, j.session.ID(), sqlliveness.SessionID(storedSession))
+		updateInfo[LegacyPayloadKey] = value
+	}
+	if pu.progressBytes != nil {
+		if retained := progressLineageRetainedVersions.Get(&u.j.registry.settings.SV); retained > 1 {
+			if err := retainProgress(ctx, infoStorage, retained); err != nil {
+				return err
+			}
 		}
-	} else {
-		log.VInfof(ctx, 1, "job %d: update called with no session ID", j.ID())
+		updateInfo[LegacyProgressKey] = pu.progressBytes
+	}
+	if err := u.writeUpdateInfo(ctx, infoStorage, pu); err != nil {
+		return err
 	}
+	// Record which SQL instance performed this update for debugging purposes,
+	// and bump the update sequence number.
+	instanceID := strconv.FormatInt(int64(u.j.registry.ID()), 10)
+	updateInfo[lastUpdatedByKey] = []byte(instanceID)
+	updateInfo[sequenceKey] = []byte(strconv.FormatInt(pu.md.Sequence+1, 10))
+	return infoStorage.WriteBatch(ctx, updateInfo)
+}
 
-	lastRun, ok := row[4].(*tree.DTimestamp)
-	if !ok {
-		return errors.AssertionFailedf("expected timestamp last_run, but got %T", lastRun)
+// DryRunUpdate computes the update of the job that updateFn asks for, as
+// Update would, including running the BeforeUpdate testing knob, but writes
+// nothing. It returns the statement that would update the job's system.jobs
+// row, along with its arguments, or an empty statement if the row would not be
+// updated. Writes to system.job_info are not included.
+func (u Updater) DryRunUpdate(
+	ctx context.Context, updateFn UpdateFn,
+) (sql string, args []interface{}, _ error) {
+	if u.txn == nil {
+		err := u.internalDB().Txn(ctx, func(ctx context.Context, txn isql.Txn) (err error) {
+			u.txn = txn
+			sql, args, err = u.DryRunUpdate(ctx, updateFn)
+			return err
+		})
+		return sql, args, err
 	}
-	numRuns, ok := row[5].(*tree.DInt)
-	if !ok {
-		return errors.AssertionFailedf("expected int num_runs, but got %T", numRuns)
+	ctx, sp := tracing.ChildSpan(ctx, "dry-run-update-job")
+	defer sp.Finish()
+
+	u.dryRun = true
+	md, err := u.loadMetadata(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	pu, err := u.prepareUpdate(ctx, md, updateFn)
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "job %d", u.j.ID())
+	}
+	if pu == nil {
+		return "", nil, nil
 	}
+	sql, args = u.jobsRowStatement(pu)
+	return sql, args, nil
+}
+
+// pendingUpdate is an update of a job computed by prepareUpdate and ready to
+// be written.
+type pendingUpdate struct {
+	// md is the job's metadata before the update.
+	md JobMetadata
+	ju JobUpdater
+	// statusChanged is set if the update changes the job's status.
+	statusChanged bool
+	// payloadBytes and progressBytes are the marshaled payload and progress
+	// to be written, if they were updated.
+	payloadBytes  []byte
+	progressBytes []byte
+}
+
+// writesStatusOrPayload returns whether the update sets the job's status or
+// writes its payload, as opposed to only its progress, run stats or info
+// records. Such updates must not run under isolation levels which tolerate
+// write skew; see Updater.WithIsolation.
+func (pu *pendingUpdate) writesStatusOrPayload() bool {
+	return pu.ju.md.Status != "" || pu.payloadBytes != nil
+}
 
-	md := JobMetadata{
-		ID:       j.ID(),
-		Status:   status,
-		Payload:  payload,
-		Progress: progress,
-		RunStats: &RunStats{
-			NumRuns: int(*numRuns),
-			LastRun: lastRun.Time,
-		},
+// prepareUpdate invokes updateFn on the job's current metadata md and
+// computes the resulting update. It returns nil if there is nothing to write.
+func (u Updater) prepareUpdate(
+	ctx context.Context, md JobMetadata, updateFn UpdateFn,
+) (*pendingUpdate, error) {
+	j := u.j
+	status := md.Status
+	pu := &pendingUpdate{md: md}
+	ju := &pu.ju
+	ju.loadedPayload = md.Payload
+	ju.loadedProgress = md.Progress
+	ju.loadedRunStats = md.RunStats
+	ju.now = u.now
+	// The UpdateFn may modify the loaded progress in place, so it is
+	// marshaled beforehand to detect redundant writes. The loaded payload's
+	// bytes are kept in md.payloadBytes.
+	var loadedProgressBytes []byte
+	var loadedModifiedMicros int64
+	if u.skipRedundantWrites {
+		var err error
+		if loadedProgressBytes, err = protoutil.Marshal(md.Progress); err != nil {
+			return nil, err
+		}
+		loadedModifiedMicros = md.Progress.ModifiedMicros
+	}
+	if err := updateFn(u.txn, md, ju); err != nil {
+		return nil, err
+	}
+	// The UpdateFn may have run for a while: don't write state computed on
+	// behalf of a caller which has since given up.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(ju.progressMutations) > 0 {
+		p := ju.md.Progress
+		if p == nil {
+			p = md.Progress
+		}
+		for _, fn := range ju.progressMutations {
+			fn(p)
+		}
+		ju.UpdateProgress(p)
 	}
 
-	var ju JobUpdater
-	if err := updateFn(u.txn, md, &ju); err != nil {
-		return err
+	if ju.md.Status != "" && !IsValidStatusTransition(status, ju.md.Status) {
+		return nil, errors.Wrapf(ErrIllegalStatusTransition, "from %s to %s", status, ju.md.Status)
+	}
+	if ju.md.RunStats != nil && md.RunStats != nil && !ju.runStatsReset &&
+		ju.md.RunStats.NumRuns < md.RunStats.NumRuns {
+		return nil, errors.Wrapf(ErrRunStatsRegression,
+			"from %d to %d", md.RunStats.NumRuns, ju.md.RunStats.NumRuns)
+	}
+	if ju.checkpointID != nil && *ju.checkpointID <= md.CheckpointID {
+		return nil, errors.Wrapf(ErrCheckpointRegression,
+			"from %d to %d", md.CheckpointID, *ju.checkpointID)
+	}
+	if fn := j.registry.knobs.SkipUpdate; fn != nil && fn(md, ju.md) {
+		return nil, nil
 	}
 
 	// a job status is considered updated if:
@@ -182,14 +979,15 @@ WHERE id = $1
 	// Since this may not be in the case in the future we add condition #2. #3 is
 	// required when a job starts because it may already have a "running" status.
 	//
-	if ju.md.Status != "" &&
-		(ju.md.Status != status || (ju.md.Status == StatusRunning && status == StatusRunning)) {
+	pu.statusChanged = ju.md.Status != "" &&
+		(ju.md.Status != status || (ju.md.Status == StatusRunning && status == StatusRunning))
+	if pu.statusChanged && !u.dryRun {
 		u.txn.KV().AddCommitTrigger(func(ctx context.Context) {
 			p := ju.md.Payload
 			// In some cases, ju.md.Payload may be nil, such as a cancel-requested status update.
 			// In this case, payload is used.
 			if p == nil {
-				p = payload
+				p = md.Payload
 			}
 			// If run stats has been updated, use the updated run stats.
 			rs := md.RunStats
@@ -197,100 +995,252 @@ WHERE id = $1
 				rs = ju.md.RunStats
 			}
 			LogStatusChangeStructured(ctx, md.ID, p.Type().String(), p, rs, status, ju.md.Status)
+			j.registry.publishStatusChange(md.ID, p.Type(), status, ju.md.Status)
 		})
 	}
 	if j.registry.knobs.BeforeUpdate != nil {
 		if err := j.registry.knobs.BeforeUpdate(md, ju.md); err != nil {
-			return err
+			return nil, err
+		}
+	}
+
+	if ju.md.Payload != nil {
+		var err error
+		pu.payloadBytes, err = protoutil.Marshal(ju.md.Payload)
+		if err != nil {
+			return nil, err
+		}
+		// Rewriting an unchanged payload would only add a redundant version of
+		// the job's legacy_payload record.
+		if md.payloadBytes != nil && bytes.Equal(pu.payloadBytes, md.payloadBytes) {
+			ju.md.Payload = nil
+			pu.payloadBytes = nil
+			j.registry.metrics.UpdatesPayloadSkipped.Inc(1)
+		}
+	}
+	if u.skipRedundantWrites && ju.md.Progress != nil {
+		progress := *ju.md.Progress
+		progress.ModifiedMicros = loadedModifiedMicros
+		progressBytes, err := protoutil.Marshal(&progress)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(progressBytes, loadedProgressBytes) {
+			ju.md.Progress = nil
 		}
 	}
 
 	if !ju.hasUpdates() {
+		return nil, nil
+	}
+
+	if progress := ju.md.Progress; progress != nil {
+		payload := ju.md.Payload
+		if payload == nil {
+			payload = md.Payload
+		}
+		if err := checkProgressKind(payload.Type(), progress); err != nil {
+			return nil, err
+		}
+		if u.normalizeProgress {
+			if orig, changed := normalizeProgress(progress); changed {
+				log.Warningf(ctx, "job %d: normalized invalid fraction completed %f to %f",
+					j.ID(), orig, progress.GetFractionCompleted())
+			}
+		}
+		if u.progressMicros != 0 {
+			progress.ModifiedMicros = u.progressMicros
+		} else {
+			progress.ModifiedMicros = timeutil.ToUnixMicros(u.now())
+		}
+		var err error
+		pu.progressBytes, err = protoutil.Marshal(progress)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return pu, nil
+}
+
+// checkProgressKind returns an error marked with ErrProgressKindMismatch if
+// progress is of a kind other than the one declared for jobs of type typ.
+func checkProgressKind(typ jobspb.Type, progress *jobspb.Progress) error {
+	opts, ok := getRegisterOptions(typ)
+	if !ok || opts.progressKind == ProgressKindAny {
+		return nil
+	}
+	var kind ProgressKind
+	switch progress.Progress.(type) {
+	case nil:
 		return nil
+	case *jobspb.Progress_FractionCompleted:
+		kind = ProgressKindFraction
+	case *jobspb.Progress_HighWater:
+		kind = ProgressKindHighWater
+	}
+	if kind != opts.progressKind {
+		return errors.Wrapf(ErrProgressKindMismatch,
+			"%s job reports %s progress, but %s progress was written",
+			typ, opts.progressKind, kind)
+	}
+	return nil
+}
+
+// stats returns the UpdateStats of the update.
+func (pu *pendingUpdate) stats() UpdateStats {
+	s := UpdateStats{
+		StatusChanged:   pu.statusChanged,
+		PayloadWritten:  pu.payloadBytes != nil,
+		ProgressWritten: pu.progressBytes != nil,
+		BytesWritten:    len(pu.payloadBytes) + len(pu.progressBytes),
+	}
+	for _, w := range pu.ju.infoWrites {
+		s.BytesWritten += len(w.value)
+	}
+	return s
+}
+
+// recordUpdate records the outcome of the written update pu.
+func (m *Metrics) recordUpdate(pu *pendingUpdate) {
+	if pu.statusChanged {
+		m.UpdatesStatusChanged.Inc(1)
+	}
+	if pu.payloadBytes != nil {
+		m.UpdatesPayloadWritten.Inc(1)
+	}
+	if pu.progressBytes != nil {
+		m.UpdatesProgressWritten.Inc(1)
+	}
+}
+
+// jobsColumns returns the system.jobs columns set by the update, along with
+// their new values.
+func (pu *pendingUpdate) jobsColumns() (columns []string, values []interface{}) {
+	if pu.ju.md.Status != "" {
+		columns = append(columns, "status")
+		values = append(values, pu.ju.md.Status)
+	}
+	if rs := pu.ju.md.RunStats; rs != nil && pu.ju.onlyLastRun {
+		columns = append(columns, "last_run")
+		values = append(values, rs.LastRun)
+	} else if rs != nil {
+		columns = append(columns, "last_run", "num_runs")
+		values = append(values, rs.LastRun, rs.NumRuns)
+	}
+	if id := pu.ju.md.ClaimSessionID; id != "" {
+		columns = append(columns, "claim_session_id", "claim_instance_id")
+		values = append(values, id.UnsafeBytes(), pu.ju.claimInstanceID)
 	}
+	return columns, values
+}
 
+// jobsRowStatement returns the statement that writes the system.jobs columns
+// set by the update, along with its arguments, or an empty statement if the
+// update sets none.
+func (u Updater) jobsRowStatement(pu *pendingUpdate) (string, []interface{}) {
 	// Build a statement of the following form, depending on which properties
 	// need updating:
 	//
 	//   UPDATE system.jobs
 	//   SET
 	//     [status = $2,]
-	//     [payload = $y,]
-	//     [progress = $z]
+	//     [last_run = $y,]
+	//     [num_runs = $z,]
+	//     [claim_session_id = $c, claim_instance_id = $d]
 	//   WHERE
 	//     id = $1
-
-	var setters []string
-	params := []interface{}{j.ID()} // $1 is always the job ID.
-	addSetter := func(column string, value interface{}) {
-		params = append(params, value)
-		setters = append(setters, fmt.Sprintf("%s = $%d", column, len(params)))
+	//     [AND status = $k]
+	columns, values := pu.jobsColumns()
+	if len(columns) == 0 {
+		return "", nil
 	}
-
-	if ju.md.Status != "" {
-		addSetter("status", ju.md.Status)
+	params := []interface{}{u.j.ID()} // $1 is always the job ID.
+	setters := make([]string, len(columns))
+	for i, column := range columns {
+		params = append(params, values[i])
+		setters[i] = fmt.Sprintf("%s = $%d", column, len(params))
 	}
-	if ju.md.RunStats != nil {
-		runStats = ju.md.RunStats
-		addSetter("last_run", ju.md.RunStats.LastRun)
-		addSetter("num_runs", ju.md.RunStats.NumRuns)
+	where := "id = $1"
+	if pu.ju.expectedStatus != "" {
+		params = append(params, pu.ju.expectedStatus)
+		where += fmt.Sprintf(" AND status = $%d", len(params))
 	}
+	updateStmt := fmt.Sprintf(
+		"UPDATE system.jobs SET %s WHERE %s",
+		strings.Join(setters, ", "), where,
+	)
+	return updateStmt, params
+}
 
-	var payloadBytes []byte
-	if ju.md.Payload != nil {
-		payload = ju.md.Payload
-		var err error
-		payloadBytes, err = protoutil.Marshal(payload)
-		if err != nil {
-			return err
+// writeJobsRow writes the system.jobs columns set by the update, if any.
+func (u Updater) writeJobsRow(ctx context.Context, pu *pendingUpdate) error {
+	updateStmt, params := u.jobsRowStatement(pu)
+	if updateStmt == "" {
+		return nil
+	}
+	n, err := u.txn.ExecEx(
+		ctx, "job-update", u.txn.KV(),
+		sessiondata.NodeUserSessionDataOverride,
+		updateStmt, params...,
+	)
+	if err != nil {
+		return err
+	}
+	if fn := u.j.registry.knobs.OverrideUpdateRowCount; fn != nil {
+		if override, ok := fn(); ok {
+			n = override
 		}
 	}
+	if n == 0 && pu.ju.expectedStatus != "" {
+		return errors.Mark(&ConcurrentStatusChangeError{
+			JobID: u.j.ID(), Expected: pu.ju.expectedStatus,
+		}, ErrConcurrentStatusChange)
+	}
+	if n != 1 {
+		return errors.Errorf(
+			"expected exactly one row affected, but %d rows affected by job update", n,
+		)
+	}
+	return nil
+}
 
-	var progressBytes []byte
-	if ju.md.Progress != nil {
-		progress = ju.md.Progress
-		progress.ModifiedMicros = timeutil.ToUnixMicros(u.now())
-		var err error
-		progressBytes, err = protoutil.Marshal(progress)
-		if err != nil {
+// writeUpdateInfo writes the job_info records that accompany the update,
+// other than the payload, progress and last updater: the records written by
+// the UpdateFn, the status history and the run stats history. A job reaching a
+// terminal status also lets go of its exclusion group.
+func (u Updater) writeUpdateInfo(
+	ctx context.Context, infoStorage InfoStorage, pu *pendingUpdate,
+) error {
+	for _, w := range pu.ju.infoWrites {
+		if err := infoStorage.write(ctx, w.key, w.value); err != nil {
 			return err
 		}
 	}
-
-	if len(setters) != 0 {
-		updateStmt := fmt.Sprintf(
-			"UPDATE system.jobs SET %s WHERE id = $1",
-			strings.Join(setters, ", "),
-		)
-		n, err := u.txn.ExecEx(
-			ctx, "job-update", u.txn.KV(),
-			sessiondata.NodeUserSessionDataOverride,
-			updateStmt, params...,
-		)
-		if err != nil {
+	if pu.ju.numLogEntries > 0 {
+		if err := trimJobLog(ctx, infoStorage, &u.j.registry.settings.SV); err != nil {
 			return err
 		}
-		if n != 1 {
-			return errors.Errorf(
-				"expected exactly one row affected, but %d rows affected by job update", n,
-			)
-		}
 	}
-
-	// Insert the job payload and progress into the system.jobs_info table.
-	infoStorage := j.InfoStorage(u.txn)
-	infoStorage.claimChecked = true
-	if payloadBytes != nil {
-		if err := infoStorage.WriteLegacyPayload(ctx, payloadBytes); err != nil {
+	if pu.statusChanged {
+		if err := appendStatusHistory(ctx, infoStorage, StatusHistoryEntry{
+			Time:   u.now(),
+			From:   pu.md.Status,
+			To:     pu.ju.md.Status,
+			Reason: pu.ju.statusReason,
+		}); err != nil {
 			return err
 		}
+		if pu.ju.md.Status.Terminal() {
+			if err := releaseExclusionGroup(ctx, infoStorage); err != nil {
+				return err
+			}
+		}
 	}
-	if progressBytes != nil {
-		if err := infoStorage.WriteLegacyProgress(ctx, progressBytes); err != nil {
+	if rs := pu.ju.md.RunStats; rs != nil && rs.NumRuns > pu.md.RunStats.NumRuns {
+		if err := recordRunStatsSample(ctx, infoStorage, &u.j.registry.settings.SV, *rs); err != nil {
 			return err
 		}
 	}
-
 	return nil
 }
 
@@ -300,6 +1250,16 @@ type RunStats struct {
 	NumRuns int
 }
 
+// NextRetry returns the earliest time at which the registry resumes a job with
+// these run stats, given the initial and maximum delays of its exponential
+// backoff. The delay is computed as in NextRunClause, i.e. as
+// initialDelay * (2^NumRuns - 1) capped at maxDelay, without jitter, since the
+// registry applies none.
+func (rs *RunStats) NextRetry(initialDelay, maxDelay time.Duration) time.Time {
+	delay, _ := backoffDelay(rs.NumRuns, initialDelay, maxDelay)
+	return rs.LastRun.Add(delay)
+}
+
 // JobMetadata groups the job metadata values passed to UpdateFn.
 type JobMetadata struct {
 	ID       jobspb.JobID
@@ -307,6 +1267,28 @@ type JobMetadata struct {
 	Payload  *jobspb.Payload
 	Progress *jobspb.Progress
 	RunStats *RunStats
+	// ClaimSessionID is the session of the coordinator which has claimed the
+	// job, or empty if the job is not claimed.
+	ClaimSessionID sqlliveness.SessionID
+	// Sequence is the number of updates of the job which wrote anything, so it
+	// can be used to tell whether the job was updated since it was read; see
+	// Updater.WithSequence.
+	Sequence int64
+	// LastUpdatedBy is the ID of the SQL instance which last updated the job,
+	// or zero if no update of the job has been recorded.
+	LastUpdatedBy base.SQLInstanceID
+	// Created is the time at which the job was created. Unlike the other
+	// fields, it is never written by updates.
+	Created time.Time
+	// CheckpointID is the ID of the checkpoint the job's progress was last
+	// written with, or zero if it never was; see
+	// JobUpdater.UpdateProgressWithCheckpoint.
+	CheckpointID uint64
+
+	// payloadBytes is the marshaled Payload as it was loaded, if it was, which
+	// updates compare the payload they write to, so as not to rewrite it
+	// unchanged.
+	payloadBytes []byte
 }
 
 // CheckRunningOrReverting returns an InvalidStatusError if md.Status is not
@@ -321,6 +1303,84 @@ func (md *JobMetadata) CheckRunningOrReverting() error {
 // JobUpdater accumulates changes to job metadata that are to be persisted.
 type JobUpdater struct {
 	md JobMetadata
+
+	// progressMutations are applied, in order, to the job's progress once the
+	// update function has returned. They apply to the progress passed to
+	// UpdateProgress, if any, or else to the job's current progress.
+	progressMutations []func(*jobspb.Progress)
+
+	// infoWrites are job_info records written along with the update, in order.
+	infoWrites []infoWrite
+
+	// statusReason is recorded in the status history along with the status
+	// change, if any.
+	statusReason string
+
+	// expectedStatus, if set, is the status the job must still be in for the
+	// status change to be written; see UpdateStatusCAS.
+	expectedStatus Status
+
+	// loadedPayload, loadedProgress and loadedRunStats are the job's payload,
+	// progress and run stats as loaded by the update.
+	loadedPayload  *jobspb.Payload
+	loadedProgress *jobspb.Progress
+	loadedRunStats *RunStats
+
+	// now returns the current time according to the updater's clock.
+	now func() time.Time
+
+	// onlyLastRun is set if the run stats of the update only change the job's
+	// last run; see TouchLastRun.
+	onlyLastRun bool
+
+	// claimInstanceID is the SQL instance of the session the job's claim is
+	// handed to by the update, if md.ClaimSessionID is set; see updateClaim.
+	claimInstanceID base.SQLInstanceID
+
+	// checkpointID, if set, is the checkpoint ID the progress of the update is
+	// written with; see UpdateProgressWithCheckpoint.
+	checkpointID *uint64
+
+	// runStatsReset is set if the run stats of the update were set by
+	// ResetNumRuns, which is allowed to decrease the job's number of runs.
+	runStatsReset bool
+
+	// numLogEntries is the number of entries appended to the job's log by the
+	// update; see AppendLogEntry.
+	numLogEntries int
+}
+
+// infoWrite is a pending write of a job_info record. A nil value deletes the
+// record.
+type infoWrite struct {
+	key   string
+	value []byte
+}
+
+// mutateProgress arranges for fn to be applied to the job's progress when the
+// update is persisted.
+func (ju *JobUpdater) mutateProgress(fn func(*jobspb.Progress)) {
+	ju.progressMutations = append(ju.progressMutations, fn)
+}
+
+// writeInfo arranges for the job_info record with the given key to be
+// replaced by value, or deleted if value is nil, when the update is
+// persisted.
+func (ju *JobUpdater) writeInfo(key string, value []byte) {
+	ju.infoWrites = append(ju.infoWrites, infoWrite{key: key, value: value})
+}
+
+// UpdateSetting sets the job-scoped setting with the given key to value (to
+// be persisted), or clears it if value is nil; see InfoStorage.WriteSetting.
+func (ju *JobUpdater) UpdateSetting(key string, value []byte) {
+	ju.writeInfo(settingKey(key), value)
+}
+
+// updateClaim hands the job's claim to the given session of the given SQL
+// instance (to be persisted).
+func (ju *JobUpdater) updateClaim(sessionID sqlliveness.SessionID, instanceID base.SQLInstanceID) {
+	ju.md.ClaimSessionID = sessionID
+	ju.claimInstanceID = instanceID
 }
 
 // UpdateStatus sets a new status (to be persisted).
@@ -328,6 +1388,14 @@ func (ju *JobUpdater) UpdateStatus(status Status) {
 	ju.md.Status = status
 }
 
+// UpdateStatusCAS sets a new status (to be persisted) provided the job is still
+// in the expected status when it is written. Otherwise, the update fails with
+// a ConcurrentStatusChangeError, which callers may retry on.
+func (ju *JobUpdater) UpdateStatusCAS(expected, status Status) {
+	ju.expectedStatus = expected
+	ju.UpdateStatus(status)
+}
+
 // UpdatePayload sets a new Payload (to be persisted).
 //
 // WARNING: the payload can be large (resulting in a large KV for each version);
@@ -341,8 +1409,161 @@ func (ju *JobUpdater) UpdateProgress(progress *jobspb.Progress) {
 	ju.md.Progress = progress
 }
 
+// UpdateProgressWithCheckpoint sets a new Progress (to be persisted), as
+// UpdateProgress does, tagged with the ID of the checkpoint it belongs to. The
+// ID is persisted along with the progress, in the same transaction, and is
+// surfaced as JobMetadata.CheckpointID, so that a resumer can verify that the
+// progress it loads matches its last durable checkpoint. Checkpoint IDs must
+// increase: the update fails with ErrCheckpointRegression otherwise.
+func (ju *JobUpdater) UpdateProgressWithCheckpoint(
+	progress *jobspb.Progress, checkpointID uint64,
+) {
+	ju.UpdateProgress(progress)
+	ju.checkpointID = &checkpointID
+	ju.writeInfo(checkpointIDKey, []byte(strconv.FormatUint(checkpointID, 10)))
+}
+
+// UpdateFractionCompleted sets the fraction completed of the job's progress,
+// which must be within [0, 1], in place, leaving the progress details as they
+// are. It fails for jobs whose progress is a high-water mark.
+func (ju *JobUpdater) UpdateFractionCompleted(fraction float32) error {
+	if math.IsNaN(float64(fraction)) || fraction < 0 || fraction > 1 {
+		return errors.Errorf("fraction completed %f is outside allowable range [0.0, 1.0]", fraction)
+	}
+	p, err := ju.progressForUpdate()
+	if err != nil {
+		return err
+	}
+	if _, ok := p.Progress.(*jobspb.Progress_HighWater); ok {
+		return errors.New("cannot update the fraction completed of a job that tracks a high-water mark")
+	}
+	p.Progress = &jobspb.Progress_FractionCompleted{FractionCompleted: fraction}
+	ju.UpdateProgress(p)
+	return nil
+}
+
+// progressForUpdate returns the job's progress as updated so far by the update,
+// or as loaded by it.
+func (ju *JobUpdater) progressForUpdate() (*jobspb.Progress, error) {
+	p := ju.md.Progress
+	if p == nil {
+		p = ju.loadedProgress
+	}
+	if p == nil {
+		return nil, errors.AssertionFailedf("progress updated outside of a job update")
+	}
+	return p, nil
+}
+
+// ConvertProgressToHighWater replaces the job's progress, of either kind, with
+// the high-water mark hw (to be persisted), e.g. for a job switching to a
+// streaming phase. Any estimate of the job's remaining time, which only makes
+// sense for a fraction completed, is cleared. hw must not be empty.
+func (ju *JobUpdater) ConvertProgressToHighWater(hw hlc.Timestamp) error {
+	if hw.IsEmpty() {
+		return errors.New("cannot convert the progress of a job to an empty high-water mark")
+	}
+	p, err := ju.progressForUpdate()
+	if err != nil {
+		return err
+	}
+	p.Progress = &jobspb.Progress_HighWater{HighWater: &hw}
+	ju.UpdateProgress(p)
+	ju.writeInfo(etaKey, nil)
+	return nil
+}
+
+// ConvertProgressToFraction replaces the job's progress, of either kind, with
+// the fraction completed f (to be persisted), which must be in [0.0, 1.0].
+func (ju *JobUpdater) ConvertProgressToFraction(f float32) error {
+	if math.IsNaN(float64(f)) || f < 0 || f > 1 {
+		return errors.Errorf("fraction completed %f is outside allowable range [0.0, 1.0]", f)
+	}
+	p, err := ju.progressForUpdate()
+	if err != nil {
+		return err
+	}
+	p.Progress = &jobspb.Progress_FractionCompleted{FractionCompleted: f}
+	ju.UpdateProgress(p)
+	return nil
+}
+
+// UpdateProgressWithDetails sets both the fraction completed, which must be
+// within [0, 1], and the details of the job's progress. The details must be
+// of the job's type. The fraction completed and a high-water mark are
+// alternatives in the progress, so, like UpdateFractionCompleted, this fails
+// for jobs whose progress is a high-water mark; the progress is left as it
+// was if an error is returned.
+func (ju *JobUpdater) UpdateProgressWithDetails(
+	fraction float32, details jobspb.ProgressDetails,
+) error {
+	if err := ju.UpdateFractionCompleted(fraction); err != nil {
+		return err
+	}
+	ju.md.Progress.Details = jobspb.WrapProgressDetails(details)
+	return nil
+}
+
 func (ju *JobUpdater) hasUpdates() bool {
-	return ju.md != JobMetadata{}
+	return ju.md != JobMetadata{} || len(ju.infoWrites) > 0
+}
+
+// UpdateETA sets the fraction completed of the job to completedFraction and
+// records an estimate of the time remaining until the job completes, based on
+// the time elapsed so far. No estimate is recorded, and any previous one is
+// cleared, if too little progress has been made for the estimate to be
+// meaningful. See Job.ETA.
+func (ju *JobUpdater) UpdateETA(completedFraction float32, elapsed time.Duration) {
+	ju.mutateProgress(func(p *jobspb.Progress) {
+		p.Progress = &jobspb.Progress_FractionCompleted{FractionCompleted: completedFraction}
+	})
+	remaining, ok := estimateRemaining(completedFraction, elapsed)
+	if !ok {
+		ju.writeInfo(etaKey, nil)
+		return
+	}
+	ju.writeInfo(etaKey, []byte(strconv.FormatInt(int64(remaining), 10)))
+}
+
+// UpdateRunningStatus sets the job's running status, a human-readable
+// description of its current step, e.g. "validating indexes", which SHOW JOBS
+// displays next to its fraction completed. The status is written as part of
+// the job's progress, as loaded in the update's transaction, since that is
+// where crdb_internal.system_jobs, and so SHOW JOBS, reads it from.
+func (ju *JobUpdater) UpdateRunningStatus(msg string) {
+	ju.mutateProgress(func(p *jobspb.Progress) {
+		p.RunningStatus = msg
+	})
+}
+
+// IncrementProgressCounter adds delta to the counter returned by accessor
+// within the job's progress, as loaded in the update's transaction. Since the
+// read and the write happen in the same transaction, concurrent increments of
+// the same counter do not clobber one another.
+func (ju *JobUpdater) IncrementProgressCounter(
+	accessor func(*jobspb.Progress) *int64, delta int64,
+) {
+	ju.IncrementProgressCounterOfTotal(accessor, nil /* total */, delta)
+}
+
+// IncrementProgressCounterOfTotal is like IncrementProgressCounter, but also
+// sets the fraction completed of the job to the ratio of the counter to the
+// total returned by the total accessor, if that total is positive.
+func (ju *JobUpdater) IncrementProgressCounterOfTotal(
+	accessor func(*jobspb.Progress) *int64, total func(*jobspb.Progress) int64, delta int64,
+) {
+	ju.mutateProgress(func(p *jobspb.Progress) {
+		c := accessor(p)
+		*c += delta
+		if total == nil {
+			return
+		}
+		if t := total(p); t > 0 {
+			p.Progress = &jobspb.Progress_FractionCompleted{
+				FractionCompleted: float32(*c) / float32(t),
+			}
+		}
+	})
 }
 
 // UpdateRunStats is used to update the exponential-backoff parameters last_run and
@@ -352,6 +1573,64 @@ func (ju *JobUpdater) UpdateRunStats(numRuns int, lastRun time.Time) {
 		NumRuns: numRuns,
 		LastRun: lastRun,
 	}
+	ju.onlyLastRun = false
+	ju.runStatsReset = false
+}
+
+// ResetNumRuns is like UpdateRunStats, but is allowed to decrease the job's
+// number of runs. It is meant for callers which deliberately reset the job's
+// exponential backoff, e.g. once a long-running job has returned to a steady
+// state; see also ResetRunStats.
+func (ju *JobUpdater) ResetNumRuns(numRuns int, lastRun time.Time) {
+	ju.UpdateRunStats(numRuns, lastRun)
+	ju.runStatsReset = true
+}
+
+// TouchLastRun sets the job's last run to now without changing its number of
+// runs, and so the backoff derived from it, e.g. to indicate that the job's
+// coordinator is still alive. Only the last_run column is written.
+func (ju *JobUpdater) TouchLastRun(now time.Time) {
+	runStats := ju.md.RunStats
+	if runStats == nil {
+		runStats = ju.loadedRunStats
+	}
+	var numRuns int
+	if runStats != nil {
+		numRuns = runStats.NumRuns
+	}
+	onlyLastRun := ju.md.RunStats == nil || ju.onlyLastRun
+	ju.UpdateRunStats(numRuns, now)
+	ju.onlyLastRun = onlyLastRun
+}
+
+// ResetRunStats clears the job's accumulated exponential backoff by resetting
+// its number of runs to zero, with its last run set to now. The job is then
+// eligible to be resumed right away.
+func (ju *JobUpdater) ResetRunStats() {
+	ju.ResetNumRuns(0, ju.now())
+}
+
+// RecordFailedRun records a failed run of the job: it sets the payload's error
+// to err, which must be non-nil, and increments the job's number of runs, with
+// its last run set to now. It applies on top of any payload or run stats
+// already passed to UpdatePayload or UpdateRunStats by the update.
+func (ju *JobUpdater) RecordFailedRun(err error, now time.Time) {
+	payload := ju.md.Payload
+	if payload == nil {
+		payload = ju.loadedPayload
+	}
+	payload.Error = err.Error()
+	ju.UpdatePayload(payload)
+
+	runStats := ju.md.RunStats
+	if runStats == nil {
+		runStats = ju.loadedRunStats
+	}
+	var numRuns int
+	if runStats != nil {
+		numRuns = runStats.NumRuns
+	}
+	ju.UpdateRunStats(numRuns+1, now)
 }
 
 func (ju *JobUpdater) PauseRequested(
@@ -374,15 +1653,44 @@ func (ju *JobUpdater) PauseRequestedWithFunc(
 			return err
 		}
 	}
-	ju.UpdateStatus(StatusPauseRequested)
-	md.Payload.PauseReason = reason
-	ju.UpdatePayload(md.Payload)
+	ju.PauseWithReason(reason)
 	log.Infof(ctx, "job %d: pause requested recorded with reason %s", md.ID, reason)
 	return nil
 }
 
+// PauseWithReason requests that the tracked job be paused, recording reason as
+// the reason it is paused, which is shown by SHOW JOBS until the job is
+// resumed. It applies on top of any payload already passed to UpdatePayload by
+// the update. Unlike PauseRequested, it leaves it to the update to reject jobs
+// which cannot be paused, with ErrIllegalStatusTransition.
+func (ju *JobUpdater) PauseWithReason(reason string) {
+	payload := ju.md.Payload
+	if payload == nil {
+		payload = ju.loadedPayload
+	}
+	ju.UpdateStatus(StatusPauseRequested)
+	payload.PauseReason = reason
+	ju.UpdatePayload(payload)
+	ju.writeInfo(pauseRequestedAtKey, []byte(strconv.FormatInt(ju.now().UnixNano(), 10)))
+}
+
+// getPauseRequestedAt returns the time at which the pause of the job was last
+// requested, if it was recorded.
+func getPauseRequestedAt(ctx context.Context, infoStorage InfoStorage) (time.Time, bool, error) {
+	value, ok, err := infoStorage.get(ctx, pauseRequestedAtKey)
+	if err != nil || !ok {
+		return time.Time{}, false, err
+	}
+	nanos, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return time.Time{}, false, errors.Wrapf(err, "job %d: invalid pause request time", infoStorage.j.ID())
+	}
+	return timeutil.Unix(0, nanos), true, nil
+}
+
 // Unpaused sets the status of the tracked job to running or reverting iff the
-// job is currently paused. It does not directly resume the job.
+// job is currently paused, clearing the reason it was paused for. It does not
+// directly resume the job.
 func (ju *JobUpdater) Unpaused(_ context.Context, md JobMetadata) error {
 	if md.Status == StatusRunning || md.Status == StatusReverting {
 		// Already resumed - do nothing.
@@ -398,6 +1706,10 @@ func (ju *JobUpdater) Unpaused(_ context.Context, md JobMetadata) error {
 	} else {
 		ju.UpdateStatus(StatusReverting)
 	}
+	if md.Payload.PauseReason != "" {
+		md.Payload.PauseReason = ""
+		ju.UpdatePayload(md.Payload)
+	}
 	return nil
 }
 
@@ -453,6 +1765,99 @@ func (u Updater) Update(ctx context.Context, updateFn UpdateFn) error {
 	return u.update(ctx, updateFn)
 }
 
+// TryUpdate is like Update, but doesn't wait for concurrent transactions which
+// hold a lock on the job's row: if the row is locked, the update is skipped and
+// done is false, without an error, so that callers can opportunistically write,
+// e.g., low-priority progress and try again later. It cannot be used with an
+// Updater bound to a transaction, since the transaction could not be used once
+// locking the row failed.
+func (u Updater) TryUpdate(ctx context.Context, updateFn UpdateFn) (done bool, _ error) {
+	if u.txn != nil {
+		return false, errors.AssertionFailedf("TryUpdate cannot be used in a transaction")
+	}
+	u.noWait = true
+	if err := u.update(ctx, updateFn); err != nil {
+		if pgerror.GetPGCode(err) == pgcode.LockNotAvailable {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// UpdateIfStatus is like Update, but only calls updateFn if the job is in the
+// expected status, returning an InvalidStatusError otherwise.
+func (u Updater) UpdateIfStatus(ctx context.Context, expected Status, updateFn UpdateFn) error {
+	return u.update(ctx, func(txn isql.Txn, md JobMetadata, ju *JobUpdater) error {
+		if md.Status != expected {
+			return &InvalidStatusError{md.ID, md.Status, "update", md.Payload.Error}
+		}
+		return updateFn(txn, md, ju)
+	})
+}
+
+// UpdateWithInfo is like Update, but also passes updateFn the job's InfoStorage
+// bound to the update's transaction, so that custom info records can be read
+// and written atomically with the job's metadata. Records written by updateFn
+// are written before the job's payload and progress.
+func (u Updater) UpdateWithInfo(ctx context.Context, updateFn UpdateFnInfo) error {
+	return u.update(ctx, func(txn isql.Txn, md JobMetadata, ju *JobUpdater) error {
+		info := u.j.InfoStorage(txn)
+		// The job's claim was checked when its metadata was loaded.
+		info.claimChecked = true
+		return updateFn(txn, md, ju, info)
+	})
+}
+
+// UpdateWithSideEffect is like Update, but additionally calls sideEffect with
+// the update's transaction once the job has been written, allowing callers to
+// write to their own tables atomically with the job update. If sideEffect
+// returns an error, the transaction, including the job update, is rolled back;
+// if the Updater was created with a transaction, it is up to the caller to
+// roll it back.
+func (u Updater) UpdateWithSideEffect(
+	ctx context.Context, updateFn UpdateFn, sideEffect func(txn isql.Txn) error,
+) error {
+	if u.txn == nil {
+		return u.internalDB().Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+			u.txn = txn
+			return u.UpdateWithSideEffect(ctx, updateFn, sideEffect)
+		})
+	}
+	j := u.j
+	j.mu.Lock()
+	payload, progress, status, runStats := j.mu.payload, j.mu.progress, j.mu.status, j.mu.runStats
+	j.mu.Unlock()
+	if err := u.update(ctx, updateFn); err != nil {
+		return err
+	}
+	if err := sideEffect(u.txn); err != nil {
+		// The job update will not be committed, so neither should the in-memory
+		// state of the job reflect it.
+		j.mu.Lock()
+		j.mu.payload, j.mu.progress, j.mu.status, j.mu.runStats = payload, progress, status, runStats
+		j.mu.Unlock()
+		return errors.Wrapf(err, "job %d: side effect of update", j.ID())
+	}
+	return nil
+}
+
 func (u Updater) now() time.Time {
 	return u.j.registry.clock.Now().GoTime()
 }
+
+// checkPayloadSize logs a warning, and calls the OnLargePayload testing knob,
+// if a payload of the given size being written exceeds jobs.payload.warn_size.
+func (u Updater) checkPayloadSize(ctx context.Context, size int) {
+	j := u.j
+	limit := payloadWarnSize.Get(&j.registry.settings.SV)
+	if limit == 0 || int64(size) <= limit {
+		return
+	}
+	log.Warningf(ctx, "job %d: writing a payload of %d bytes, which exceeds "+
+		"jobs.payload.warn_size (%d bytes); large state should be stored in "+
+		"separate info records", j.ID(), size, limit)
+	if fn := j.registry.knobs.OnLargePayload; fn != nil {
+		fn(j.ID(), size)
+	}
+}
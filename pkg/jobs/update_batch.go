@@ -0,0 +1,454 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/cockroach/pkg/jobs/jobspb"
+	"github.com/cockroachdb/cockroach/pkg/sql/isql"
+	"github.com/cockroachdb/cockroach/pkg/sql/sem/tree"
+	"github.com/cockroachdb/cockroach/pkg/sql/sessiondata"
+	"github.com/cockroachdb/cockroach/pkg/sql/types"
+	"github.com/cockroachdb/cockroach/pkg/util/tracing"
+	"github.com/cockroachdb/errors"
+)
+
+// loadJobsBatchQuery is the equivalent of loadJobQuery for the jobs whose IDs
+// are in the array $1.
+const loadJobsBatchQuery = `
+WITH
+  latestpayload AS (
+    SELECT DISTINCT ON (job_id) job_id, value
+    FROM system.job_info AS payload
+    WHERE info_key = 'legacy_payload' AND job_id = ANY($1)
+    ORDER BY job_id, written DESC
+  ),
+  latestprogress AS (
+    SELECT DISTINCT ON (job_id) job_id, value
+    FROM system.job_info AS progress
+    WHERE info_key = 'legacy_progress' AND job_id = ANY($1)
+    ORDER BY job_id, written DESC
+  ),
+  latestinfo AS (
+    SELECT job_id,
+           max(value) FILTER (WHERE info_key = '` + sequenceKey + `') AS sequence,
+           max(value) FILTER (WHERE info_key = '` + lastUpdatedByKey + `') AS updated_by,
+           max(value) FILTER (WHERE info_key = '` + checkpointIDKey + `') AS checkpoint_id
+    FROM (
+      SELECT DISTINCT ON (job_id, info_key) job_id, info_key, value
+      FROM system.job_info
+      WHERE info_key IN ('` + sequenceKey + `', '` + lastUpdatedByKey + `', '` + checkpointIDKey + `')
+        AND job_id = ANY($1)
+      ORDER BY job_id, info_key, written DESC
+    ) AS latest
+    GROUP BY job_id
+  )
+SELECT id, status, payload.value AS payload, progress.value AS progress,
+       COALESCE(last_run, created), COALESCE(num_runs, 0), claim_session_id,
+       info.sequence, info.updated_by, created, info.checkpoint_id
+FROM system.jobs AS j
+INNER JOIN latestpayload AS payload ON j.id = payload.job_id
+LEFT JOIN latestprogress AS progress ON j.id = progress.job_id
+LEFT JOIN latestinfo AS info ON j.id = info.job_id
+WHERE id = ANY($1)
+`
+
+// jobsColumnTypes are the types of the system.jobs columns set by updates.
+var jobsColumnTypes = map[string]string{
+	"status":            "STRING",
+	"last_run":          "TIMESTAMP",
+	"num_runs":          "INT8",
+	"claim_session_id":  "BYTES",
+	"claim_instance_id": "INT8",
+}
+
+// UpdateBatch updates the jobs with the given IDs in a single transaction,
+// invoking updateFn for each of them in turn, as Updater.Update would. The
+// jobs are loaded with a single query, and their system.jobs rows and their
+// payloads, progress, last updaters and sequence numbers are written with a
+// statement per set of updated columns or job_info key rather than per job.
+// If updateFn, or any write, fails for any job, none of the jobs is updated.
+//
+// The Updater options set by opts, e.g. WithIsolation or
+// WithStatementTimeout, apply to the updates of all of the jobs, and the
+// statement timeout to the loading and to the writing of the jobs as a whole.
+// Other than that, each job is updated as by Updater.Update, including the
+// testing knobs and the payload size warning.
+//
+// As with jobs loaded by LoadJobWithTxn, the jobs' claims are not checked.
+func (r *Registry) UpdateBatch(
+	ctx context.Context, ids []jobspb.JobID, updateFn UpdateFn, opts ...func(Updater) Updater,
+) error {
+	withOpts := func(u Updater) Updater {
+		for _, opt := range opts {
+			u = opt(u)
+		}
+		return u
+	}
+	isoLevel := withOpts(Updater{}).isoLevel
+	return r.db.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+		if err := txn.KV().SetIsoLevel(isoLevel); err != nil {
+			return err
+		}
+		return r.updateBatch(ctx, txn, ids, updateFn, withOpts)
+	})
+}
+
+func (r *Registry) updateBatch(
+	ctx context.Context,
+	txn isql.Txn,
+	ids []jobspb.JobID,
+	updateFn UpdateFn,
+	withOpts func(Updater) Updater,
+) error {
+	ctx, sp := tracing.ChildSpan(ctx, "update-job-batch")
+	defer sp.Finish()
+
+	opts := withOpts(Updater{})
+	var mds map[jobspb.JobID]JobMetadata
+	if err := opts.withStatementTimeout(ctx, "loading jobs", func(ctx context.Context) (err error) {
+		mds, err = loadMetadataBatch(ctx, txn, ids)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	updaters := make(map[jobspb.JobID]Updater, len(ids))
+	var pending []*pendingUpdate
+	for _, id := range ids {
+		u := withOpts((&Job{id: id, registry: r}).WithTxn(txn))
+		pu, err := u.prepareUpdate(ctx, mds[id], updateFn)
+		if err != nil {
+			return errors.Wrapf(err, "job %d", id)
+		}
+		if pu == nil {
+			r.metrics.UpdatesNoop.Inc(1)
+			continue
+		}
+		if err := u.checkIsolation(pu); err != nil {
+			return errors.Wrapf(err, "job %d", id)
+		}
+		updaters[id] = u
+		pending = append(pending, pu)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	if err := opts.withStatementTimeout(ctx, "writing jobs", func(ctx context.Context) error {
+		return r.writeUpdateBatch(ctx, txn, updaters, pending)
+	}); err != nil {
+		return err
+	}
+	for _, pu := range pending {
+		updaters[pu.md.ID].finishUpdate(pu)
+	}
+	return nil
+}
+
+// writeUpdateBatch writes the prepared updates of the jobs of a batch, as
+// writeUpdate would write each of them.
+func (r *Registry) writeUpdateBatch(
+	ctx context.Context, txn isql.Txn, updaters map[jobspb.JobID]Updater, pending []*pendingUpdate,
+) error {
+	if err := writeJobsRowsBatch(ctx, txn, pending); err != nil {
+		return err
+	}
+
+	var payloads, progresses, lastUpdatedBy, sequences []batchedInfoWrite
+	retained := progressLineageRetainedVersions.Get(&r.settings.SV)
+	instanceID := []byte(strconv.FormatInt(int64(r.ID()), 10))
+	for _, pu := range pending {
+		id := pu.md.ID
+		if pu.payloadBytes != nil {
+			updaters[id].checkPayloadSize(ctx, len(pu.payloadBytes))
+			value, err := encodePayload(ctx, r.settings, pu.payloadBytes)
+			if err != nil {
+				return errors.Wrapf(err, "job %d", id)
+			}
+			payloads = append(payloads, batchedInfoWrite{id, value})
+		}
+		if pu.progressBytes != nil {
+			if retained > 1 {
+				if err := retainProgress(ctx, updaters[id].j.InfoStorage(txn), retained); err != nil {
+					return errors.Wrapf(err, "job %d", id)
+				}
+			}
+			progresses = append(progresses, batchedInfoWrite{id, pu.progressBytes})
+		}
+		lastUpdatedBy = append(lastUpdatedBy, batchedInfoWrite{id, instanceID})
+		sequence := []byte(strconv.FormatInt(pu.md.Sequence+1, 10))
+		sequences = append(sequences, batchedInfoWrite{id, sequence})
+	}
+	for _, w := range []struct {
+		key    string
+		writes []batchedInfoWrite
+	}{
+		{LegacyPayloadKey, payloads},
+		{LegacyProgressKey, progresses},
+	} {
+		if err := writeInfoBatch(ctx, txn, w.key, w.writes); err != nil {
+			return err
+		}
+	}
+	for _, pu := range pending {
+		u := updaters[pu.md.ID]
+		if err := u.writeUpdateInfo(ctx, u.j.InfoStorage(txn), pu); err != nil {
+			return errors.Wrapf(err, "job %d", pu.md.ID)
+		}
+	}
+	if err := writeInfoBatch(ctx, txn, lastUpdatedByKey, lastUpdatedBy); err != nil {
+		return err
+	}
+	return writeInfoBatch(ctx, txn, sequenceKey, sequences)
+}
+
+// LoadMetadataBatch loads the current metadata of the jobs with the given IDs
+// with a single query, as Updater.Update would load it. The returned map omits
+// the jobs which do not exist.
+func (r *Registry) LoadMetadataBatch(
+	ctx context.Context, ids []jobspb.JobID,
+) (map[jobspb.JobID]JobMetadata, error) {
+	var mds map[jobspb.JobID]JobMetadata
+	if err := r.db.Txn(ctx, func(ctx context.Context, txn isql.Txn) (err error) {
+		mds, err = queryMetadataBatch(ctx, txn, ids)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return mds, nil
+}
+
+// loadMetadataBatch loads the current metadata of the jobs with the given IDs
+// with a single query. Unlike LoadMetadataBatch, it returns an error if any of
+// the jobs does not exist or appears more than once in ids.
+func loadMetadataBatch(
+	ctx context.Context, txn isql.Txn, ids []jobspb.JobID,
+) (map[jobspb.JobID]JobMetadata, error) {
+	mds, err := queryMetadataBatch(ctx, txn, ids)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[jobspb.JobID]struct{}, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			return nil, errors.AssertionFailedf("job %d appears more than once in the batch", id)
+		}
+		seen[id] = struct{}{}
+		if _, ok := mds[id]; !ok {
+			return nil, &JobNotFoundError{jobID: id}
+		}
+	}
+	return mds, nil
+}
+
+// queryMetadataBatch runs loadJobsBatchQuery for the given IDs and returns
+// the metadata of the jobs it found.
+func queryMetadataBatch(
+	ctx context.Context, txn isql.Txn, ids []jobspb.JobID,
+) (map[jobspb.JobID]JobMetadata, error) {
+	idArray := tree.NewDArray(types.Int)
+	for _, id := range ids {
+		if err := idArray.Append(tree.NewDInt(tree.DInt(id))); err != nil {
+			return nil, err
+		}
+	}
+	rows, err := txn.QueryBufferedEx(
+		ctx, "select-jobs", txn.KV(),
+		sessiondata.NodeUserSessionDataOverride,
+		loadJobsBatchQuery, idArray,
+	)
+	if err != nil {
+		return nil, err
+	}
+	mds := make(map[jobspb.JobID]JobMetadata, len(rows))
+	for _, row := range rows {
+		id := jobspb.JobID(*row[0].(*tree.DInt))
+		md := JobMetadata{ID: id}
+		if md.Status, err = unmarshalStatus(row[1]); err != nil {
+			return nil, errors.Wrapf(err, "job %d", id)
+		}
+		if md.Payload, md.payloadBytes, err = unmarshalPayloadWithBytes(row[2]); err != nil {
+			return nil, errors.Wrapf(err, "job %d", id)
+		}
+		if md.Progress, err = UnmarshalProgress(row[3]); err != nil {
+			return nil, errors.Wrapf(err, "job %d", id)
+		}
+		if md.RunStats, err = unmarshalRunStats(row[4], row[5]); err != nil {
+			return nil, errors.Wrapf(err, "job %d", id)
+		}
+		md.ClaimSessionID = unmarshalClaimSessionID(row[6])
+		if md.Sequence, err = unmarshalSequence(row[7]); err != nil {
+			return nil, errors.Wrapf(err, "job %d", id)
+		}
+		if md.LastUpdatedBy, err = unmarshalLastUpdatedBy(row[8]); err != nil {
+			return nil, errors.Wrapf(err, "job %d", id)
+		}
+		if md.Created, err = unmarshalCreated(row[9]); err != nil {
+			return nil, errors.Wrapf(err, "job %d", id)
+		}
+		if md.CheckpointID, err = unmarshalCheckpointID(row[10]); err != nil {
+			return nil, errors.Wrapf(err, "job %d", id)
+		}
+		mds[id] = md
+	}
+	return mds, nil
+}
+
+// writeJobsRowsBatch writes the system.jobs columns set by the pending
+// updates, with one statement per set of updated columns of the form:
+//
+//	UPDATE system.jobs AS j
+//	SET status = v.status, ...
+//	FROM (VALUES ($1::INT8, $2::STRING, ...), ...) AS v (id, status, ...)
+//	WHERE j.id = v.id [AND j.status = v.expected]
+//	RETURNING j.id
+func writeJobsRowsBatch(ctx context.Context, txn isql.Txn, pending []*pendingUpdate) error {
+	type group struct {
+		columns  []string
+		expected bool
+		updates  []*pendingUpdate
+	}
+	var groups []*group
+	byShape := make(map[string]*group)
+	for _, pu := range pending {
+		columns, _ := pu.jobsColumns()
+		if len(columns) == 0 {
+			continue
+		}
+		expected := pu.ju.expectedStatus != ""
+		shape := fmt.Sprintf("%s/%t", strings.Join(columns, ","), expected)
+		g, ok := byShape[shape]
+		if !ok {
+			g = &group{columns: columns, expected: expected}
+			byShape[shape] = g
+			groups = append(groups, g)
+		}
+		g.updates = append(g.updates, pu)
+	}
+
+	for _, g := range groups {
+		var params []interface{}
+		tuples := make([]string, len(g.updates))
+		for i, pu := range g.updates {
+			_, values := pu.jobsColumns()
+			values = append([]interface{}{pu.md.ID}, values...)
+			colTypes := append([]string{"INT8"}, make([]string, len(g.columns))...)
+			for j, column := range g.columns {
+				colTypes[j+1] = jobsColumnTypes[column]
+			}
+			if g.expected {
+				values = append(values, pu.ju.expectedStatus)
+				colTypes = append(colTypes, "STRING")
+			}
+			placeholders := make([]string, len(values))
+			for j, value := range values {
+				params = append(params, value)
+				placeholders[j] = fmt.Sprintf("$%d::%s", len(params), colTypes[j])
+			}
+			tuples[i] = "(" + strings.Join(placeholders, ", ") + ")"
+		}
+		setters := make([]string, len(g.columns))
+		for i, column := range g.columns {
+			setters[i] = fmt.Sprintf("%s = v.%s", column, column)
+		}
+		names := append([]string{"id"}, g.columns...)
+		where := "j.id = v.id"
+		if g.expected {
+			names = append(names, "expected")
+			where += " AND j.status = v.expected"
+		}
+		stmt := fmt.Sprintf(
+			"UPDATE system.jobs AS j SET %s FROM (VALUES %s) AS v (%s) WHERE %s RETURNING j.id",
+			strings.Join(setters, ", "), strings.Join(tuples, ", "), strings.Join(names, ", "), where,
+		)
+		rows, err := txn.QueryBufferedEx(
+			ctx, "job-update-batch", txn.KV(),
+			sessiondata.NodeUserSessionDataOverride,
+			stmt, params...,
+		)
+		if err != nil {
+			return err
+		}
+		if len(rows) == len(g.updates) {
+			continue
+		}
+		updated := make(map[jobspb.JobID]struct{}, len(rows))
+		for _, row := range rows {
+			updated[jobspb.JobID(*row[0].(*tree.DInt))] = struct{}{}
+		}
+		for _, pu := range g.updates {
+			if _, ok := updated[pu.md.ID]; ok {
+				continue
+			}
+			if g.expected {
+				return errors.Mark(&ConcurrentStatusChangeError{
+					JobID: pu.md.ID, Expected: pu.ju.expectedStatus,
+				}, ErrConcurrentStatusChange)
+			}
+			return errors.Errorf("job %d: no row affected by job update", pu.md.ID)
+		}
+	}
+	return nil
+}
+
+// batchedInfoWrite is a write of a job_info record of a given job, as part of
+// a batch of writes of the same info_key. A nil value deletes the record.
+type batchedInfoWrite struct {
+	jobID jobspb.JobID
+	value []byte
+}
+
+// writeInfoBatch replaces, or deletes, the job_info records with the given
+// info_key of the given jobs, with a single delete and a single insert.
+func writeInfoBatch(
+	ctx context.Context, txn isql.Txn, infoKey string, writes []batchedInfoWrite,
+) error {
+	if len(writes) == 0 {
+		return nil
+	}
+	idArray := tree.NewDArray(types.Int)
+	params := []interface{}{infoKey}
+	var tuples []string
+	for _, w := range writes {
+		if err := idArray.Append(tree.NewDInt(tree.DInt(w.jobID))); err != nil {
+			return err
+		}
+		if w.value == nil {
+			continue
+		}
+		params = append(params, w.jobID, w.value)
+		tuples = append(tuples, fmt.Sprintf("($%d, $1, now(), $%d)", len(params)-1, len(params)))
+	}
+	if _, err := txn.ExecEx(
+		ctx, "write-job-info-delete-batch", txn.KV(),
+		sessiondata.NodeUserSessionDataOverride,
+		"DELETE FROM system.job_info WHERE job_id = ANY($1) AND info_key::string = $2",
+		idArray, infoKey,
+	); err != nil {
+		return err
+	}
+	if len(tuples) == 0 {
+		return nil
+	}
+	_, err := txn.ExecEx(
+		ctx, "write-job-info-insert-batch", txn.KV(),
+		sessiondata.NodeUserSessionDataOverride,
+		"INSERT INTO system.job_info (job_id, info_key, written, value) VALUES "+
+			strings.Join(tuples, ", "),
+		params...,
+	)
+	return err
+}
@@ -12,23 +12,38 @@ package jobs_test
 
 import (
 	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/cockroachdb/cockroach/pkg/base"
 	"github.com/cockroachdb/cockroach/pkg/jobs"
 	"github.com/cockroachdb/cockroach/pkg/jobs/jobspb"
+	"github.com/cockroachdb/cockroach/pkg/jobs/jobstest"
 	"github.com/cockroachdb/cockroach/pkg/keyvisualizer"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/concurrency/isolation"
 	"github.com/cockroachdb/cockroach/pkg/security/username"
+	"github.com/cockroachdb/cockroach/pkg/settings/cluster"
 	"github.com/cockroachdb/cockroach/pkg/sql/isql"
 	"github.com/cockroachdb/cockroach/pkg/sql/sem/tree"
 	"github.com/cockroachdb/cockroach/pkg/sql/sessiondata"
+	"github.com/cockroachdb/cockroach/pkg/sql/sqlliveness"
+	"github.com/cockroachdb/cockroach/pkg/sql/sqlliveness/slstorage"
 	"github.com/cockroachdb/cockroach/pkg/testutils/serverutils"
+	"github.com/cockroachdb/cockroach/pkg/testutils/sqlutils"
 	"github.com/cockroachdb/cockroach/pkg/upgrade/upgradebase"
+	"github.com/cockroachdb/cockroach/pkg/util/ctxgroup"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
 	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
 	"github.com/cockroachdb/cockroach/pkg/util/protoutil"
 	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
 	"github.com/cockroachdb/cockroach/pkg/util/tracing/tracingpb"
+	"github.com/cockroachdb/cockroach/pkg/util/uuid"
+	"github.com/cockroachdb/errors"
 	"github.com/stretchr/testify/require"
 )
 
@@ -118,7 +133,24 @@ func TestUpdaterUpdatesJobInfo(t *testing.T) {
 					return err
 				}
 				jobInfoCount := tree.MustBeDInt(row[0])
-				require.Equal(t, jobsCount*2, jobInfoCount)
+				// Besides the payload and progress of every job, the job_info table
+				// holds the last updater and update sequence number of the job, which
+				// were written by its updates.
+				require.Equal(t, jobsCount*2+2, jobInfoCount)
+
+				rows, err := txn.QueryBufferedEx(ctx, "verify-job-query", txn.KV(),
+					sessiondata.NodeUserSessionDataOverride, `
+SELECT job_id, info_key FROM system.job_info
+WHERE info_key NOT IN ('legacy_payload', 'legacy_progress')
+ORDER BY info_key`)
+				if err != nil {
+					return err
+				}
+				require.Len(t, rows, 2)
+				for i, infoKey := range []string{"last_updated_by", "update_sequence"} {
+					require.Equal(t, int64(createdJob.ID()), int64(tree.MustBeDInt(rows[i][0])))
+					require.Equal(t, infoKey, string(tree.MustBeDString(rows[i][1])))
+				}
 
 				return nil
 			}))
@@ -161,3 +193,2786 @@ func TestUpdaterUpdatesJobInfo(t *testing.T) {
 		runTests(t, j)
 	})
 }
+
+// noAdoptionTestServerArgs returns server args under which the registry never
+// adopts jobs, so that tests can drive job updates directly. The passed knobs,
+// if any, are used as the base of the jobs testing knobs.
+func noAdoptionTestServerArgs(knobs *jobs.TestingKnobs) base.TestServerArgs {
+	if knobs == nil {
+		knobs = &jobs.TestingKnobs{}
+	}
+	knobs.DisableAdoptions = true
+	return base.TestServerArgs{
+		Knobs: base.TestingKnobs{
+			JobsTestingKnobs: knobs,
+			// DisableAdoptions needs this.
+			UpgradeManager: &upgradebase.TestingKnobs{
+				DontUseJobs:                       true,
+				SkipJobMetricsPollingJobBootstrap: true,
+			},
+			KeyVisualizer: &keyvisualizer.TestingKnobs{
+				SkipJobBootstrap: true,
+			},
+		},
+	}
+}
+
+// createImportJob creates an import job that is claimed by the registry.
+func createImportJob(t *testing.T, registry *jobs.Registry) *jobs.Job {
+	record := jobs.Record{
+		Details:  jobspb.ImportDetails{},
+		Progress: jobspb.ImportProgress{},
+		Username: username.TestUserName(),
+	}
+	job, err := registry.CreateJobWithTxn(
+		context.Background(), record, registry.MakeJobID(), nil, /* txn */
+	)
+	require.NoError(t, err)
+	return job
+}
+
+func TestUpdaterProgressNormalization(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+
+	setFraction := func(u jobs.Updater, f float32) {
+		require.NoError(t, u.Update(ctx, func(_ isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater) error {
+			md.Progress.Progress = &jobspb.Progress_FractionCompleted{FractionCompleted: f}
+			ju.UpdateProgress(md.Progress)
+			return nil
+		}))
+	}
+	storedFraction := func() float32 {
+		loaded, err := registry.LoadJob(ctx, j.ID())
+		require.NoError(t, err)
+		return loaded.FractionCompleted()
+	}
+
+	for _, tc := range []struct {
+		name     string
+		fraction float32
+		expected float32
+	}{
+		{"valid", 0.5, 0.5},
+		{"above one", 1.0000001, 1},
+		{"below zero", -0.1, 0},
+		{"NaN", float32(math.NaN()), 0},
+		{"infinite", float32(math.Inf(1)), 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			setFraction(j.NoTxn().WithProgressNormalization(), tc.fraction)
+			require.Equal(t, tc.expected, storedFraction())
+		})
+	}
+
+	t.Run("without option", func(t *testing.T) {
+		setFraction(j.NoTxn(), 1.0000001)
+		require.Equal(t, float32(1.0000001), storedFraction())
+	})
+}
+
+func TestJobLastUpdatedBy(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+
+	// Creating a job is not an update.
+	instanceID, _, err := j.LastUpdatedBy(ctx)
+	require.NoError(t, err)
+	require.Zero(t, instanceID)
+
+	// Updates which only write the progress are recorded.
+	require.NoError(t, j.NoTxn().FractionProgressed(ctx, jobs.FractionUpdater(0.5)))
+	instanceID, _, err = j.LastUpdatedBy(ctx)
+	require.NoError(t, err)
+	require.Equal(t, registry.ID(), instanceID)
+
+	// A progress-only update replaces the instance recorded by another one.
+	db := s.InternalDB().(isql.DB)
+	require.NoError(t, db.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+		return j.InfoStorage(txn).Write(ctx, "last_updated_by", []byte("999"))
+	}))
+	instanceID, _, err = j.LastUpdatedBy(ctx)
+	require.NoError(t, err)
+	require.Equal(t, base.SQLInstanceID(999), instanceID)
+	require.NoError(t, j.NoTxn().FractionProgressed(ctx, jobs.FractionUpdater(0.6)))
+	instanceID, _, err = j.LastUpdatedBy(ctx)
+	require.NoError(t, err)
+	require.Equal(t, registry.ID(), instanceID)
+
+	before := timeutil.Now()
+	require.NoError(t, j.NoTxn().SetDetails(ctx, jobspb.ImportDetails{URIs: []string{"new"}}))
+	instanceID, updated, err := j.LastUpdatedBy(ctx)
+	require.NoError(t, err)
+	require.Equal(t, registry.ID(), instanceID)
+	require.Equal(t, s.ApplicationLayer().SQLInstanceID(), instanceID)
+	require.False(t, updated.Before(before.Add(-time.Second)), "updated at %s", updated)
+}
+
+func TestUpdaterClaimAndCheckpoint(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	var failUpdate atomic.Bool
+	knobs := &jobs.TestingKnobs{
+		BeforeUpdate: func(orig, updated jobs.JobMetadata) error {
+			if failUpdate.Load() {
+				return errors.New("injected progress write failure")
+			}
+			return nil
+		},
+	}
+	ctx := context.Background()
+	s, sqlDB, _ := serverutils.StartServer(t, noAdoptionTestServerArgs(knobs))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	tdb := sqlutils.MakeSQLRunner(sqlDB)
+
+	j := createImportJob(t, registry)
+	session := j.Session()
+	tdb.Exec(t, `UPDATE system.jobs SET claim_session_id = NULL, claim_instance_id = NULL WHERE id = $1`, j.ID())
+	isClaimed := func() bool {
+		var claimed bool
+		tdb.QueryRow(t, `SELECT claim_session_id IS NOT NULL FROM system.jobs WHERE id = $1`, j.ID()).Scan(&claimed)
+		return claimed
+	}
+
+	progress := j.Progress()
+	progress.Progress = &jobspb.Progress_FractionCompleted{FractionCompleted: 0.25}
+
+	failUpdate.Store(true)
+	require.Error(t, j.NoTxn().ClaimAndCheckpoint(ctx, session, &progress))
+	require.False(t, isClaimed(), "claim should have been rolled back")
+
+	failUpdate.Store(false)
+	require.NoError(t, j.NoTxn().ClaimAndCheckpoint(ctx, session, &progress))
+	require.True(t, isClaimed())
+	loaded, err := registry.LoadJob(ctx, j.ID())
+	require.NoError(t, err)
+	require.Equal(t, float32(0.25), loaded.FractionCompleted())
+
+	// Jobs in a terminal status can't be claimed.
+	tdb.Exec(t, `UPDATE system.jobs SET status = $2, claim_session_id = NULL, claim_instance_id = NULL WHERE id = $1`,
+		j.ID(), jobs.StatusSucceeded)
+	err = j.NoTxn().ClaimAndCheckpoint(ctx, session, &progress)
+	var statusErr *jobs.InvalidStatusError
+	require.True(t, errors.As(err, &statusErr), "unexpected error: %v", err)
+	require.False(t, isClaimed(), "claim should have been rolled back")
+}
+
+func TestUpdaterCompleteIfFullyProgressed(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+
+	loadStatus := func(j *jobs.Job) jobs.Status {
+		loaded, err := registry.LoadJob(ctx, j.ID())
+		require.NoError(t, err)
+		return loaded.Status()
+	}
+
+	t.Run("fraction", func(t *testing.T) {
+		j := createImportJob(t, registry)
+		require.NoError(t, j.NoTxn().FractionProgressed(ctx, jobs.FractionUpdater(0.5)))
+		err := j.NoTxn().CompleteIfFullyProgressed(ctx)
+		require.True(t, errors.Is(err, jobs.ErrIncompleteProgress), "unexpected error: %v", err)
+		require.Equal(t, jobs.StatusRunning, loadStatus(j))
+
+		require.NoError(t, j.NoTxn().FractionProgressed(ctx, jobs.FractionUpdater(1.0)))
+		require.NoError(t, j.NoTxn().CompleteIfFullyProgressed(ctx))
+		require.Equal(t, jobs.StatusSucceeded, loadStatus(j))
+	})
+
+	t.Run("high-water", func(t *testing.T) {
+		j := createImportJob(t, registry)
+		hw := hlc.Timestamp{WallTime: 100}
+		require.NoError(t, j.NoTxn().Update(ctx, func(_ isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater) error {
+			md.Progress.Progress = &jobspb.Progress_HighWater{HighWater: &hw}
+			ju.UpdateProgress(md.Progress)
+			return nil
+		}))
+		err := j.NoTxn().CompleteIfFullyProgressed(ctx)
+		require.True(t, errors.Is(err, jobs.ErrIncompleteProgress), "unexpected error: %v", err)
+		err = j.NoTxn().CompleteIfHighWaterReached(ctx, hlc.Timestamp{WallTime: 200})
+		require.True(t, errors.Is(err, jobs.ErrIncompleteProgress), "unexpected error: %v", err)
+		require.Equal(t, jobs.StatusRunning, loadStatus(j))
+
+		require.NoError(t, j.NoTxn().CompleteIfHighWaterReached(ctx, hw))
+		require.Equal(t, jobs.StatusSucceeded, loadStatus(j))
+	})
+}
+
+func TestUpdaterUpdateProgressIfClaimLive(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s, sqlDB, _ := serverutils.StartServer(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	tdb := sqlutils.MakeSQLRunner(sqlDB)
+
+	j := createImportJob(t, registry)
+	loadFraction := func() float32 {
+		loaded, err := registry.LoadJob(ctx, j.ID())
+		require.NoError(t, err)
+		return loaded.FractionCompleted()
+	}
+	withFraction := func(f float32) *jobspb.Progress {
+		p := j.Progress()
+		p.Progress = &jobspb.Progress_FractionCompleted{FractionCompleted: f}
+		return &p
+	}
+
+	written, err := j.NoTxn().UpdateProgressIfClaimLive(ctx, withFraction(0.3))
+	require.NoError(t, err)
+	require.True(t, written)
+	require.Equal(t, float32(0.3), loadFraction())
+
+	// Hand the claim to a session that is not alive.
+	deadSession, err := slstorage.MakeSessionID([]byte("us"), uuid.MakeV4())
+	require.NoError(t, err)
+	tdb.Exec(t, `UPDATE system.jobs SET claim_session_id = $1 WHERE id = $2`,
+		deadSession.UnsafeBytes(), j.ID())
+
+	written, err = j.NoTxn().UpdateProgressIfClaimLive(ctx, withFraction(0.6))
+	require.NoError(t, err)
+	require.False(t, written)
+	require.Equal(t, float32(0.3), loadFraction())
+}
+
+func TestUpdaterClaimState(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s, sqlDB, _ := serverutils.StartServer(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	tdb := sqlutils.MakeSQLRunner(sqlDB)
+	j := createImportJob(t, registry)
+
+	checkClaim := func(expected jobs.ClaimState) {
+		t.Helper()
+		state, err := j.NoTxn().ClaimState(ctx)
+		require.NoError(t, err)
+		require.Equal(t, expected, state)
+		live, err := j.NoTxn().IsClaimedByLiveSession(ctx)
+		require.NoError(t, err)
+		require.Equal(t, expected == jobs.ClaimStateLiveSession, live)
+	}
+	checkClaim(jobs.ClaimStateLiveSession)
+
+	deadSession, err := slstorage.MakeSessionID([]byte("us"), uuid.MakeV4())
+	require.NoError(t, err)
+	tdb.Exec(t, `UPDATE system.jobs SET claim_session_id = $1 WHERE id = $2`,
+		deadSession.UnsafeBytes(), j.ID())
+	checkClaim(jobs.ClaimStateDeadSession)
+
+	tdb.Exec(t, `UPDATE system.jobs SET claim_session_id = NULL WHERE id = $1`, j.ID())
+	checkClaim(jobs.ClaimStateUnclaimed)
+}
+
+func TestUpdaterIncrementProgressCounter(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+
+	record := jobs.Record{
+		Details:  jobspb.ImportDetails{},
+		Progress: jobspb.ImportProgress{ResumePos: []int64{0, 100}},
+		Username: username.TestUserName(),
+	}
+	j, err := registry.CreateJobWithTxn(ctx, record, registry.MakeJobID(), nil /* txn */)
+	require.NoError(t, err)
+
+	counter := func(p *jobspb.Progress) *int64 { return &p.GetImport().ResumePos[0] }
+	total := func(p *jobspb.Progress) int64 { return p.GetImport().ResumePos[1] }
+
+	// Concurrent increments of the same counter must all land.
+	const workers, increments = 4, 5
+	g := ctxgroup.WithContext(ctx)
+	for i := 0; i < workers; i++ {
+		g.GoCtx(func(ctx context.Context) error {
+			for k := 0; k < increments; k++ {
+				if err := j.NoTxn().Update(ctx, func(_ isql.Txn, _ jobs.JobMetadata, ju *jobs.JobUpdater) error {
+					ju.IncrementProgressCounterOfTotal(counter, total, 1)
+					return nil
+				}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	require.NoError(t, g.Wait())
+
+	loaded, err := registry.LoadJob(ctx, j.ID())
+	require.NoError(t, err)
+	progress := loaded.Progress()
+	require.Equal(t, int64(workers*increments), *counter(&progress))
+	require.Equal(t, float32(workers*increments)/100, loaded.FractionCompleted())
+
+	// Without a total, the fraction completed is left alone.
+	require.NoError(t, j.NoTxn().Update(ctx, func(_ isql.Txn, _ jobs.JobMetadata, ju *jobs.JobUpdater) error {
+		ju.IncrementProgressCounter(counter, 10)
+		return nil
+	}))
+	loaded, err = registry.LoadJob(ctx, j.ID())
+	require.NoError(t, err)
+	progress = loaded.Progress()
+	require.Equal(t, int64(workers*increments+10), *counter(&progress))
+	require.Equal(t, float32(workers*increments)/100, loaded.FractionCompleted())
+}
+
+func TestUpdaterPauseIf(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+
+	overQuota := false
+	predicate := func(md jobs.JobMetadata) (bool, string) {
+		require.Equal(t, j.ID(), md.ID)
+		return overQuota, "over quota"
+	}
+
+	paused, err := j.NoTxn().PauseIf(ctx, predicate)
+	require.NoError(t, err)
+	require.False(t, paused)
+	loaded, err := registry.LoadJob(ctx, j.ID())
+	require.NoError(t, err)
+	require.Equal(t, jobs.StatusRunning, loaded.Status())
+
+	overQuota = true
+	paused, err = j.NoTxn().PauseIf(ctx, predicate)
+	require.NoError(t, err)
+	require.True(t, paused)
+	loaded, err = registry.LoadJob(ctx, j.ID())
+	require.NoError(t, err)
+	require.Equal(t, jobs.StatusPauseRequested, loaded.Status())
+	require.Equal(t, "over quota", loaded.Payload().PauseReason)
+
+	// A job that is already pause-requested is not transitioned again.
+	paused, err = j.NoTxn().PauseIf(ctx, predicate)
+	require.NoError(t, err)
+	require.False(t, paused)
+}
+
+func TestJobAsyncProgressBarrier(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	var (
+		block    atomic.Value // chan struct{}
+		writeErr atomic.Value // error
+	)
+	knobs := &jobs.TestingKnobs{
+		BeforeUpdate: func(_, updated jobs.JobMetadata) error {
+			if updated.Progress == nil {
+				return nil
+			}
+			if ch, ok := block.Load().(chan struct{}); ok && ch != nil {
+				<-ch
+			}
+			if err, ok := writeErr.Load().(error); ok && err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(knobs))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+
+	withFraction := func(f float32) *jobspb.Progress {
+		p := j.Progress()
+		p.Progress = &jobspb.Progress_FractionCompleted{FractionCompleted: f}
+		return &p
+	}
+	loadFraction := func() float32 {
+		loaded, err := registry.LoadJob(ctx, j.ID())
+		require.NoError(t, err)
+		return loaded.FractionCompleted()
+	}
+
+	// Buffered writes become durable once the barrier returns.
+	for _, f := range []float32{0.1, 0.2, 0.3} {
+		j.AsyncProgress(withFraction(f))
+	}
+	require.NoError(t, j.Barrier(ctx))
+	require.Equal(t, float32(0.3), loadFraction())
+
+	// The barrier waits for a write that is in flight.
+	unblock := make(chan struct{})
+	block.Store(unblock)
+	j.AsyncProgress(withFraction(0.5))
+	barrierErr := make(chan error, 1)
+	go func() { barrierErr <- j.Barrier(ctx) }()
+	select {
+	case err := <-barrierErr:
+		t.Fatalf("barrier returned before the write committed: %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(unblock)
+	require.NoError(t, <-barrierErr)
+	require.Equal(t, float32(0.5), loadFraction())
+
+	// The barrier surfaces the first write error.
+	writeErr.Store(errors.New("boom"))
+	j.AsyncProgress(withFraction(0.7))
+	require.ErrorContains(t, j.Barrier(ctx), "boom")
+	require.Equal(t, float32(0.5), loadFraction())
+}
+
+func TestUpdaterSetStatusWithReason(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+
+	require.NoError(t, j.NoTxn().SetStatusWithReason(ctx, jobs.StatusPaused, "maintenance window"))
+	require.NoError(t, j.NoTxn().SetStatusWithReason(ctx, jobs.StatusRunning, "maintenance over"))
+	require.NoError(t, j.NoTxn().SetStatusWithReason(ctx, jobs.StatusSucceeded, ""))
+
+	loaded, err := registry.LoadJob(ctx, j.ID())
+	require.NoError(t, err)
+	require.Equal(t, jobs.StatusSucceeded, loaded.Status())
+
+	history, err := j.StatusHistory(ctx)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	for i, expected := range []jobs.StatusHistoryEntry{
+		{From: jobs.StatusRunning, To: jobs.StatusPaused, Reason: "maintenance window"},
+		{From: jobs.StatusPaused, To: jobs.StatusRunning, Reason: "maintenance over"},
+		{From: jobs.StatusRunning, To: jobs.StatusSucceeded},
+	} {
+		require.Equal(t, expected.From, history[i].From)
+		require.Equal(t, expected.To, history[i].To)
+		require.Equal(t, expected.Reason, history[i].Reason)
+		require.False(t, history[i].Time.IsZero())
+	}
+
+	// Terminal jobs cannot be transitioned, and nothing is recorded.
+	err = j.NoTxn().SetStatusWithReason(ctx, jobs.StatusRunning, "retry")
+	require.ErrorContains(t, err, "cannot transition to running succeeded job")
+	history, err = j.StatusHistory(ctx)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+}
+
+func TestUpdaterDetectsClaimInstanceMismatch(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s, sqlDB, _ := serverutils.StartServer(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	tdb := sqlutils.MakeSQLRunner(sqlDB)
+
+	j := createImportJob(t, registry)
+	require.NotNil(t, j.Session())
+
+	// The claim is held by this instance under its session.
+	require.NoError(t, j.NoTxn().FractionProgressed(ctx, jobs.FractionUpdater(0.2)))
+
+	// The session still matches, but the claim now names another instance.
+	tdb.Exec(t, `UPDATE system.jobs SET claim_instance_id = claim_instance_id + 1 WHERE id = $1`, j.ID())
+	mismatches := registry.MetricsStruct().UpdatesSessionMismatch.Count()
+	err := j.NoTxn().FractionProgressed(ctx, jobs.FractionUpdater(0.4))
+	require.True(t, errors.Is(err, jobs.ErrClaimInstanceMismatch), "unexpected error: %v", err)
+	require.Equal(t, mismatches+1, registry.MetricsStruct().UpdatesSessionMismatch.Count())
+
+	loaded, err := registry.LoadJob(ctx, j.ID())
+	require.NoError(t, err)
+	require.Equal(t, float32(0.2), loaded.FractionCompleted())
+}
+
+func TestUpdaterEpochFencing(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+	require.Zero(t, j.Epoch())
+
+	epoch, err := j.NoTxn().BumpEpoch(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), epoch)
+	require.Equal(t, int64(1), j.Epoch())
+
+	stale := j.NoTxn().WithEpoch(epoch)
+	require.NoError(t, stale.FractionProgressed(ctx, jobs.FractionUpdater(0.3)))
+
+	// A new generation of the resumer bumps the epoch, fencing off the old one.
+	epoch, err = j.NoTxn().BumpEpoch(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), epoch)
+
+	err = stale.FractionProgressed(ctx, jobs.FractionUpdater(0.6))
+	require.True(t, errors.Is(err, jobs.ErrStaleEpoch), "unexpected error: %v", err)
+	_, err = stale.BumpEpoch(ctx)
+	require.True(t, errors.Is(err, jobs.ErrStaleEpoch), "unexpected error: %v", err)
+
+	require.NoError(t, j.NoTxn().WithEpoch(epoch).FractionProgressed(ctx, jobs.FractionUpdater(0.6)))
+	loaded, err := registry.LoadJob(ctx, j.ID())
+	require.NoError(t, err)
+	require.Equal(t, float32(0.6), loaded.FractionCompleted())
+}
+
+func TestUpdaterCheckpointWithFence(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+
+	checkpoint := func(fraction float32, expectedEpoch int64) bool {
+		progress := j.Progress()
+		progress.Progress = &jobspb.Progress_FractionCompleted{FractionCompleted: fraction}
+		written, err := j.NoTxn().CheckpointWithFence(ctx, &progress, expectedEpoch)
+		require.NoError(t, err)
+		return written
+	}
+	fractionCompleted := func() float32 {
+		loaded, err := registry.LoadJob(ctx, j.ID())
+		require.NoError(t, err)
+		return loaded.FractionCompleted()
+	}
+
+	// The job has never been adopted, so its epoch is zero.
+	require.True(t, checkpoint(0.2, 0))
+	require.Equal(t, float32(0.2), fractionCompleted())
+
+	epoch, err := j.NoTxn().BumpEpoch(ctx)
+	require.NoError(t, err)
+	require.True(t, checkpoint(0.4, epoch))
+	require.Equal(t, float32(0.4), fractionCompleted())
+
+	// A later generation of the resumer fences off the earlier one.
+	_, err = j.NoTxn().BumpEpoch(ctx)
+	require.NoError(t, err)
+	require.False(t, checkpoint(0.9, epoch))
+	require.False(t, checkpoint(0.9, 0))
+	require.Equal(t, float32(0.4), fractionCompleted())
+}
+
+func TestUpdaterIncrementRunStatsReportingDeadline(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+
+	const initialDelay, maxDelay = time.Second, 10 * time.Second
+	for _, tc := range []struct {
+		delay     time.Duration
+		saturated bool
+	}{
+		{delay: time.Second},
+		{delay: 3 * time.Second},
+		{delay: 7 * time.Second},
+		{delay: maxDelay, saturated: true},
+		{delay: maxDelay, saturated: true},
+	} {
+		before := timeutil.Now()
+		nextRun, saturated, err := j.NoTxn().IncrementRunStatsReportingDeadline(ctx, initialDelay, maxDelay)
+		require.NoError(t, err)
+		require.Equal(t, tc.saturated, saturated)
+		require.False(t, nextRun.Before(before.Add(tc.delay)))
+		require.True(t, nextRun.Before(timeutil.Now().Add(tc.delay+time.Second)))
+	}
+}
+
+func TestRunStatsNextRetry(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	const initialDelay, maxDelay = time.Second, 10 * time.Second
+	lastRun := timeutil.Unix(1000, 0)
+	for numRuns, delay := range []time.Duration{
+		0, time.Second, 3 * time.Second, 7 * time.Second, maxDelay, maxDelay,
+	} {
+		rs := jobs.RunStats{LastRun: lastRun, NumRuns: numRuns}
+		require.Equal(t, lastRun.Add(delay), rs.NextRetry(initialDelay, maxDelay), "num runs %d", numRuns)
+	}
+
+	// The registry applies its own backoff parameters.
+	registryInitialDelay, registryMaxDelay := 2*time.Second, time.Minute
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(&jobs.TestingKnobs{
+		IntervalOverrides: jobs.TestingIntervalOverrides{
+			RetryInitialDelay: &registryInitialDelay,
+			RetryMaxDelay:     &registryMaxDelay,
+		},
+	}))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	md := jobs.JobMetadata{RunStats: &jobs.RunStats{LastRun: lastRun, NumRuns: 2}}
+	require.Equal(t, lastRun.Add(6*time.Second), registry.NextRetry(md))
+	require.True(t, registry.NextRetry(jobs.JobMetadata{}).IsZero())
+}
+
+func TestUpdaterSwitchToHighWater(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+
+	require.NoError(t, j.NoTxn().FractionProgressed(ctx, jobs.FractionUpdater(0.7)))
+	_, switched, err := j.SwitchedToHighWater(ctx)
+	require.NoError(t, err)
+	require.False(t, switched)
+
+	initial := hlc.Timestamp{WallTime: 42}
+	require.NoError(t, j.NoTxn().SwitchToHighWater(ctx, initial))
+
+	loaded, err := registry.LoadJob(ctx, j.ID())
+	require.NoError(t, err)
+	require.Equal(t, jobs.StatusRunning, loaded.Status())
+	progress := loaded.Progress()
+	require.NotNil(t, progress.GetHighWater())
+	require.Equal(t, initial, *progress.GetHighWater())
+	at, switched, err := j.SwitchedToHighWater(ctx)
+	require.NoError(t, err)
+	require.True(t, switched)
+	require.False(t, at.IsZero())
+
+	// A job that already tracks a high-water mark cannot be switched again.
+	require.Error(t, j.NoTxn().SwitchToHighWater(ctx, hlc.Timestamp{WallTime: 43}))
+}
+
+func TestUpdaterUpdateWithSideEffect(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s, sqlDB, _ := serverutils.StartServer(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	tdb := sqlutils.MakeSQLRunner(sqlDB)
+	tdb.Exec(t, `CREATE TABLE defaultdb.public.side (k INT PRIMARY KEY)`)
+
+	j := createImportJob(t, registry)
+	setFraction := func(f float32) jobs.UpdateFn {
+		return func(_ isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater) error {
+			md.Progress.Progress = &jobspb.Progress_FractionCompleted{FractionCompleted: f}
+			ju.UpdateProgress(md.Progress)
+			return nil
+		}
+	}
+	insert := func(k int) func(isql.Txn) error {
+		return func(txn isql.Txn) error {
+			_, err := txn.Exec(ctx, "side-write", txn.KV(), `INSERT INTO defaultdb.public.side VALUES ($1)`, k)
+			return err
+		}
+	}
+	loadFraction := func() float32 {
+		loaded, err := registry.LoadJob(ctx, j.ID())
+		require.NoError(t, err)
+		return loaded.FractionCompleted()
+	}
+
+	require.NoError(t, j.NoTxn().UpdateWithSideEffect(ctx, setFraction(0.3), insert(1)))
+	require.Equal(t, float32(0.3), loadFraction())
+	tdb.CheckQueryResults(t, `SELECT k FROM defaultdb.public.side`, [][]string{{"1"}})
+
+	// The side effect fails: neither it nor the job update are committed.
+	err := j.NoTxn().UpdateWithSideEffect(ctx, setFraction(0.6), func(txn isql.Txn) error {
+		if err := insert(2)(txn); err != nil {
+			return err
+		}
+		return errors.New("side effect failed")
+	})
+	require.ErrorContains(t, err, "side effect failed")
+	require.Equal(t, float32(0.3), loadFraction())
+	require.Equal(t, float32(0.3), j.FractionCompleted())
+	tdb.CheckQueryResults(t, `SELECT k FROM defaultdb.public.side`, [][]string{{"1"}})
+}
+
+func TestUpdaterHeartbeat(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s, sqlDB, _ := serverutils.StartServer(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	tdb := sqlutils.MakeSQLRunner(sqlDB)
+	j := createImportJob(t, registry)
+
+	at, err := j.LastHeartbeat(ctx)
+	require.NoError(t, err)
+	require.True(t, at.IsZero())
+
+	require.NoError(t, j.NoTxn().FractionProgressed(ctx, jobs.FractionUpdater(0.3)))
+	progressWritten := func() (written time.Time) {
+		tdb.QueryRow(t, `SELECT max(written) FROM system.job_info WHERE job_id = $1 AND info_key = $2`,
+			j.ID(), jobs.LegacyProgressKey).Scan(&written)
+		return written
+	}
+	before := progressWritten()
+
+	require.NoError(t, j.NoTxn().Heartbeat(ctx))
+	first, err := j.LastHeartbeat(ctx)
+	require.NoError(t, err)
+	require.True(t, first.After(before), "heartbeat %s not after progress %s", first, before)
+
+	require.NoError(t, j.NoTxn().Heartbeat(ctx))
+	second, err := j.LastHeartbeat(ctx)
+	require.NoError(t, err)
+	require.True(t, second.After(first), "heartbeat %s not after %s", second, first)
+
+	// Neither heartbeat rewrote the progress.
+	require.Equal(t, before, progressWritten())
+	loaded, err := registry.LoadJob(ctx, j.ID())
+	require.NoError(t, err)
+	require.Equal(t, float32(0.3), loaded.FractionCompleted())
+}
+
+func TestUpdaterLoadMetadata(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s, sqlDB, _ := serverutils.StartServer(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	tdb := sqlutils.MakeSQLRunner(sqlDB)
+	j := createImportJob(t, registry)
+
+	// Progress the job through another handle, leaving j's in-memory copy
+	// stale.
+	other, err := registry.LoadJob(ctx, j.ID())
+	require.NoError(t, err)
+	require.NoError(t, other.NoTxn().FractionProgressed(ctx, jobs.FractionUpdater(0.4)))
+	require.Zero(t, j.FractionCompleted())
+
+	lastWritten := func() (written time.Time) {
+		tdb.QueryRow(t, `SELECT max(written) FROM system.job_info WHERE job_id = $1`, j.ID()).Scan(&written)
+		return written
+	}
+	before := lastWritten()
+
+	md, err := j.NoTxn().LoadMetadata(ctx)
+	require.NoError(t, err)
+	require.Equal(t, j.ID(), md.ID)
+	require.Equal(t, jobs.StatusRunning, md.Status)
+	require.Equal(t, float32(0.4), md.Progress.GetFractionCompleted())
+	require.Equal(t, float32(0.4), j.FractionCompleted())
+	// Nothing was written.
+	require.Equal(t, before, lastWritten())
+
+	// The claim is checked as it is for updates.
+	tdb.Exec(t, `UPDATE system.jobs SET claim_session_id = NULL WHERE id = $1`, j.ID())
+	_, err = j.NoTxn().LoadMetadata(ctx)
+	require.ErrorContains(t, err, "expected session")
+
+	tdb.Exec(t, `DELETE FROM system.jobs WHERE id = $1`, j.ID())
+	_, err = j.NoTxn().LoadMetadata(ctx)
+	require.True(t, jobs.HasJobNotFoundError(err), "unexpected error: %v", err)
+}
+
+func TestUpdaterUpdateStatusCAS(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+
+	status := func() jobs.Status {
+		loaded, err := registry.LoadJob(ctx, j.ID())
+		require.NoError(t, err)
+		return loaded.Status()
+	}
+
+	require.NoError(t, j.NoTxn().Update(ctx, func(
+		_ isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater,
+	) error {
+		ju.UpdateStatusCAS(jobs.StatusRunning, jobs.StatusPauseRequested)
+		return nil
+	}))
+	require.Equal(t, jobs.StatusPauseRequested, status())
+
+	// Another coordinator changes the status after it was read.
+	err := j.NoTxn().Update(ctx, func(
+		txn isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater,
+	) error {
+		if _, err := txn.ExecEx(
+			ctx, "concurrent-status-change", txn.KV(),
+			sessiondata.NodeUserSessionDataOverride,
+			`UPDATE system.jobs SET status = $2 WHERE id = $1`, md.ID, jobs.StatusPaused,
+		); err != nil {
+			return err
+		}
+		ju.UpdateStatusCAS(md.Status, jobs.StatusCancelRequested)
+		return nil
+	})
+	require.True(t, errors.Is(err, jobs.ErrConcurrentStatusChange), "unexpected error: %v", err)
+	var casErr *jobs.ConcurrentStatusChangeError
+	require.True(t, errors.As(err, &casErr))
+	require.Equal(t, j.ID(), casErr.JobID)
+	require.Equal(t, jobs.StatusPauseRequested, casErr.Expected)
+	require.Equal(t, jobs.StatusPauseRequested, status())
+}
+
+func TestJobUpdaterUpdateFractionCompleted(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+
+	setFraction := func(fraction float32) error {
+		return j.NoTxn().Update(ctx, func(_ isql.Txn, _ jobs.JobMetadata, ju *jobs.JobUpdater) error {
+			return ju.UpdateFractionCompleted(fraction)
+		})
+	}
+
+	require.NoError(t, j.NoTxn().Update(ctx, func(_ isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater) error {
+		md.Progress.GetImport().ResumePos = []int64{42}
+		ju.UpdateProgress(md.Progress)
+		return nil
+	}))
+	require.NoError(t, setFraction(0.7))
+	loaded, err := registry.LoadJob(ctx, j.ID())
+	require.NoError(t, err)
+	require.Equal(t, float32(0.7), loaded.FractionCompleted())
+	// The details are left alone.
+	require.Equal(t, []int64{42}, loaded.Progress().GetImport().ResumePos)
+
+	for _, fraction := range []float32{-0.1, 1.1, float32(math.NaN())} {
+		require.ErrorContains(t, setFraction(fraction), "outside allowable range")
+	}
+
+	require.NoError(t, j.NoTxn().SwitchToHighWater(ctx, hlc.Timestamp{WallTime: 1}))
+	require.ErrorContains(t, setFraction(0.8), "high-water mark")
+}
+
+func TestJobUpdaterUpdateProgressWithDetails(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+
+	update := func(fraction float32, resumePos int64) error {
+		return j.NoTxn().Update(ctx, func(_ isql.Txn, _ jobs.JobMetadata, ju *jobs.JobUpdater) error {
+			return ju.UpdateProgressWithDetails(fraction, jobspb.ImportProgress{ResumePos: []int64{resumePos}})
+		})
+	}
+	load := func() *jobs.Job {
+		loaded, err := registry.LoadJob(ctx, j.ID())
+		require.NoError(t, err)
+		return loaded
+	}
+
+	require.NoError(t, update(0.4, 7))
+	loaded := load()
+	require.Equal(t, float32(0.4), loaded.FractionCompleted())
+	require.Equal(t, []int64{7}, loaded.Progress().GetImport().ResumePos)
+	modified := loaded.Progress().ModifiedMicros
+	require.NotZero(t, modified)
+
+	// Invalid fractions leave the progress alone.
+	require.ErrorContains(t, update(1.5, 8), "outside allowable range")
+	loaded = load()
+	require.Equal(t, float32(0.4), loaded.FractionCompleted())
+	require.Equal(t, []int64{7}, loaded.Progress().GetImport().ResumePos)
+	require.Equal(t, modified, loaded.Progress().ModifiedMicros)
+
+	require.NoError(t, j.NoTxn().SwitchToHighWater(ctx, hlc.Timestamp{WallTime: 1}))
+	require.ErrorContains(t, update(0.5, 9), "high-water mark")
+}
+
+func TestUpdaterWithStats(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+
+	var stats jobs.UpdateStats
+	require.NoError(t, j.NoTxn().WithStats(&stats).FractionProgressed(ctx, jobs.FractionUpdater(0.5)))
+	require.False(t, stats.StatusChanged)
+	require.False(t, stats.PayloadWritten)
+	require.True(t, stats.ProgressWritten)
+	require.Positive(t, stats.BytesWritten)
+
+	require.NoError(t, j.NoTxn().WithStats(&stats).SetStatusWithReason(ctx, jobs.StatusPaused, ""))
+	require.True(t, stats.StatusChanged)
+	require.False(t, stats.ProgressWritten)
+
+	// Updates that write nothing report so.
+	require.NoError(t, j.NoTxn().WithStats(&stats).Update(ctx, func(
+		isql.Txn, jobs.JobMetadata, *jobs.JobUpdater,
+	) error {
+		return nil
+	}))
+	require.Equal(t, jobs.UpdateStats{}, stats)
+
+	// Failed updates leave the stats untouched.
+	stats.PayloadWritten = true
+	err := j.NoTxn().WithStats(&stats).Update(ctx, func(
+		_ isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater,
+	) error {
+		ju.UpdatePayload(md.Payload)
+		return errors.New("boom")
+	})
+	require.ErrorContains(t, err, "boom")
+	require.Equal(t, jobs.UpdateStats{PayloadWritten: true}, stats)
+}
+
+func TestUpdaterDryRunUpdate(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	var beforeUpdateCalls atomic.Int32
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(&jobs.TestingKnobs{
+		BeforeUpdate: func(orig, updated jobs.JobMetadata) error {
+			beforeUpdateCalls.Add(1)
+			return nil
+		},
+	}))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+	beforeUpdateCalls.Store(0)
+
+	sql, args, err := j.NoTxn().DryRunUpdate(ctx, func(
+		_ isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater,
+	) error {
+		ju.UpdateStatus(jobs.StatusPaused)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, "UPDATE system.jobs SET status = $2 WHERE id = $1", sql)
+	require.Equal(t, []interface{}{j.ID(), jobs.StatusPaused}, args)
+	require.Equal(t, int32(1), beforeUpdateCalls.Load())
+
+	// Nothing was written.
+	loaded, err := registry.LoadJob(ctx, j.ID())
+	require.NoError(t, err)
+	require.Equal(t, jobs.StatusRunning, loaded.Status())
+	history, err := j.StatusHistory(ctx)
+	require.NoError(t, err)
+	require.Empty(t, history)
+
+	// Updates that leave the system.jobs row alone produce no statement.
+	sql, args, err = j.NoTxn().DryRunUpdate(ctx, func(
+		_ isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater,
+	) error {
+		return ju.UpdateFractionCompleted(0.5)
+	})
+	require.NoError(t, err)
+	require.Empty(t, sql)
+	require.Empty(t, args)
+	require.Equal(t, int32(2), beforeUpdateCalls.Load())
+	loaded, err = registry.LoadJob(ctx, j.ID())
+	require.NoError(t, err)
+	require.Zero(t, loaded.FractionCompleted())
+}
+
+func TestUpdaterValidatesStatusTransitions(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	for _, tc := range []struct {
+		from, to jobs.Status
+		valid    bool
+	}{
+		{jobs.StatusPending, jobs.StatusRunning, true},
+		{jobs.StatusRunning, jobs.StatusPaused, true},
+		{jobs.StatusRunning, jobs.StatusReverting, true},
+		{jobs.StatusReverting, jobs.StatusFailed, true},
+		{jobs.StatusPauseRequested, jobs.StatusPaused, true},
+		{jobs.StatusPaused, jobs.StatusRunning, true},
+		{jobs.StatusSucceeded, jobs.StatusSucceeded, true},
+		{jobs.StatusSucceeded, jobs.StatusRunning, false},
+		{jobs.StatusFailed, jobs.StatusReverting, false},
+		{jobs.StatusRunning, jobs.StatusCanceled, false},
+		{jobs.StatusPaused, jobs.StatusSucceeded, false},
+	} {
+		require.Equal(t, tc.valid, jobs.IsValidStatusTransition(tc.from, tc.to), "%s -> %s", tc.from, tc.to)
+	}
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+
+	setStatus := func(status jobs.Status) error {
+		return j.NoTxn().Update(ctx, func(_ isql.Txn, _ jobs.JobMetadata, ju *jobs.JobUpdater) error {
+			ju.UpdateStatus(status)
+			return nil
+		})
+	}
+	status := func() jobs.Status {
+		loaded, err := registry.LoadJob(ctx, j.ID())
+		require.NoError(t, err)
+		return loaded.Status()
+	}
+
+	err := setStatus(jobs.StatusCanceled)
+	require.True(t, errors.Is(err, jobs.ErrIllegalStatusTransition), "unexpected error: %v", err)
+	require.Equal(t, jobs.StatusRunning, status())
+
+	require.NoError(t, setStatus(jobs.StatusRunning))
+	require.NoError(t, setStatus(jobs.StatusSucceeded))
+	require.NoError(t, setStatus(jobs.StatusSucceeded))
+	err = setStatus(jobs.StatusRunning)
+	require.True(t, errors.Is(err, jobs.ErrIllegalStatusTransition), "unexpected error: %v", err)
+	require.Equal(t, jobs.StatusSucceeded, status())
+}
+
+// injectedRetryError is a retryable error which, unlike a KV retry error for
+// the txn at hand, is not retried by the txn itself and so escapes it.
+type injectedRetryError struct{}
+
+func (injectedRetryError) Error() string            { return "injected retry error" }
+func (injectedRetryError) ClientVisibleRetryError() {}
+
+func TestUpdaterRetriesRetryableErrors(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s, sqlDB, _ := serverutils.StartServer(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	runner := sqlutils.MakeSQLRunner(sqlDB)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+
+	// failUpdates makes the first n attempts of an update fail with err and
+	// returns the number of attempts the update took.
+	failUpdates := func(n int, err error) (int, error) {
+		var attempts int
+		updateErr := j.NoTxn().Update(ctx, func(_ isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater) error {
+			attempts++
+			if attempts <= n {
+				return err
+			}
+			md.Progress.RunningStatus = "updated"
+			ju.UpdateProgress(md.Progress)
+			return nil
+		})
+		return attempts, updateErr
+	}
+
+	runner.Exec(t, "SET CLUSTER SETTING jobs.update.max_retries = 3")
+	attempts, err := failUpdates(2, injectedRetryError{})
+	require.NoError(t, err)
+	require.Equal(t, 3, attempts)
+
+	attempts, err = failUpdates(10, injectedRetryError{})
+	require.True(t, errors.HasType(err, injectedRetryError{}), "unexpected error: %v", err)
+	require.Equal(t, 4, attempts)
+
+	// Errors which aren't retryable are returned right away.
+	attempts, err = failUpdates(10, errors.New("boom"))
+	require.ErrorContains(t, err, "boom")
+	require.Equal(t, 1, attempts)
+
+	runner.Exec(t, "SET CLUSTER SETTING jobs.update.max_retries = 0")
+	attempts, err = failUpdates(1, injectedRetryError{})
+	require.True(t, errors.HasType(err, injectedRetryError{}), "unexpected error: %v", err)
+	require.Equal(t, 1, attempts)
+
+	// Retries stop once the context is canceled.
+	runner.Exec(t, "SET CLUSTER SETTING jobs.update.max_retries = 100")
+	cancelCtx, cancel := context.WithCancel(ctx)
+	var attemptsBeforeCancel int
+	err = j.NoTxn().Update(cancelCtx, func(_ isql.Txn, _ jobs.JobMetadata, _ *jobs.JobUpdater) error {
+		attemptsBeforeCancel++
+		if attemptsBeforeCancel == 2 {
+			cancel()
+		}
+		return injectedRetryError{}
+	})
+	require.Error(t, err)
+	require.Equal(t, 2, attemptsBeforeCancel)
+}
+
+func TestRegistryResetBackoff(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s, sqlDB, _ := serverutils.StartServer(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	runner := sqlutils.MakeSQLRunner(sqlDB)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+
+	lastRun := s.Clock().Now().GoTime().Add(-time.Hour)
+	require.NoError(t, j.NoTxn().Update(ctx, func(_ isql.Txn, _ jobs.JobMetadata, ju *jobs.JobUpdater) error {
+		ju.UpdateRunStats(5, lastRun)
+		return nil
+	}))
+
+	before := s.Clock().Now().GoTime()
+	require.NoError(t, registry.ResetBackoff(ctx, j.ID()))
+	var numRuns int
+	var resetLastRun time.Time
+	runner.QueryRow(t, "SELECT num_runs, last_run FROM system.jobs WHERE id = $1", j.ID()).
+		Scan(&numRuns, &resetLastRun)
+	require.Equal(t, 0, numRuns)
+	require.False(t, resetLastRun.Before(before.Truncate(time.Microsecond)),
+		"last run %s before reset at %s", resetLastRun, before)
+
+	require.NoError(t, registry.Succeeded(ctx, nil /* txn */, j.ID()))
+	err := registry.ResetBackoff(ctx, j.ID())
+	require.ErrorContains(t, err, "reset backoff of")
+}
+
+func TestJobMetadataClaimSessionID(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s, sqlDB, _ := serverutils.StartServer(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	runner := sqlutils.MakeSQLRunner(sqlDB)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+
+	claimSessionID := func() sqlliveness.SessionID {
+		var id sqlliveness.SessionID
+		require.NoError(t, j.NoTxn().Update(ctx, func(_ isql.Txn, md jobs.JobMetadata, _ *jobs.JobUpdater) error {
+			id = md.ClaimSessionID
+			return nil
+		}))
+		return id
+	}
+
+	var stored []byte
+	runner.QueryRow(t, "SELECT claim_session_id FROM system.jobs WHERE id = $1", j.ID()).Scan(&stored)
+	require.NotEmpty(t, stored)
+	require.Equal(t, sqlliveness.SessionID(stored), claimSessionID())
+
+	runner.Exec(t, "UPDATE system.jobs SET claim_session_id = NULL WHERE id = $1", j.ID())
+	require.Equal(t, sqlliveness.SessionID(""), claimSessionID())
+}
+
+func TestUpdateOutcomeMetrics(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s, sqlDB, _ := serverutils.StartServer(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	runner := sqlutils.MakeSQLRunner(sqlDB)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+	m := registry.MetricsStruct()
+
+	type counts struct {
+		status, payload, progress, noop, sessionMismatch int64
+	}
+	read := func() counts {
+		return counts{
+			status:          m.UpdatesStatusChanged.Count(),
+			payload:         m.UpdatesPayloadWritten.Count(),
+			progress:        m.UpdatesProgressWritten.Count(),
+			noop:            m.UpdatesNoop.Count(),
+			sessionMismatch: m.UpdatesSessionMismatch.Count(),
+		}
+	}
+	expectDelta := func(fn func() error, expected counts) {
+		before := read()
+		err := fn()
+		after := read()
+		require.Equal(t, expected, counts{
+			status:          after.status - before.status,
+			payload:         after.payload - before.payload,
+			progress:        after.progress - before.progress,
+			noop:            after.noop - before.noop,
+			sessionMismatch: after.sessionMismatch - before.sessionMismatch,
+		})
+		if expected.sessionMismatch == 0 {
+			require.NoError(t, err)
+		} else {
+			require.Error(t, err)
+		}
+	}
+
+	expectDelta(func() error {
+		return j.NoTxn().FractionProgressed(ctx, jobs.FractionUpdater(0.5))
+	}, counts{progress: 1})
+	expectDelta(func() error {
+		return j.NoTxn().SetDetails(ctx, jobspb.ImportDetails{URIs: []string{"new"}})
+	}, counts{payload: 1})
+	expectDelta(func() error {
+		return j.NoTxn().Update(ctx, func(isql.Txn, jobs.JobMetadata, *jobs.JobUpdater) error {
+			return nil
+		})
+	}, counts{noop: 1})
+	expectDelta(func() error {
+		return j.NoTxn().SetStatusWithReason(ctx, jobs.StatusPaused, "")
+	}, counts{status: 1})
+
+	runner.Exec(t, "UPDATE system.jobs SET claim_session_id = 'other' WHERE id = $1", j.ID())
+	expectDelta(func() error {
+		return j.NoTxn().FractionProgressed(ctx, jobs.FractionUpdater(0.6))
+	}, counts{sessionMismatch: 1})
+}
+
+func TestUpdaterUpdateIfStatus(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+
+	var called bool
+	setFraction := func(u jobs.Updater, expected jobs.Status, f float32) error {
+		called = false
+		return u.UpdateIfStatus(ctx, expected, func(_ isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater) error {
+			called = true
+			return ju.UpdateFractionCompleted(f)
+		})
+	}
+
+	require.NoError(t, setFraction(j.NoTxn(), jobs.StatusRunning, 0.3))
+	require.True(t, called)
+
+	err := setFraction(j.NoTxn(), jobs.StatusPaused, 0.4)
+	var statusErr *jobs.InvalidStatusError
+	require.True(t, errors.As(err, &statusErr), "unexpected error: %v", err)
+	require.False(t, called)
+
+	// The update runs in the caller's transaction, if any.
+	require.NoError(t, s.InternalDB().(isql.DB).Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+		return setFraction(j.WithTxn(txn), jobs.StatusRunning, 0.5)
+	}))
+	loaded, err := registry.LoadJob(ctx, j.ID())
+	require.NoError(t, err)
+	require.Equal(t, float32(0.5), loaded.FractionCompleted())
+}
+
+func TestAfterUpdateKnob(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	var calls []jobs.JobMetadata
+	knobs := &jobs.TestingKnobs{
+		AfterUpdate: func(_, updated jobs.JobMetadata) {
+			calls = append(calls, updated)
+		},
+	}
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(knobs))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	db := s.InternalDB().(isql.DB)
+	j := createImportJob(t, registry)
+	calls = nil
+
+	require.NoError(t, j.NoTxn().FractionProgressed(ctx, jobs.FractionUpdater(0.5)))
+	require.Len(t, calls, 1)
+	require.Equal(t, float32(0.5), calls[0].Progress.GetFractionCompleted())
+
+	// Updates which write nothing don't call the knob.
+	require.NoError(t, j.NoTxn().Update(ctx, func(isql.Txn, jobs.JobMetadata, *jobs.JobUpdater) error {
+		return nil
+	}))
+	require.Len(t, calls, 1)
+
+	// Nor do updates whose transaction doesn't commit.
+	injected := errors.New("injected")
+	err := db.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+		if err := j.WithTxn(txn).FractionProgressed(ctx, jobs.FractionUpdater(0.6)); err != nil {
+			return err
+		}
+		return injected
+	})
+	require.True(t, errors.Is(err, injected))
+	require.Len(t, calls, 1)
+
+	// The knob is called once the caller's transaction commits.
+	require.NoError(t, db.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+		if err := j.WithTxn(txn).FractionProgressed(ctx, jobs.FractionUpdater(0.7)); err != nil {
+			return err
+		}
+		require.Len(t, calls, 1)
+		return nil
+	}))
+	require.Len(t, calls, 2)
+	require.Equal(t, float32(0.7), calls[1].Progress.GetFractionCompleted())
+}
+
+func TestOnLargePayloadKnob(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	var sizes []int
+	knobs := &jobs.TestingKnobs{
+		OnLargePayload: func(_ jobspb.JobID, size int) {
+			sizes = append(sizes, size)
+		},
+	}
+	ctx := context.Background()
+	s, sqlDB, _ := serverutils.StartServer(t, noAdoptionTestServerArgs(knobs))
+	defer s.Stopper().Stop(ctx)
+	tdb := sqlutils.MakeSQLRunner(sqlDB)
+	tdb.Exec(t, "SET CLUSTER SETTING jobs.payload.warn_size = '1KiB'")
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+	require.Empty(t, sizes)
+
+	// Updates which only write the progress don't check the payload's size.
+	require.NoError(t, j.NoTxn().FractionProgressed(ctx, jobs.FractionUpdater(0.5)))
+	require.Empty(t, sizes)
+
+	require.NoError(t, j.NoTxn().Update(ctx, func(_ isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater) error {
+		md.Payload.Description = strings.Repeat("x", 2<<10)
+		ju.UpdatePayload(md.Payload)
+		return nil
+	}))
+	require.Len(t, sizes, 1)
+	require.Greater(t, sizes[0], 2<<10)
+}
+
+// TestUpdateBatchRunsUpdateHooks verifies that batched updates run the
+// testing knobs, check the payload size and honor the Updater options as
+// Updater.Update does.
+func TestUpdateBatchRunsUpdateHooks(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	var updated []jobspb.JobID
+	var sizes []int
+	knobs := &jobs.TestingKnobs{
+		AfterUpdate: func(_, md jobs.JobMetadata) {
+			updated = append(updated, md.ID)
+		},
+		OnLargePayload: func(_ jobspb.JobID, size int) {
+			sizes = append(sizes, size)
+		},
+	}
+	ctx := context.Background()
+	s, sqlDB, _ := serverutils.StartServer(t, noAdoptionTestServerArgs(knobs))
+	defer s.Stopper().Stop(ctx)
+	tdb := sqlutils.MakeSQLRunner(sqlDB)
+	tdb.Exec(t, "SET CLUSTER SETTING jobs.payload.warn_size = '1KiB'")
+	registry := s.JobRegistry().(*jobs.Registry)
+	ids := []jobspb.JobID{createImportJob(t, registry).ID(), createImportJob(t, registry).ID()}
+	updated = nil
+
+	require.NoError(t, registry.UpdateBatch(ctx, ids, func(
+		_ isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater,
+	) error {
+		md.Payload.Description = strings.Repeat("x", 2<<10)
+		ju.UpdatePayload(md.Payload)
+		return nil
+	}))
+	require.ElementsMatch(t, ids, updated)
+	require.Len(t, sizes, 2)
+
+	// Status changes must run under serializable isolation, as with Update.
+	err := registry.UpdateBatch(ctx, ids, func(
+		_ isql.Txn, _ jobs.JobMetadata, ju *jobs.JobUpdater,
+	) error {
+		ju.UpdateStatus(jobs.StatusPaused)
+		return nil
+	}, func(u jobs.Updater) jobs.Updater {
+		return u.WithIsolation(isolation.ReadCommitted)
+	})
+	require.Error(t, err)
+	require.Len(t, updated, 2)
+}
+
+func TestOverrideUpdateRowCountKnob(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	var vanish atomic.Bool
+	knobs := &jobs.TestingKnobs{
+		OverrideUpdateRowCount: func() (int, bool) {
+			return 0, vanish.Load()
+		},
+	}
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(knobs))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+	status := j.Status()
+
+	vanish.Store(true)
+	err := j.NoTxn().Update(ctx, func(_ isql.Txn, _ jobs.JobMetadata, ju *jobs.JobUpdater) error {
+		ju.UpdateStatus(jobs.StatusPaused)
+		return nil
+	})
+	require.ErrorContains(t, err, "expected exactly one row affected, but 0 rows affected")
+
+	vanish.Store(false)
+	loaded, err := registry.LoadJob(ctx, j.ID())
+	require.NoError(t, err)
+	require.Equal(t, status, loaded.Status())
+}
+
+func TestSkipUpdateKnob(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	var skip atomic.Bool
+	knobs := &jobs.TestingKnobs{
+		SkipUpdate: func(_, _ jobs.JobMetadata) bool {
+			return skip.Load()
+		},
+	}
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(knobs))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+	status := j.Status()
+
+	skip.Store(true)
+	require.NoError(t, j.NoTxn().Update(ctx, func(_ isql.Txn, _ jobs.JobMetadata, ju *jobs.JobUpdater) error {
+		ju.UpdateStatus(jobs.StatusPaused)
+		return nil
+	}))
+	loaded, err := registry.LoadJob(ctx, j.ID())
+	require.NoError(t, err)
+	require.Equal(t, status, loaded.Status())
+
+	skip.Store(false)
+	require.NoError(t, j.NoTxn().Update(ctx, func(_ isql.Txn, _ jobs.JobMetadata, ju *jobs.JobUpdater) error {
+		ju.UpdateStatus(jobs.StatusPaused)
+		return nil
+	}))
+	loaded, err = registry.LoadJob(ctx, j.ID())
+	require.NoError(t, err)
+	require.Equal(t, jobs.StatusPaused, loaded.Status())
+}
+
+func TestUpdateHighwaterProgressedRegression(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+	require.NoError(t, j.NoTxn().SwitchToHighWater(ctx, hlc.Timestamp{WallTime: 10}))
+
+	setHighWater := func(wallTime int64, allowRegression bool) error {
+		return j.NoTxn().Update(ctx, func(_ isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater) error {
+			return jobs.UpdateHighwaterProgressedWithOpts(
+				hlc.Timestamp{WallTime: wallTime}, md, ju, allowRegression)
+		})
+	}
+	highWater := func() int64 {
+		loaded, err := registry.LoadJob(ctx, j.ID())
+		require.NoError(t, err)
+		return loaded.Progress().GetHighWater().WallTime
+	}
+
+	require.NoError(t, setHighWater(20, false))
+	require.Equal(t, int64(20), highWater())
+
+	err := setHighWater(15, false)
+	require.True(t, errors.Is(err, jobs.ErrHighWaterRegression), "unexpected error: %v", err)
+	require.Equal(t, int64(20), highWater())
+
+	require.NoError(t, j.NoTxn().Update(ctx, func(_ isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater) error {
+		return jobs.UpdateHighwaterProgressed(hlc.Timestamp{WallTime: 20}, md, ju)
+	}))
+	require.NoError(t, setHighWater(15, true))
+	require.Equal(t, int64(15), highWater())
+}
+
+func TestUpdaterWithoutSessionCheck(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s, sqlDB, _ := serverutils.StartServer(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	runner := sqlutils.MakeSQLRunner(sqlDB)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+
+	runner.Exec(t, "UPDATE system.jobs SET claim_session_id = 'other' WHERE id = $1", j.ID())
+	err := j.NoTxn().FractionProgressed(ctx, jobs.FractionUpdater(0.5))
+	require.ErrorContains(t, err, "expected session")
+
+	require.NoError(t, j.NoTxn().WithoutSessionCheck().FractionProgressed(ctx, jobs.FractionUpdater(0.5)))
+	loaded, err := registry.LoadJob(ctx, j.ID())
+	require.NoError(t, err)
+	require.Equal(t, float32(0.5), loaded.FractionCompleted())
+}
+
+func TestUpdaterWithSequence(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+
+	sequence := func() int64 {
+		md, err := j.NoTxn().LoadMetadata(ctx)
+		require.NoError(t, err)
+		return md.Sequence
+	}
+	setURI := func(u jobs.Updater, uri string) error {
+		return u.SetDetails(ctx, jobspb.ImportDetails{URIs: []string{uri}})
+	}
+
+	require.Equal(t, int64(0), sequence())
+	require.NoError(t, setURI(j.NoTxn(), "a"))
+	require.Equal(t, int64(1), sequence())
+	// Updates which write nothing leave the sequence alone.
+	require.NoError(t, j.NoTxn().Update(ctx, func(isql.Txn, jobs.JobMetadata, *jobs.JobUpdater) error {
+		return nil
+	}))
+	require.Equal(t, int64(1), sequence())
+
+	require.NoError(t, setURI(j.NoTxn().WithSequence(1), "b"))
+	require.Equal(t, int64(2), sequence())
+
+	err := setURI(j.NoTxn().WithSequence(1), "c")
+	require.True(t, errors.Is(err, jobs.ErrStaleSequence), "unexpected error: %v", err)
+	loaded, err := registry.LoadJob(ctx, j.ID())
+	require.NoError(t, err)
+	require.Equal(t, []string{"b"}, loaded.Details().(jobspb.ImportDetails).URIs)
+
+	// Updates which only write the progress bump the sequence too, so they are
+	// detected by conditional updates.
+	require.NoError(t, j.NoTxn().FractionProgressed(ctx, jobs.FractionUpdater(0.1)))
+	require.Equal(t, int64(3), sequence())
+	err = setURI(j.NoTxn().WithSequence(2), "c")
+	require.True(t, errors.Is(err, jobs.ErrStaleSequence), "unexpected error: %v", err)
+
+	require.NoError(t, registry.UpdateBatch(ctx, []jobspb.JobID{j.ID()}, func(
+		_ isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater,
+	) error {
+		md.Payload.GetImport().URIs = []string{"d"}
+		ju.UpdatePayload(md.Payload)
+		return nil
+	}))
+	require.Equal(t, int64(4), sequence())
+}
+
+func TestJobUpdaterPauseWithReason(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+
+	pause := func(reason string) error {
+		return j.NoTxn().Update(ctx, func(_ isql.Txn, _ jobs.JobMetadata, ju *jobs.JobUpdater) error {
+			ju.PauseWithReason(reason)
+			return nil
+		})
+	}
+	load := func() *jobs.Job {
+		loaded, err := registry.LoadJob(ctx, j.ID())
+		require.NoError(t, err)
+		return loaded
+	}
+
+	require.NoError(t, pause("disk full"))
+	loaded := load()
+	require.Equal(t, jobs.StatusPauseRequested, loaded.Status())
+	require.Equal(t, "disk full", loaded.Payload().PauseReason)
+
+	// Once the job is paused, resuming it clears the reason.
+	require.NoError(t, j.NoTxn().Update(ctx, func(_ isql.Txn, _ jobs.JobMetadata, ju *jobs.JobUpdater) error {
+		ju.UpdateStatus(jobs.StatusPaused)
+		return nil
+	}))
+	require.Equal(t, "disk full", load().Payload().PauseReason)
+	require.NoError(t, registry.Unpause(ctx, nil /* txn */, j.ID()))
+	loaded = load()
+	require.Equal(t, jobs.StatusRunning, loaded.Status())
+	require.Empty(t, loaded.Payload().PauseReason)
+
+	require.NoError(t, registry.Succeeded(ctx, nil /* txn */, j.ID()))
+	require.True(t, errors.Is(pause("too late"), jobs.ErrIllegalStatusTransition))
+	require.Empty(t, load().Payload().PauseReason)
+}
+
+func TestRateLimitedFractionProgressed(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s, sqlDB, _ := serverutils.StartServer(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	tdb := sqlutils.MakeSQLRunner(sqlDB)
+	tdb.Exec(t, "SET CLUSTER SETTING jobs.progress.fraction_min_delta = 0.05")
+	tdb.Exec(t, "SET CLUSTER SETTING jobs.progress.fraction_min_interval = '1h'")
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+
+	persisted := func() float32 {
+		loaded, err := registry.LoadJob(ctx, j.ID())
+		require.NoError(t, err)
+		return loaded.FractionCompleted()
+	}
+
+	// The first call always writes.
+	require.NoError(t, j.RateLimitedFractionProgressed(ctx, 0.1))
+	require.Equal(t, float32(0.1), persisted())
+
+	// Small changes only update the in-memory progress.
+	require.NoError(t, j.RateLimitedFractionProgressed(ctx, 0.12))
+	require.Equal(t, float32(0.12), j.FractionCompleted())
+	require.Equal(t, float32(0.1), persisted())
+
+	// Changes by at least the minimum delta since the last write are written.
+	require.NoError(t, j.RateLimitedFractionProgressed(ctx, 0.2))
+	require.Equal(t, float32(0.2), persisted())
+
+	// Completion is always written.
+	require.NoError(t, j.RateLimitedFractionProgressed(ctx, 0.21))
+	require.NoError(t, j.RateLimitedFractionProgressed(ctx, 1))
+	require.Equal(t, float32(1), persisted())
+
+	// As is any change once the minimum interval has passed.
+	tdb.Exec(t, "SET CLUSTER SETTING jobs.progress.fraction_min_interval = '0s'")
+	require.NoError(t, j.RateLimitedFractionProgressed(ctx, 0.99))
+	require.Equal(t, float32(0.99), persisted())
+}
+
+func TestJobUpdaterRecordFailedRun(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+
+	recordFailedRun := func(err error, now time.Time) {
+		require.NoError(t, j.NoTxn().Update(ctx, func(_ isql.Txn, _ jobs.JobMetadata, ju *jobs.JobUpdater) error {
+			ju.RecordFailedRun(err, now)
+			return nil
+		}))
+	}
+	check := func(expectedErr string, expectedRuns int, expectedLastRun time.Time) {
+		require.NoError(t, j.NoTxn().Update(ctx, func(_ isql.Txn, md jobs.JobMetadata, _ *jobs.JobUpdater) error {
+			require.Equal(t, expectedErr, md.Payload.Error)
+			require.NotNil(t, md.RunStats)
+			require.Equal(t, expectedRuns, md.RunStats.NumRuns)
+			require.True(t, expectedLastRun.Equal(md.RunStats.LastRun),
+				"expected last run %s, found %s", expectedLastRun, md.RunStats.LastRun)
+			return nil
+		}))
+	}
+
+	var initialRuns int
+	require.NoError(t, j.NoTxn().Update(ctx, func(_ isql.Txn, md jobs.JobMetadata, _ *jobs.JobUpdater) error {
+		if md.RunStats != nil {
+			initialRuns = md.RunStats.NumRuns
+		}
+		return nil
+	}))
+
+	first := timeutil.Unix(100, 0)
+	recordFailedRun(errors.New("first failure"), first)
+	check("first failure", initialRuns+1, first)
+
+	second := timeutil.Unix(200, 0)
+	recordFailedRun(errors.New("second failure"), second)
+	check("second failure", initialRuns+2, second)
+}
+
+func TestUpdaterUpdateWithInfo(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	db := s.InternalDB().(isql.DB)
+	j := createImportJob(t, registry)
+
+	checkpoint := func(fraction float32, shard string, injected error) error {
+		return j.NoTxn().UpdateWithInfo(ctx, func(
+			_ isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater, info jobs.InfoStorage,
+		) error {
+			if err := info.Write(ctx, "shard", []byte(shard)); err != nil {
+				return err
+			}
+			md.Progress.Progress = &jobspb.Progress_FractionCompleted{FractionCompleted: fraction}
+			ju.UpdateProgress(md.Progress)
+			return injected
+		})
+	}
+	check := func(fraction float32, shard string) {
+		loaded, err := registry.LoadJob(ctx, j.ID())
+		require.NoError(t, err)
+		require.Equal(t, fraction, loaded.FractionCompleted())
+		require.NoError(t, db.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+			value, ok, err := j.InfoStorage(txn).Get(ctx, "shard")
+			if err != nil {
+				return err
+			}
+			require.True(t, ok)
+			require.Equal(t, shard, string(value))
+			return nil
+		}))
+	}
+
+	require.NoError(t, checkpoint(0.3, "a", nil))
+	check(0.3, "a")
+
+	// Neither the progress nor the info record are written if the update fails.
+	injected := errors.New("injected")
+	require.True(t, errors.Is(checkpoint(0.6, "b", injected), injected))
+	check(0.3, "a")
+}
+
+func TestUpdaterLoadMetadataAsOf(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	db := s.InternalDB().(isql.DB)
+	j := createImportJob(t, registry)
+
+	require.NoError(t, j.NoTxn().FractionProgressed(ctx, jobs.FractionUpdater(0.3)))
+	before := s.Clock().Now()
+	require.NoError(t, j.NoTxn().FractionProgressed(ctx, jobs.FractionUpdater(0.6)))
+	require.NoError(t, registry.PauseRequested(ctx, nil /* txn */, j.ID(), "test"))
+
+	md, err := j.NoTxn().LoadMetadataAsOf(ctx, before)
+	require.NoError(t, err)
+	require.Equal(t, jobs.StatusRunning, md.Status)
+	require.Equal(t, float32(0.3), md.Progress.GetFractionCompleted())
+	require.Empty(t, md.Payload.PauseReason)
+
+	md, err = j.NoTxn().LoadMetadataAsOf(ctx, s.Clock().Now())
+	require.NoError(t, err)
+	require.Equal(t, jobs.StatusPauseRequested, md.Status)
+	require.Equal(t, float32(0.6), md.Progress.GetFractionCompleted())
+	require.Equal(t, "test", md.Payload.PauseReason)
+
+	// Historical reads are not possible in a transaction.
+	require.Error(t, db.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+		_, err := j.WithTxn(txn).LoadMetadataAsOf(ctx, before)
+		return err
+	}))
+}
+
+func TestUpdaterWithIsolation(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+
+	u := j.NoTxn().WithIsolation(isolation.ReadCommitted)
+	require.NoError(t, u.FractionProgressed(ctx, jobs.FractionUpdater(0.4)))
+	loaded, err := registry.LoadJob(ctx, j.ID())
+	require.NoError(t, err)
+	require.Equal(t, float32(0.4), loaded.FractionCompleted())
+
+	// Status changes must run under serializable isolation.
+	require.Error(t, u.Update(ctx, func(_ isql.Txn, _ jobs.JobMetadata, ju *jobs.JobUpdater) error {
+		ju.UpdateStatus(jobs.StatusPaused)
+		return nil
+	}))
+	require.NoError(t, j.NoTxn().WithIsolation(isolation.Serializable).Update(ctx, func(
+		_ isql.Txn, _ jobs.JobMetadata, ju *jobs.JobUpdater,
+	) error {
+		ju.UpdateStatus(jobs.StatusPaused)
+		return nil
+	}))
+	loaded, err = registry.LoadJob(ctx, j.ID())
+	require.NoError(t, err)
+	require.Equal(t, jobs.StatusPaused, loaded.Status())
+}
+
+func TestRegistryInFlightUpdates(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	var blockJob atomic.Int64
+	blocked, unblock := make(chan struct{}), make(chan struct{})
+	knobs := &jobs.TestingKnobs{
+		BeforeUpdate: func(orig, _ jobs.JobMetadata) error {
+			if int64(orig.ID) == blockJob.Load() {
+				blocked <- struct{}{}
+				<-unblock
+			}
+			return nil
+		},
+	}
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(knobs))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+	require.Empty(t, registry.InFlightUpdates())
+
+	blockJob.Store(int64(j.ID()))
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- j.NoTxn().FractionProgressed(ctx, jobs.FractionUpdater(0.5))
+	}()
+	<-blocked
+	inFlight := registry.InFlightUpdates()
+	require.Len(t, inFlight, 1)
+	require.Equal(t, j.ID(), inFlight[0].ID)
+	require.Equal(t, jobs.UpdatePhaseUpdateFn, inFlight[0].Phase)
+	require.Greater(t, inFlight[0].Duration, time.Duration(0))
+
+	blockJob.Store(0)
+	close(unblock)
+	require.NoError(t, <-errCh)
+	require.Empty(t, registry.InFlightUpdates())
+}
+
+func TestUpdaterClearProgress(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+
+	require.NoError(t, j.NoTxn().Update(ctx, func(_ isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater) error {
+		md.Progress.Progress = &jobspb.Progress_FractionCompleted{FractionCompleted: 0.5}
+		md.Progress.GetImport().ResumePos = []int64{1, 2, 3}
+		ju.UpdateProgress(md.Progress)
+		return nil
+	}))
+
+	require.NoError(t, j.NoTxn().ClearProgress(ctx))
+	loaded, err := registry.LoadJob(ctx, j.ID())
+	require.NoError(t, err)
+	for _, progress := range []jobspb.Progress{j.Progress(), loaded.Progress()} {
+		require.Zero(t, progress.GetFractionCompleted())
+		require.NotZero(t, progress.ModifiedMicros)
+		require.NotNil(t, progress.GetImport())
+		require.Empty(t, progress.GetImport().ResumePos)
+	}
+
+	require.NoError(t, registry.Succeeded(ctx, nil /* txn */, j.ID()))
+	var statusErr *jobs.InvalidStatusError
+	require.True(t, errors.As(j.NoTxn().ClearProgress(ctx), &statusErr))
+}
+
+func TestJobUpdaterTouchLastRun(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+
+	runStats := func() jobs.RunStats {
+		md, err := j.NoTxn().LoadMetadata(ctx)
+		require.NoError(t, err)
+		return *md.RunStats
+	}
+
+	first := timeutil.Unix(100, 0)
+	require.NoError(t, j.NoTxn().Update(ctx, func(_ isql.Txn, _ jobs.JobMetadata, ju *jobs.JobUpdater) error {
+		ju.UpdateRunStats(3, first)
+		return nil
+	}))
+
+	touched := timeutil.Unix(200, 0)
+	require.NoError(t, j.NoTxn().Update(ctx, func(_ isql.Txn, _ jobs.JobMetadata, ju *jobs.JobUpdater) error {
+		ju.TouchLastRun(touched)
+		return nil
+	}))
+	rs := runStats()
+	require.Equal(t, 3, rs.NumRuns)
+	require.True(t, touched.Equal(rs.LastRun), "expected %s, found %s", touched, rs.LastRun)
+
+	// Run stats set explicitly take precedence over an earlier touch.
+	require.NoError(t, j.NoTxn().Update(ctx, func(_ isql.Txn, _ jobs.JobMetadata, ju *jobs.JobUpdater) error {
+		ju.TouchLastRun(touched)
+		ju.UpdateRunStats(5, first)
+		return nil
+	}))
+	rs = runStats()
+	require.Equal(t, 5, rs.NumRuns)
+	require.True(t, first.Equal(rs.LastRun), "expected %s, found %s", first, rs.LastRun)
+}
+
+func TestUpdaterChecksProgressKind(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	defer jobs.TestingRegisterConstructor(jobspb.TypeImport, func(*jobs.Job, *cluster.Settings) jobs.Resumer {
+		return jobstest.FakeResumer{}
+	}, jobs.UsesTenantCostControl, jobs.WithProgressKind(jobs.ProgressKindFraction))()
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+
+	require.NoError(t, j.NoTxn().FractionProgressed(ctx, jobs.FractionUpdater(0.5)))
+	err := j.NoTxn().Update(ctx, func(_ isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater) error {
+		md.Progress.Progress = &jobspb.Progress_HighWater{HighWater: &hlc.Timestamp{WallTime: 1}}
+		ju.UpdateProgress(md.Progress)
+		return nil
+	})
+	require.True(t, errors.Is(err, jobs.ErrProgressKindMismatch), "%+v", err)
+
+	loaded, err := registry.LoadJob(ctx, j.ID())
+	require.NoError(t, err)
+	require.Equal(t, float32(0.5), loaded.FractionCompleted())
+}
+
+func TestUpdaterWithProgressTimestamp(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+
+	modifiedMicros := func() int64 {
+		loaded, err := registry.LoadJob(ctx, j.ID())
+		require.NoError(t, err)
+		return loaded.Progress().ModifiedMicros
+	}
+
+	const backfilled = int64(1234567)
+	require.NoError(t, j.NoTxn().WithProgressTimestamp(backfilled).FractionProgressed(
+		ctx, jobs.FractionUpdater(0.2)))
+	require.Equal(t, backfilled, modifiedMicros())
+
+	before := timeutil.ToUnixMicros(timeutil.Now())
+	require.NoError(t, j.NoTxn().FractionProgressed(ctx, jobs.FractionUpdater(0.3)))
+	require.GreaterOrEqual(t, modifiedMicros(), before)
+}
+
+func TestUpdaterTryUpdate(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	db := s.InternalDB().(isql.DB)
+	j := createImportJob(t, registry)
+
+	// Hold a lock on the job's row in a concurrent transaction.
+	locked, release := make(chan struct{}), make(chan struct{})
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- db.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+			if _, err := txn.Exec(ctx, "lock-job", txn.KV(),
+				"SELECT id FROM system.jobs WHERE id = $1 FOR UPDATE", j.ID(),
+			); err != nil {
+				return err
+			}
+			close(locked)
+			<-release
+			return nil
+		})
+	}()
+	<-locked
+
+	done, err := j.NoTxn().TryUpdate(ctx, func(_ isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater) error {
+		md.Progress.Progress = &jobspb.Progress_FractionCompleted{FractionCompleted: 0.4}
+		ju.UpdateProgress(md.Progress)
+		return nil
+	})
+	require.NoError(t, err)
+	require.False(t, done)
+
+	close(release)
+	require.NoError(t, <-errCh)
+	done, err = j.NoTxn().TryUpdate(ctx, func(_ isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater) error {
+		md.Progress.Progress = &jobspb.Progress_FractionCompleted{FractionCompleted: 0.4}
+		ju.UpdateProgress(md.Progress)
+		return nil
+	})
+	require.NoError(t, err)
+	require.True(t, done)
+	loaded, err := registry.LoadJob(ctx, j.ID())
+	require.NoError(t, err)
+	require.Equal(t, float32(0.4), loaded.FractionCompleted())
+}
+
+func TestUpdaterWithStatementTimeout(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	db := s.InternalDB().(isql.DB)
+	j := createImportJob(t, registry)
+
+	// Hold a lock on the job's row in a concurrent transaction.
+	locked, release := make(chan struct{}), make(chan struct{})
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- db.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+			if _, err := txn.Exec(ctx, "lock-job", txn.KV(),
+				"SELECT id FROM system.jobs WHERE id = $1 FOR UPDATE", j.ID(),
+			); err != nil {
+				return err
+			}
+			close(locked)
+			<-release
+			return nil
+		})
+	}()
+	<-locked
+
+	err := j.NoTxn().WithStatementTimeout(100*time.Millisecond).FractionProgressed(
+		ctx, jobs.FractionUpdater(0.4))
+	require.True(t, errors.Is(err, jobs.ErrUpdateTimeout), "%+v", err)
+
+	close(release)
+	require.NoError(t, <-errCh)
+	require.NoError(t, j.NoTxn().WithStatementTimeout(time.Minute).FractionProgressed(
+		ctx, jobs.FractionUpdater(0.4)))
+	loaded, err := registry.LoadJob(ctx, j.ID())
+	require.NoError(t, err)
+	require.Equal(t, float32(0.4), loaded.FractionCompleted())
+}
+
+func TestUpdaterSkipRedundantWrites(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+	require.NoError(t, j.NoTxn().FractionProgressed(ctx, jobs.FractionUpdater(0.3)))
+
+	var stats jobs.UpdateStats
+	u := j.NoTxn().SkipRedundantWrites().WithStats(&stats)
+
+	require.NoError(t, u.FractionProgressed(ctx, jobs.FractionUpdater(0.3)))
+	require.False(t, stats.ProgressWritten)
+
+	require.NoError(t, u.Update(ctx, func(_ isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater) error {
+		ju.UpdatePayload(md.Payload)
+		return nil
+	}))
+	require.False(t, stats.PayloadWritten)
+
+	require.NoError(t, u.FractionProgressed(ctx, jobs.FractionUpdater(0.4)))
+	require.True(t, stats.ProgressWritten)
+	require.NoError(t, u.Update(ctx, func(_ isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater) error {
+		md.Payload.Description = "changed"
+		ju.UpdatePayload(md.Payload)
+		return nil
+	}))
+	require.True(t, stats.PayloadWritten)
+	require.False(t, stats.ProgressWritten)
+
+	loaded, err := registry.LoadJob(ctx, j.ID())
+	require.NoError(t, err)
+	require.Equal(t, float32(0.4), loaded.FractionCompleted())
+	require.Equal(t, "changed", loaded.Payload().Description)
+}
+
+func TestUnchangedPayloadWritesSkipped(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s, sqlDB, _ := serverutils.StartServer(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	tdb := sqlutils.MakeSQLRunner(sqlDB)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+	m := registry.MetricsStruct()
+
+	written := func() (ts time.Time) {
+		tdb.QueryRow(t, "SELECT written FROM system.job_info WHERE job_id = $1 AND info_key = $2",
+			j.ID(), jobs.LegacyPayloadKey).Scan(&ts)
+		return ts
+	}
+	before := written()
+	skipped := m.UpdatesPayloadSkipped.Count()
+
+	var stats jobs.UpdateStats
+	require.NoError(t, j.NoTxn().WithStats(&stats).Update(ctx, func(
+		_ isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater,
+	) error {
+		ju.UpdatePayload(md.Payload)
+		return nil
+	}))
+	require.False(t, stats.PayloadWritten)
+	require.Equal(t, skipped+1, m.UpdatesPayloadSkipped.Count())
+	require.Equal(t, before, written())
+
+	// Updates which don't set the payload don't compare it.
+	require.NoError(t, j.NoTxn().FractionProgressed(ctx, jobs.FractionUpdater(0.5)))
+	require.Equal(t, skipped+1, m.UpdatesPayloadSkipped.Count())
+	require.Equal(t, before, written())
+
+	// Changed payloads are written.
+	require.NoError(t, j.NoTxn().WithStats(&stats).Update(ctx, func(
+		_ isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater,
+	) error {
+		md.Payload.Description = "changed"
+		ju.UpdatePayload(md.Payload)
+		return nil
+	}))
+	require.True(t, stats.PayloadWritten)
+	require.Equal(t, skipped+1, m.UpdatesPayloadSkipped.Count())
+	require.NotEqual(t, before, written())
+}
+
+func TestJobMetadataLastUpdatedBy(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	db := s.InternalDB().(isql.DB)
+	j := createImportJob(t, registry)
+
+	require.NoError(t, j.NoTxn().SetDetails(ctx, jobspb.ImportDetails{URIs: []string{"new"}}))
+	md, err := j.NoTxn().LoadMetadata(ctx)
+	require.NoError(t, err)
+	require.Equal(t, registry.ID(), md.LastUpdatedBy)
+
+	// The batch path loads it too.
+	require.NoError(t, registry.UpdateBatch(ctx, []jobspb.JobID{j.ID()}, func(
+		_ isql.Txn, md jobs.JobMetadata, _ *jobs.JobUpdater,
+	) error {
+		require.Equal(t, registry.ID(), md.LastUpdatedBy)
+		return nil
+	}))
+
+	// Jobs which haven't been updated have no last updater.
+	other, err := registry.CreateJobWithTxn(ctx, jobs.Record{
+		Details:  jobspb.ImportDetails{},
+		Progress: jobspb.ImportProgress{},
+		Username: username.TestUserName(),
+	}, registry.MakeJobID(), nil /* txn */)
+	require.NoError(t, err)
+	require.NoError(t, db.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+		return other.InfoStorage(txn).Delete(ctx, "last_updated_by")
+	}))
+	md, err = other.NoTxn().LoadMetadata(ctx)
+	require.NoError(t, err)
+	require.Zero(t, md.LastUpdatedBy)
+}
+
+func TestJobMetadataCreated(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s, sqlDB, _ := serverutils.StartServer(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	tdb := sqlutils.MakeSQLRunner(sqlDB)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+
+	var created time.Time
+	tdb.QueryRow(t, "SELECT created FROM system.jobs WHERE id = $1", j.ID()).Scan(&created)
+
+	// Updates don't change the creation time.
+	require.NoError(t, j.NoTxn().Update(ctx, func(_ isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater) error {
+		require.True(t, created.Equal(md.Created), "%s != %s", created, md.Created)
+		ju.UpdateRunStats(md.RunStats.NumRuns+1, timeutil.Now())
+		return nil
+	}))
+	md, err := j.NoTxn().LoadMetadata(ctx)
+	require.NoError(t, err)
+	require.True(t, created.Equal(md.Created), "%s != %s", created, md.Created)
+
+	// The batch path loads it too.
+	require.NoError(t, registry.UpdateBatch(ctx, []jobspb.JobID{j.ID()}, func(
+		_ isql.Txn, md jobs.JobMetadata, _ *jobs.JobUpdater,
+	) error {
+		require.True(t, created.Equal(md.Created), "%s != %s", created, md.Created)
+		return nil
+	}))
+}
+
+func TestJobUpdaterUpdateProgressWithCheckpoint(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+
+	checkpoint := func(fraction float32, id uint64) error {
+		return j.NoTxn().Update(ctx, func(_ isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater) error {
+			md.Progress.Progress = &jobspb.Progress_FractionCompleted{FractionCompleted: fraction}
+			ju.UpdateProgressWithCheckpoint(md.Progress, id)
+			return nil
+		})
+	}
+	loadCheckpoint := func() (float32, uint64) {
+		md, err := j.NoTxn().LoadMetadata(ctx)
+		require.NoError(t, err)
+		return md.Progress.GetFractionCompleted(), md.CheckpointID
+	}
+
+	_, id := loadCheckpoint()
+	require.Zero(t, id)
+
+	require.NoError(t, checkpoint(0.2, 1))
+	fraction, id := loadCheckpoint()
+	require.Equal(t, float32(0.2), fraction)
+	require.Equal(t, uint64(1), id)
+
+	// Checkpoint IDs must increase.
+	for _, stale := range []uint64{0, 1} {
+		err := checkpoint(0.4, stale)
+		require.True(t, errors.Is(err, jobs.ErrCheckpointRegression), "%+v", err)
+	}
+	fraction, id = loadCheckpoint()
+	require.Equal(t, float32(0.2), fraction)
+	require.Equal(t, uint64(1), id)
+
+	require.NoError(t, checkpoint(0.4, 5))
+
+	// Plain progress updates leave the checkpoint ID as it is.
+	require.NoError(t, j.NoTxn().FractionProgressed(ctx, jobs.FractionUpdater(0.5)))
+	require.NoError(t, registry.UpdateBatch(ctx, []jobspb.JobID{j.ID()}, func(
+		_ isql.Txn, md jobs.JobMetadata, _ *jobs.JobUpdater,
+	) error {
+		require.Equal(t, uint64(5), md.CheckpointID)
+		return nil
+	}))
+}
+
+func TestUpdaterFailed(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+
+	load := func() jobs.JobMetadata {
+		md, err := j.NoTxn().LoadMetadata(ctx)
+		require.NoError(t, err)
+		return md
+	}
+
+	// Running jobs revert first.
+	require.NoError(t, j.NoTxn().Failed(ctx, errors.New("out of disk")))
+	md := load()
+	require.Equal(t, jobs.StatusReverting, md.Status)
+	require.Equal(t, "out of disk", md.Payload.Error)
+	require.NotNil(t, md.Payload.FinalResumeError)
+	require.Zero(t, md.Payload.FinishedMicros)
+	require.Equal(t, 1, md.RunStats.NumRuns)
+
+	// Reverting jobs fail.
+	require.NoError(t, j.NoTxn().Failed(ctx, errors.New("revert failed too")))
+	md = load()
+	require.Equal(t, jobs.StatusFailed, md.Status)
+	require.Equal(t, "revert failed too", md.Payload.Error)
+	require.NotZero(t, md.Payload.FinishedMicros)
+	require.Equal(t, 2, md.RunStats.NumRuns)
+
+	var statusErr *jobs.InvalidStatusError
+	require.True(t, errors.As(j.NoTxn().Failed(ctx, errors.New("again")), &statusErr))
+}
+
+func TestUpdaterSucceeded(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+
+	require.NoError(t, j.NoTxn().Update(ctx, func(_ isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater) error {
+		md.Payload.Error = "transient"
+		ju.UpdatePayload(md.Payload)
+		return nil
+	}))
+	require.NoError(t, j.NoTxn().Succeeded(ctx))
+	md, err := j.NoTxn().LoadMetadata(ctx)
+	require.NoError(t, err)
+	require.Equal(t, jobs.StatusSucceeded, md.Status)
+	require.NotZero(t, md.Payload.FinishedMicros)
+	require.Empty(t, md.Payload.Error)
+
+	// Only running jobs can succeed.
+	var statusErr *jobs.InvalidStatusError
+	require.True(t, errors.As(j.NoTxn().Succeeded(ctx), &statusErr))
+}
+
+func TestJobProgressUpdater(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s, sqlDB, _ := serverutils.StartServer(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	tdb := sqlutils.MakeSQLRunner(sqlDB)
+	tdb.Exec(t, "SET CLUSTER SETTING jobs.progress.fraction_min_interval = '1h'")
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+
+	persisted := func() float32 {
+		loaded, err := registry.LoadJob(ctx, j.ID())
+		require.NoError(t, err)
+		return loaded.FractionCompleted()
+	}
+
+	p, err := j.ProgressUpdater(ctx)
+	require.NoError(t, err)
+	require.Equal(t, j.ID(), p.Metadata().ID)
+	for i := 1; i <= 100; i++ {
+		p.Set(float32(i) / 200)
+		require.NoError(t, p.MaybeFlush(ctx))
+	}
+	require.Equal(t, float32(0.5), j.FractionCompleted())
+	require.Zero(t, persisted())
+
+	require.NoError(t, p.Flush(ctx))
+	require.Equal(t, float32(0.5), persisted())
+	require.Equal(t, float32(0.5), p.Metadata().Progress.GetFractionCompleted())
+
+	// Flushes check the job's claim.
+	tdb.Exec(t, "UPDATE system.jobs SET claim_session_id = NULL WHERE id = $1", j.ID())
+	p.Set(0.6)
+	require.Error(t, p.Flush(ctx))
+	require.Equal(t, float32(0.5), persisted())
+}
+
+func TestUpdaterRejectsNumRunsRegression(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+
+	numRuns := func() int {
+		md, err := j.NoTxn().LoadMetadata(ctx)
+		require.NoError(t, err)
+		return md.RunStats.NumRuns
+	}
+	lastRun := timeutil.Unix(100, 0)
+	update := func(fn func(ju *jobs.JobUpdater)) error {
+		return j.NoTxn().Update(ctx, func(_ isql.Txn, _ jobs.JobMetadata, ju *jobs.JobUpdater) error {
+			fn(ju)
+			return nil
+		})
+	}
+
+	require.NoError(t, update(func(ju *jobs.JobUpdater) { ju.UpdateRunStats(5, lastRun) }))
+	err := update(func(ju *jobs.JobUpdater) { ju.UpdateRunStats(2, lastRun) })
+	require.True(t, errors.Is(err, jobs.ErrRunStatsRegression), "%+v", err)
+	require.Equal(t, 5, numRuns())
+
+	require.NoError(t, update(func(ju *jobs.JobUpdater) { ju.ResetNumRuns(2, lastRun) }))
+	require.Equal(t, 2, numRuns())
+	require.NoError(t, update(func(ju *jobs.JobUpdater) { ju.ResetRunStats() }))
+	require.Equal(t, 0, numRuns())
+}
+
+func TestUpdaterUpdateSetting(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	db := s.InternalDB().(isql.DB)
+	j := createImportJob(t, registry)
+
+	readSetting := func(key string) (value []byte, ok bool) {
+		require.NoError(t, db.Txn(ctx, func(ctx context.Context, txn isql.Txn) (err error) {
+			value, ok, err = j.InfoStorage(txn).ReadSetting(ctx, key)
+			return err
+		}))
+		return value, ok
+	}
+
+	_, ok := readSetting("concurrency")
+	require.False(t, ok)
+	require.NoError(t, j.NoTxn().UpdateSetting(ctx, "concurrency", []byte("4")))
+	value, ok := readSetting("concurrency")
+	require.True(t, ok)
+	require.Equal(t, []byte("4"), value)
+
+	// Settings can be written along with the job's metadata, or directly.
+	var stats jobs.UpdateStats
+	require.NoError(t, j.NoTxn().WithStats(&stats).Update(ctx, func(
+		_ isql.Txn, _ jobs.JobMetadata, ju *jobs.JobUpdater,
+	) error {
+		ju.UpdateSetting("concurrency", []byte("8"))
+		ju.UpdateStatus(jobs.StatusPaused)
+		return nil
+	}))
+	require.False(t, stats.PayloadWritten)
+	require.False(t, stats.ProgressWritten)
+	value, _ = readSetting("concurrency")
+	require.Equal(t, []byte("8"), value)
+
+	require.NoError(t, db.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+		return j.InfoStorage(txn).WriteSetting(ctx, "batch_size", []byte("100"))
+	}))
+	value, _ = readSetting("batch_size")
+	require.Equal(t, []byte("100"), value)
+
+	require.NoError(t, j.NoTxn().UpdateSetting(ctx, "concurrency", nil))
+	_, ok = readSetting("concurrency")
+	require.False(t, ok)
+}
+
+func TestUpdateChecksContextAfterUpdateFn(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+	status := j.Status()
+
+	updateCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	err := j.NoTxn().Update(updateCtx, func(_ isql.Txn, _ jobs.JobMetadata, ju *jobs.JobUpdater) error {
+		ju.UpdateStatus(jobs.StatusPaused)
+		// Simulate the caller giving up while the UpdateFn runs.
+		cancel()
+		return nil
+	})
+	require.True(t, errors.Is(err, context.Canceled), "%+v", err)
+
+	loaded, err := registry.LoadJob(ctx, j.ID())
+	require.NoError(t, err)
+	require.Equal(t, status, loaded.Status())
+}
+
+func TestJobUpdaterUpdateRunningStatus(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s, sqlDB, _ := serverutils.StartServer(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	tdb := sqlutils.MakeSQLRunner(sqlDB)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+
+	require.NoError(t, j.NoTxn().Update(ctx, func(_ isql.Txn, _ jobs.JobMetadata, ju *jobs.JobUpdater) error {
+		ju.UpdateRunningStatus("validating indexes")
+		return ju.UpdateFractionCompleted(0.25)
+	}))
+	tdb.CheckQueryResults(t, fmt.Sprintf(
+		"SELECT running_status, fraction_completed FROM [SHOW JOBS] WHERE job_id = %d", j.ID(),
+	), [][]string{{"validating indexes", "0.25"}})
+
+	// The fraction can be updated independently of the running status.
+	require.NoError(t, j.NoTxn().FractionProgressed(ctx, jobs.FractionUpdater(0.5)))
+	tdb.CheckQueryResults(t, fmt.Sprintf(
+		"SELECT running_status, fraction_completed FROM [SHOW JOBS] WHERE job_id = %d", j.ID(),
+	), [][]string{{"validating indexes", "0.5"}})
+}
+
+func TestUpdaterWithDB(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	src := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer src.Stopper().Stop(ctx)
+	dst := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer dst.Stopper().Stop(ctx)
+	srcRegistry := src.JobRegistry().(*jobs.Registry)
+	dstRegistry := dst.JobRegistry().(*jobs.Registry)
+
+	j := createImportJob(t, srcRegistry)
+	_, err := dstRegistry.CreateJobWithTxn(ctx, jobs.Record{
+		Details:  jobspb.ImportDetails{},
+		Progress: jobspb.ImportProgress{},
+		Username: username.TestUserName(),
+	}, j.ID(), nil /* txn */)
+	require.NoError(t, err)
+
+	require.NoError(t, j.NoTxn().WithDB(dst.InternalDB().(isql.DB)).Update(ctx, func(
+		_ isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater,
+	) error {
+		md.Payload.Description = "imported"
+		ju.UpdatePayload(md.Payload)
+		return nil
+	}))
+
+	loaded, err := dstRegistry.LoadJob(ctx, j.ID())
+	require.NoError(t, err)
+	require.Equal(t, "imported", loaded.Payload().Description)
+	loaded, err = srcRegistry.LoadJob(ctx, j.ID())
+	require.NoError(t, err)
+	require.Empty(t, loaded.Payload().Description)
+}
+
+func TestUpdaterStateSize(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s, sqlDB, _ := serverutils.StartServer(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	tdb := sqlutils.MakeSQLRunner(sqlDB)
+	tdb.Exec(t, "SET CLUSTER SETTING jobs.progress_lineage.retained_versions = 3")
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+
+	for _, f := range []float32{0.1, 0.2, 0.3} {
+		require.NoError(t, j.NoTxn().FractionProgressed(ctx, jobs.FractionUpdater(f)))
+	}
+	b, err := j.NoTxn().StateSize(ctx)
+	require.NoError(t, err)
+
+	var payload, progress, other, historical int64
+	var versions int
+	tdb.QueryRow(t, `
+SELECT
+  sum(length(value)) FILTER (WHERE info_key = 'legacy_payload' AND latest),
+  sum(length(value)) FILTER (WHERE info_key = 'legacy_progress' AND latest),
+  COALESCE(sum(length(value)) FILTER (WHERE info_key NOT IN ('legacy_payload', 'legacy_progress') AND latest), 0),
+  COALESCE(sum(length(value)) FILTER (WHERE NOT latest), 0),
+  count(*) FILTER (WHERE NOT latest)
+FROM (
+  SELECT info_key, value, written = max(written) OVER (PARTITION BY info_key) AS latest
+  FROM system.job_info WHERE job_id = $1
+)`, j.ID()).Scan(&payload, &progress, &other, &historical, &versions)
+
+	require.Equal(t, jobs.SizeBreakdown{
+		Payload:            payload,
+		Progress:           progress,
+		Other:              other,
+		Historical:         historical,
+		HistoricalVersions: versions,
+	}, b)
+	// The retained versions of the progress are counted separately.
+	require.Equal(t, 2, b.HistoricalVersions)
+	require.Positive(t, b.Payload)
+	require.Equal(t, payload+progress+other+historical, b.Total())
+}
+
+func TestJobUpdaterConvertProgress(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+
+	convert := func(fn func(ju *jobs.JobUpdater) error) (*jobspb.Progress, error) {
+		if err := j.NoTxn().Update(ctx, func(_ isql.Txn, _ jobs.JobMetadata, ju *jobs.JobUpdater) error {
+			return fn(ju)
+		}); err != nil {
+			return nil, err
+		}
+		md, err := j.NoTxn().LoadMetadata(ctx)
+		require.NoError(t, err)
+		return md.Progress, nil
+	}
+
+	require.NoError(t, j.NoTxn().FractionProgressed(ctx, jobs.FractionUpdater(0.5)))
+	hw := hlc.Timestamp{WallTime: 42}
+	p, err := convert(func(ju *jobs.JobUpdater) error { return ju.ConvertProgressToHighWater(hw) })
+	require.NoError(t, err)
+	require.Equal(t, &hw, p.GetHighWater())
+	require.Zero(t, p.GetFractionCompleted())
+
+	p, err = convert(func(ju *jobs.JobUpdater) error { return ju.ConvertProgressToFraction(0.9) })
+	require.NoError(t, err)
+	require.Nil(t, p.GetHighWater())
+	require.Equal(t, float32(0.9), p.GetFractionCompleted())
+
+	// Invalid values are rejected.
+	_, err = convert(func(ju *jobs.JobUpdater) error { return ju.ConvertProgressToHighWater(hlc.Timestamp{}) })
+	require.ErrorContains(t, err, "empty high-water mark")
+	_, err = convert(func(ju *jobs.JobUpdater) error { return ju.ConvertProgressToFraction(1.5) })
+	require.ErrorContains(t, err, "outside allowable range")
+	loaded, err := registry.LoadJob(ctx, j.ID())
+	require.NoError(t, err)
+	require.Equal(t, float32(0.9), loaded.FractionCompleted())
+}
+
+func TestJobUpdaterSetErrorDetails(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s := serverutils.StartServerOnly(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+
+	_, ok, err := j.ErrorDetails(ctx)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	details := jobs.JobErrorDetails{
+		Code:             "22P02",
+		Retryable:        false,
+		LastOffendingRow: "line 42",
+	}
+	require.NoError(t, j.NoTxn().Update(ctx, func(_ isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater) error {
+		md.Payload.Error = "invalid input syntax"
+		ju.UpdatePayload(md.Payload)
+		ju.UpdateStatus(jobs.StatusFailed)
+		return ju.SetErrorDetails(details)
+	}))
+
+	loaded, err := registry.LoadJob(ctx, j.ID())
+	require.NoError(t, err)
+	require.Equal(t, jobs.StatusFailed, loaded.Status())
+	require.Equal(t, "invalid input syntax", loaded.Payload().Error)
+	stored, ok, err := loaded.ErrorDetails(ctx)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, details, stored)
+}
+
+func TestUpdaterReparent(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s, sqlDB, _ := serverutils.StartServer(t, noAdoptionTestServerArgs(nil))
+	defer s.Stopper().Stop(ctx)
+	tdb := sqlutils.MakeSQLRunner(sqlDB)
+	registry := s.JobRegistry().(*jobs.Registry)
+	j := createImportJob(t, registry)
+
+	session, err := s.SQLLivenessProvider().(sqlliveness.Provider).Session(ctx)
+	require.NoError(t, err)
+	require.NoError(t, j.NoTxn().Reparent(ctx, session))
+
+	var claimSessionID []byte
+	var claimInstanceID int
+	tdb.QueryRow(t, "SELECT claim_session_id, claim_instance_id FROM system.jobs WHERE id = $1", j.ID()).
+		Scan(&claimSessionID, &claimInstanceID)
+	require.Equal(t, session.ID(), sqlliveness.SessionID(claimSessionID))
+	require.Equal(t, int(registry.ID()), claimInstanceID)
+
+	// Sessions without a SQL instance cannot be handed the job.
+	err = j.NoTxn().Reparent(ctx, &fakeSession{id: "unknown"})
+	require.ErrorContains(t, err, "no SQL instance found")
+
+	// Terminal jobs cannot be reparented.
+	require.NoError(t, j.NoTxn().Succeeded(ctx))
+	var statusErr *jobs.InvalidStatusError
+	require.True(t, errors.As(j.NoTxn().Reparent(ctx, session), &statusErr))
+}
+
+// fakeSession is a sqlliveness.Session which is never stored.
+type fakeSession struct {
+	id sqlliveness.SessionID
+}
+
+var _ sqlliveness.Session = (*fakeSession)(nil)
+
+func (s *fakeSession) ID() sqlliveness.SessionID { return s.id }
+func (s *fakeSession) Start() hlc.Timestamp      { return hlc.Timestamp{} }
+func (s *fakeSession) Expiration() hlc.Timestamp { return hlc.MaxTimestamp }
@@ -84,7 +84,7 @@ func (r *resumer) Resume(ctx context.Context, execCtxI interface{}) (jobErr erro
 	// restart at the job system level with no backoff.
 	if err := r.job.NoTxn().Update(ctx, func(_ isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater) error {
 		if md.RunStats != nil && md.RunStats.NumRuns > 1 {
-			ju.UpdateRunStats(1, md.RunStats.LastRun)
+			ju.ResetNumRuns(1, md.RunStats.LastRun)
 		}
 		return nil
 	}); err != nil {
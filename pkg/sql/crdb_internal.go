@@ -1111,6 +1111,15 @@ func populateSystemJobsTableRows(
 		if err != nil {
 			return matched, wrapPayloadUnMarshalError(err, currentRow[jobIdIdx])
 		}
+		// Expose the payload uncompressed, so that it can be decoded by
+		// crdb_internal.pb_to_json regardless of how it was written.
+		if b, ok := payloadBytes.(*tree.DBytes); ok {
+			decoded, err := jobs.DecodePayload([]byte(*b))
+			if err != nil {
+				return matched, wrapPayloadUnMarshalError(err, currentRow[jobIdIdx])
+			}
+			currentRow[jobPayloadIdx] = tree.NewDBytes(tree.DBytes(decoded))
+		}
 
 		err = jobsauth.Authorize(
 			ctx, p, jobspb.JobID(jobID), payload, jobsauth.ViewAccess, globalPrivileges,
@@ -1361,8 +1370,14 @@ func makeJobsTableRows(
 				}
 
 				if s, ok := status.(*tree.DString); ok {
-					if jobs.Status(*s) == jobs.StatusRunning && len(progress.RunningStatus) > 0 {
-						runningStatus = tree.NewDString(progress.RunningStatus)
+					if jobs.Status(*s) == jobs.StatusRunning {
+						if len(progress.RunningStatus) > 0 {
+							runningStatus = tree.NewDString(progress.RunningStatus)
+						} else if rendered, custom := jobs.RenderProgress(payload.Type(), progress); custom {
+							// Job types with a registered progress renderer describe
+							// their progress in the running status.
+							runningStatus = tree.NewDString(rendered)
+						}
 					} else if jobs.Status(*s) == jobs.StatusPaused && payload != nil && payload.PauseReason != "" {
 						errorStr = tree.NewDString(fmt.Sprintf("%s: %s", jobs.PauseRequestExplained, payload.PauseReason))
 					}
@@ -45,7 +45,7 @@ func (j *tableMetadataUpdateJobResumer) Resume(ctx context.Context, execCtxI int
 	// delayed by the job system's exponential backoff strategy.
 	if err := j.job.NoTxn().Update(ctx, func(txn isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater) error {
 		if md.RunStats != nil && md.RunStats.NumRuns > 0 {
-			ju.UpdateRunStats(0, md.RunStats.LastRun)
+			ju.ResetNumRuns(0, md.RunStats.LastRun)
 		}
 		return nil
 	}); err != nil {
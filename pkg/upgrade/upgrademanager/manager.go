@@ -771,7 +771,7 @@ running_migration_jobs AS (
     AND job_type = 'MIGRATION'
 ),
 payloads AS (
-    SELECT job_id, value
+    SELECT job_id, ` + jobs.DecodePayloadSQL("value") + ` AS value
     FROM system.job_info AS payload
     WHERE info_key = 'legacy_payload'
     AND job_id IN (SELECT id FROM running_migration_jobs)